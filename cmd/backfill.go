@@ -0,0 +1,113 @@
+// cmd/backfill.go
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/guarzo/zkillanalytics/internal/api"
+	"github.com/guarzo/zkillanalytics/internal/api/esi"
+	"github.com/guarzo/zkillanalytics/internal/api/zkill"
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+	"github.com/guarzo/zkillanalytics/internal/service"
+	"github.com/guarzo/zkillanalytics/internal/utils"
+)
+
+// RunBackfill imports whole months from zKillboard's daily history files into the monthly store.
+// History is downloaded from zKillboard unless -dir points at a directory of YYYYMMDD.json files.
+func RunBackfill(setup *config.AppSetup, args []string) error {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	from := flags.String("from", "", "first month to backfill, as YYYY-MM")
+	to := flags.String("to", "", "last month to backfill, as YYYY-MM (defaults to -from)")
+	dir := flags.String("dir", "", "directory of zKillboard history files to read instead of downloading")
+	group := flags.String("group", config.DefaultGroup, "tracked group whose killmails to import")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+	if *to == "" {
+		to = from
+	}
+
+	startDate, err := time.Parse("2006-01", *from)
+	if err != nil {
+		return fmt.Errorf("invalid -from month: %w", err)
+	}
+	endDate, err := time.Parse("2006-01", *to)
+	if err != nil {
+		return fmt.Errorf("invalid -to month: %w", err)
+	}
+	if endDate.Before(startDate) {
+		return fmt.Errorf("-to %s is before -from %s", *to, *from)
+	}
+
+	logger := newLogger()
+
+	// Cached killmails are reused, so a warm cache lets a local backfill run without ESI
+	cache, err := persist.OpenCache(persist.GenerateCacheDataFileName(), setup.CacheMaxBytes, logger)
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer cache.Close()
+
+	trackedIDs := persist.NewFileTrackedIDRepository()
+	failedChars, err := trackedIDs.LoadFailedCharacters()
+	if err != nil {
+		logger.Errorf("Failed to load failed characters: %v", err)
+		failedChars = &model.FailedCharacters{CharacterIDs: make(map[int]bool)}
+	}
+
+	httpClient := api.NewRateLimitedClient(utils.NewHTTPClientWithUserAgent(setup.UserAgent), logger)
+	esiClient := esi.NewEsiClient(config.BaseEsiURL, failedChars, trackedIDs, httpClient, cache, logger)
+
+	loadHistory := service.HistoryDirLoader(*dir)
+	if *dir == "" {
+		loadHistory = zkill.NewZkillClient(config.ZkillURL, httpClient, cache, logger).GetHistory
+	}
+
+	// Track what the server tracks, falling back to the defaults before the tracked entities file is written
+	trackedFile := setup.TrackedFile
+	if trackedFile == "" {
+		trackedFile = persist.TrackedEntitiesFile
+	}
+	if fileTracked, err := persist.LoadTrackedEntities(trackedFile); err == nil {
+		config.SetTrackedConfig(*fileTracked)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	tracked, ok := config.Group(*group)
+	if !ok {
+		return fmt.Errorf("unknown group %q", *group)
+	}
+	store := persist.GroupKillMailStore(*group)
+
+	repository, err := persist.OpenKillMailRepository(store.KillMailDBFileName())
+	if err != nil {
+		return err
+	}
+	defer repository.Close()
+
+	hydrator := service.NewKillMailHydrator(setup.EsiConcurrency, esiClient.FetchEsiKillMail, logger)
+	backfillService := service.NewBackfillService(loadHistory, hydrator, repository, logger)
+	backfillService.Group = *group
+	backfillService.Store = store
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	params := model.NewParams(httpClient, tracked.Corporations, tracked.Alliances, tracked.Characters, nil, false, nil)
+	result, err := backfillService.Backfill(ctx, startDate, endDate, &params)
+	logger.Infof("Backfill read %d days listing %d killmails: %d tracked, %d added, %d failed",
+		result.Days, result.Listed, result.Tracked, result.Added, result.Failed)
+	return err
+}
@@ -0,0 +1,65 @@
+// cmd/backups.go
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+// backupAliases name the irreplaceable files so they can be given without their path.
+var backupAliases = map[string]string{
+	"trust":   persist.TrustedCharactersFile,
+	"loot":    config.LootFile,
+	"tracked": persist.TrackedEntitiesFile,
+}
+
+// RunBackups lists or restores the timestamped backups kept for a persisted file:
+//
+//	backups list <file>
+//	backups restore <file> <id>
+//
+// The file may be a path or one of the aliases trust, loot and tracked.
+func RunBackups(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: backups list <file> | backups restore <file> <id>")
+	}
+
+	fileName := args[1]
+	if path, ok := backupAliases[fileName]; ok {
+		fileName = path
+	}
+
+	switch args[0] {
+	case "list":
+		backups, err := persist.ListBackups(fileName)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			fmt.Printf("No backups of %s\n", fileName)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tSIZE")
+		for _, backup := range backups {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", backup.ID, backup.Time.Local().Format("2006-01-02 15:04:05"), backup.Size)
+		}
+		return w.Flush()
+	case "restore":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: backups restore <file> <id>")
+		}
+		if err := persist.RestoreBackup(fileName, args[2]); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s from backup %s\n", fileName, args[2])
+		return nil
+	default:
+		return fmt.Errorf("unknown backups command %q", args[0])
+	}
+}
@@ -0,0 +1,56 @@
+// cmd/migrate.go
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+// RunMigrate upgrades every persisted document and month file under the data directory to the
+// current schema versions, printing each file changed. With -dry-run it only reports what would change.
+func RunMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dir := flags.String("dir", "data", "directory holding persisted data")
+	dryRun := flags.Bool("dry-run", false, "report the files that would be migrated without changing them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	reports, err := persist.MigrateData(*dir, *dryRun)
+	migrated, refetch := 0, 0
+	if len(reports) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FILE\tSCHEMA\tFROM\tTO")
+		for _, report := range reports {
+			if report.Refetch {
+				refetch++
+				fmt.Fprintf(w, "%s\t%s\t%d\trefetch (no killmail IDs)\n", report.File, report.Schema, report.From)
+				continue
+			}
+			migrated++
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", report.File, report.Schema, report.From, report.To)
+		}
+		w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	if refetch > 0 {
+		fmt.Printf("%d month files predate stored killmail IDs and will be fetched again when their months are requested\n", refetch)
+	}
+	switch {
+	case migrated == 0:
+		fmt.Println("All persisted data is current")
+	case *dryRun:
+		fmt.Printf("%d files would be migrated\n", migrated)
+	default:
+		fmt.Printf("Migrated %d files\n", migrated)
+	}
+	return nil
+}
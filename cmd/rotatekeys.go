@@ -0,0 +1,41 @@
+// cmd/rotatekeys.go
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+// RunRotateKeys re-encrypts every identity file under the data directory, and its backups, with the current SECRET_KEY.
+// Files sealed with a key listed in SECRET_KEY_PREVIOUS, or in the legacy format, are rewritten;
+// those already under the current key are left alone.
+func RunRotateKeys(setup *config.AppSetup, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	dir := flags.String("dir", "data", "directory searched for identity files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := persist.Initialize(setup.Key, setup.PreviousKeys...); err != nil {
+		return err
+	}
+
+	result, err := persist.RotateIdentityKeys(*dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-encrypted %d identity files and %d backups with key %s; %d were already current\n",
+		result.Rotated, result.Backups, persist.KeyID(), result.Current)
+	if len(result.Failed) > 0 {
+		for _, path := range result.Failed {
+			fmt.Printf("Could not decrypt %s with any known key\n", path)
+		}
+		return fmt.Errorf("%d identity files could not be decrypted", len(result.Failed))
+	}
+	return nil
+}
@@ -20,11 +20,13 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 
+	"github.com/guarzo/zkillanalytics/internal/api"
 	"github.com/guarzo/zkillanalytics/internal/api/esi"
 	"github.com/guarzo/zkillanalytics/internal/api/zkill"
 	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/data"
 	"github.com/guarzo/zkillanalytics/internal/handlers"
+	"github.com/guarzo/zkillanalytics/internal/handlers/apiv1"
 	"github.com/guarzo/zkillanalytics/internal/handlers/loot"
 	"github.com/guarzo/zkillanalytics/internal/handlers/tps"
 	"github.com/guarzo/zkillanalytics/internal/handlers/trust"
@@ -34,6 +36,9 @@ import (
 	"github.com/guarzo/zkillanalytics/internal/utils"
 )
 
+// trackedFileCheckInterval is how often the tracked entities file is checked for edits.
+const trackedFileCheckInterval = 10 * time.Second
+
 // logRequestHost middleware logs the host and path of each incoming request
 func logRequestHost(logger *logrus.Logger) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
@@ -63,22 +68,59 @@ func loggingMiddleware(logger *logrus.Logger) mux.MiddlewareFunc {
 	}
 }
 
-// registerTPSRoutes registers the routes for the TPS subdomain
-func registerTPSRoutes(r *mux.Router, orchestrateService *service.OrchestrateService, sessionStore *handlers.SessionService, esiService *service.EsiService) {
-	r.Use(handlers.AuthMiddleware(sessionStore, esiService))
+// registerTPSRoutes registers the routes for the TPS subdomain. The default group's dashboard is served
+// at the root and every other group's under /g/{group}/. Every group's data jobs share one queue, and
+// tracked entity backfills run on a queue of their own so they never hold up the dashboards.
+func registerTPSRoutes(r *mux.Router, groups *service.GroupRegistry, trackingService *service.TrackingService, sessionStore *handlers.SessionService, esiService *service.EsiService, adminIDs []int64, apiTokens []string) {
+	// The API checks tokens and sessions itself
+	r.Use(handlers.AuthMiddleware(sessionStore, esiService, "/api/v1/"))
 	r.HandleFunc("/login", handlers.LoginHandler(esiService))
 	r.HandleFunc("/landing", handlers.LandingHandler)
 	r.HandleFunc("/logout", handlers.LogoutHandler(sessionStore))
 	r.HandleFunc("/callback/", handlers.CallbackHandler(sessionStore, esiService))
 
-	r.HandleFunc("/", tps.TPSHandler(config.Snippets, orchestrateService)).Methods("GET")
-	r.HandleFunc("/refresh", tps.RefreshTPSHandler(orchestrateService)).Methods("GET")
+	r.HandleFunc("/jobs/{id}", tps.JobStatusHandler(groups.Default)).Methods("GET")
+	r.HandleFunc("/jobs/{id}/events", tps.JobEventsHandler(groups.Default)).Methods("GET")
+
+	// JSON API for bots and spreadsheets, described by a public OpenAPI document
+	r.HandleFunc("/api/v1/openapi.json", apiv1.OpenAPIHandler(groups)).Methods("GET")
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(handlers.APIAuthMiddleware(sessionStore, esiService, apiTokens, groups.Logger))
+	api.HandleFunc("/charts", apiv1.ListChartsHandler(groups)).Methods("GET")
+	api.HandleFunc("/groups", apiv1.ListGroupsHandler(groups)).Methods("GET")
+	api.HandleFunc("/groups/{group}/charts", apiv1.GroupChartsHandler(groups)).Methods("GET")
+	api.HandleFunc("/groups/{group}/charts/{chart}", apiv1.GroupChartHandler(groups)).Methods("GET")
+
+	registerDashboardRoutes(r, groups, trackingService, sessionStore, adminIDs)
+	r.HandleFunc("/g/{group}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+	}).Methods("GET")
+	registerDashboardRoutes(r.PathPrefix("/g/{group}").Subrouter(), groups, trackingService, sessionStore, adminIDs)
+
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 	r.NotFoundHandler = http.HandlerFunc(handlers.NotFoundHandler)
 }
 
+// registerDashboardRoutes registers a tracked group's dashboard and admin routes, taking the group from
+// the {group} path variable when r is the /g/{group} subrouter.
+func registerDashboardRoutes(r *mux.Router, groups *service.GroupRegistry, trackingService *service.TrackingService, sessionStore *handlers.SessionService, adminIDs []int64) {
+	r.HandleFunc("/", tps.GroupHandler(groups, func(orchestrateService *service.OrchestrateService) http.HandlerFunc {
+		return tps.TPSHandler(config.Snippets, orchestrateService)
+	})).Methods("GET")
+	r.HandleFunc("/refresh", tps.GroupHandler(groups, tps.RefreshTPSHandler)).Methods("GET")
+	r.HandleFunc("/range", tps.GroupHandler(groups, tps.RangeHandler)).Methods("GET")
+
+	// admin routes
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(handlers.AdminMiddleware(sessionStore, adminIDs, trackingService.Logger))
+	admin.HandleFunc("/tracked", tps.GetTrackedHandler(trackingService)).Methods("GET")
+	admin.HandleFunc("/tracked", tps.ReplaceTrackedHandler(trackingService)).Methods("PUT")
+	admin.HandleFunc("/tracked/{list}", tps.AddTrackedHandler(trackingService)).Methods("POST")
+	admin.HandleFunc("/tracked/{list}/{id}", tps.RemoveTrackedHandler(trackingService)).Methods("DELETE")
+}
+
 // registerLootRoutes registers the routes for the loot subdomain
-func registerLootRoutes(r *mux.Router, sessionStore *handlers.SessionService, esiService *service.EsiService) {
+func registerLootRoutes(r *mux.Router, sessionStore *handlers.SessionService, esiService *service.EsiService, lootSplits persist.LootSplitRepository) {
 	r.Use(handlers.AuthMiddleware(sessionStore, esiService))
 	r.HandleFunc("/login", handlers.LoginHandler(esiService))
 	r.HandleFunc("/landing", handlers.LandingHandler)
@@ -88,11 +130,11 @@ func registerLootRoutes(r *mux.Router, sessionStore *handlers.SessionService, es
 	r.HandleFunc("/", loot.LootAppraisalPageHandler).Methods("GET")
 	r.HandleFunc("/loot-appraisal", loot.LootAppraisalPageHandler).Methods("GET")
 	r.HandleFunc("/appraise-loot", loot.AppraiseLootHandler).Methods("POST")
-	r.HandleFunc("/save-loot-split", loot.SaveLootSplitHandler).Methods("POST")
-	r.HandleFunc("/delete-loot-split", loot.DeleteLootSplitHandler).Methods("POST")
-	r.HandleFunc("/save-loot-splits", loot.SaveLootSplitsHandler).Methods("POST")
-	r.HandleFunc("/fetch-loot-splits", loot.FetchLootSplitsHandler).Methods("GET")
-	r.HandleFunc("/update-loot-split", loot.UpdateLootSplitHandler).Methods("POST")
+	r.HandleFunc("/save-loot-split", loot.SaveLootSplitHandler(lootSplits)).Methods("POST")
+	r.HandleFunc("/delete-loot-split", loot.DeleteLootSplitHandler(lootSplits)).Methods("POST")
+	r.HandleFunc("/save-loot-splits", loot.SaveLootSplitsHandler(lootSplits)).Methods("POST")
+	r.HandleFunc("/fetch-loot-splits", loot.FetchLootSplitsHandler(lootSplits)).Methods("GET")
+	r.HandleFunc("/update-loot-split", loot.UpdateLootSplitHandler(lootSplits)).Methods("POST")
 
 	r.HandleFunc("/loot-summary", loot.LootSummaryHandler).Methods("GET")
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
@@ -138,18 +180,20 @@ func registerTrustRoutes(r *mux.Router, sessionStore *handlers.SessionService, t
 }
 
 // registerDefaultRoutes registers the default routes for hosts like localhost:8080 or zoolanders.space
-func registerDefaultRoutes(r *mux.Router, logger *logrus.Logger) {
+func registerDefaultRoutes(r *mux.Router, cache *persist.Cache, logger *logrus.Logger) {
 	// Health Check Endpoint
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		health := struct {
-			Status       string `json:"status"`
-			CacheStatus  string `json:"cache_status"`
-			ESIConnected bool   `json:"esi_connected"`
+			Status       string                        `json:"status"`
+			CacheStatus  string                        `json:"cache_status"`
+			Cache        map[string]persist.CacheStats `json:"cache"`
+			ESIConnected bool                          `json:"esi_connected"`
 			// Add more fields as needed
 		}{
 			Status:       "OK",
-			CacheStatus:  "Connected", // Implement actual cache status check
-			ESIConnected: true,        // Implement actual ESI connection check
+			CacheStatus:  "Connected",
+			Cache:        cache.Stats(),
+			ESIConnected: true, // Implement actual ESI connection check
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -170,9 +214,8 @@ func registerDefaultRoutes(r *mux.Router, logger *logrus.Logger) {
 	}).Methods("GET")
 }
 
-// StartServer starts the HTTP server with the specified routes
-func StartServer(setup *config.AppSetup) {
-	// Initialize Logger
+// newLogger creates the logger shared by the server and the command-line tools.
+func newLogger() *logrus.Logger {
 	logger := logrus.New()
 	logger.SetOutput(os.Stdout)
 	logger.SetLevel(logrus.InfoLevel) // Set to Debug for more detailed logs
@@ -184,6 +227,13 @@ func StartServer(setup *config.AppSetup) {
 		ForceColors:     true,
 		TimestampFormat: "2006-01-02 15:04:05",
 	})
+	return logger
+}
+
+// StartServer starts the HTTP server with the specified routes
+func StartServer(setup *config.AppSetup) {
+	// Initialize Logger
+	logger := newLogger()
 
 	// Log runtime information
 	logger.Info("Initializing server...")
@@ -215,26 +265,26 @@ func StartServer(setup *config.AppSetup) {
 	}
 
 	// Initialize Cache
-	cache := persist.NewCache(logger)
-	if cache == nil {
-		logger.Fatal("Failed to initialize cache")
-	}
-
-	// Load cache from file at startup
 	cacheFile := persist.GenerateCacheDataFileName()
-	if err := cache.LoadFromFile(cacheFile); err != nil {
-		logger.Errorf("Failed to load cache from file: %v", err)
-	} else {
-		logger.Infof("Cache loaded from %s", cacheFile)
+	cache, err := persist.OpenCache(cacheFile, setup.CacheMaxBytes, logger)
+	if err != nil {
+		logger.Fatalf("Failed to open cache: %v", err)
 	}
+	logger.Infof("Cache opened from %s", cacheFile)
+
+	// Stores for everything persisted outside the killmail database
+	trackedIDs := persist.NewFileTrackedIDRepository()
+	trustedRepository := persist.NewFileTrustedRepository(persist.TrustedCharactersFile)
+	identities := persist.NewFileIdentityRepository()
+	lootSplits := persist.NewFileLootSplitRepository(config.LootFile)
 
-	failedChars, err := persist.LoadFailedCharacters()
+	failedChars, err := trackedIDs.LoadFailedCharacters()
 	if err != nil {
 		logger.Errorf("Failed to load failed characters: %v", err)
 	}
 
 	// Initialize configuration directory
-	if err = persist.Initialize(setup.Key); err != nil {
+	if err = persist.Initialize(setup.Key, setup.PreviousKeys...); err != nil {
 		log.Fatalf("Failed to initialize identity: %v", err)
 	}
 
@@ -261,12 +311,12 @@ func StartServer(setup *config.AppSetup) {
 		logger.Infof("Using new charts directory: %v", err)
 	}
 
-	// Initialize HTTP Client with User-Agent
-	httpClient := utils.NewHTTPClientWithUserAgent(setup.UserAgent)
+	// Initialize HTTP Client with User-Agent, sending every request through the shared per-host rate limiter
+	httpClient := api.NewRateLimitedClient(utils.NewHTTPClientWithUserAgent(setup.UserAgent), logger)
 
-	lootSessionStore, lootEsiService := initializeForHost("loot", failedChars, httpClient, cache, logger, setup.Secret)
-	tpsSessionStore, tpsEsiService := initializeForHost("tps", failedChars, httpClient, cache, logger, setup.Secret)
-	trustSessionStore, trustEsiService := initializeForHost("trust", failedChars, httpClient, cache, logger, setup.Secret)
+	lootSessionStore, lootEsiService := initializeForHost("loot", failedChars, trackedIDs, identities, trustedRepository, httpClient, cache, logger, setup.Secret)
+	tpsSessionStore, tpsEsiService := initializeForHost("tps", failedChars, trackedIDs, identities, trustedRepository, httpClient, cache, logger, setup.Secret)
+	trustSessionStore, trustEsiService := initializeForHost("trust", failedChars, trackedIDs, identities, trustedRepository, httpClient, cache, logger, setup.Secret)
 
 	zkillClient := zkill.NewZkillClient(config.ZkillURL, httpClient, cache, logger)
 	invTypeService := data.NewInvTypeService(logger) // Ensure this function exists and is correctly implemented
@@ -274,23 +324,82 @@ func StartServer(setup *config.AppSetup) {
 	if err != nil {
 		logger.Fatalf("failed to load invtypes %v", err)
 	}
-	killMailService := service.NewKillMailService(zkillClient, tpsEsiService, cache, logger)
-	orchestrateService := service.NewOrchestrateService(tpsEsiService, killMailService, invTypeService, failedChars, cache, logger, httpClient)
-	// Load trusted characters on startup
-	dataLoader := persist.LoadTrustedCharacters
-	dataSaver := persist.SaveTrustedCharacters
+	staticData := data.NewStaticDataService(logger)
+	if err = staticData.LoadFromDir(setup.SDEDir); err != nil {
+		logger.Fatalf("failed to load static data %v", err)
+	}
+	// Open the killmail database, importing the monthly store files the first time
+	repository, err := persist.OpenKillMailRepository(persist.GenerateKillMailDBFileName())
+	if err != nil {
+		logger.Fatalf("failed to open killmail database %v", err)
+	}
+	imported, err := persist.ImportMonthFiles(context.Background(), repository, persist.DefaultKillMailStore)
+	if err != nil {
+		logger.Fatalf("failed to import monthly store files %v", err)
+	}
+	if imported.Files > 0 {
+		logger.Infof("Imported %d of %d killmails from %d monthly store files", imported.Added, imported.KillMails, imported.Files)
+	}
+	if imported.Skipped > 0 {
+		logger.Warnf("Skipped %d monthly store files without killmail IDs; their months will be fetched again", imported.Skipped)
+	}
 
-	// Initialize TrustedService with dependency injection
-	trustedService := service.NewTrustedService(dataLoader, dataSaver, logger)
+	killMailSource := service.NewZkillSource(zkillClient, tpsEsiService.EsiClient)
+	killMailService := service.NewKillMailService(killMailSource, cache, logger, setup.EsiConcurrency)
+	orchestrateService := service.NewOrchestrateService(tpsEsiService, killMailService, repository, trackedIDs, trustedRepository, invTypeService, staticData, failedChars, cache, logger, httpClient)
 
 	// Create a root context that we can cancel on shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel() // Ensure resources are cleaned up
 
+	// Named groups get their own store and database, sharing the default group's fetching and caches
+	groups := service.NewGroupRegistry(ctx, orchestrateService, logger)
+
+	// Load the tracked entities, backfilling stored months for any that are added
+	trackedFile := setup.TrackedFile
+	if trackedFile == "" {
+		trackedFile = persist.TrackedEntitiesFile
+	}
+	trackingService := service.NewTrackingService(trackedFile, tpsEsiService, logger)
+	if err = trackingService.Load(); err != nil {
+		logger.Fatalf("failed to load tracked entities %v", err)
+	}
+	trackingService.OnAdded = func(group string) {
+		// Opening a new group imports its store, so keep it off the caller's lock
+		go func() {
+			groupService, err := groups.Get(group)
+			if err != nil {
+				logger.Errorf("Failed to open tracked group %s: %v", group, err)
+				return
+			}
+			groupService.SubmitTrackedBackfill()
+		}()
+	}
+
+	// Initialize TrustedService with dependency injection
+	trustedService := service.NewTrustedService(trustedRepository, logger)
+
+	// Reload the tracked entities when their file is edited, and catch up on any added while stopped
+	go trackingService.Watch(ctx, trackedFileCheckInterval)
+	groups.OpenAll()
+	for _, groupService := range groups.Active() {
+		groupService.SubmitTrackedBackfill()
+	}
+
 	// Initialize and start PrefetchService with the root context
 	prefetchService := service.NewPrefetchService(orchestrateService, logger)
 	prefetchService.Start(ctx)
 
+	// Initialize and start live killmail ingestion unless disabled
+	var ingestService *service.IngestService
+	if mode, _ := utils.GetCassetteConfig(); mode == utils.CassetteModeReplay && setup.RedisQURL != "" {
+		logger.Info("Replaying HTTP cassettes, live killmail ingestion disabled")
+	} else if setup.RedisQURL != "" {
+		redisQClient := zkill.NewRedisQClient(setup.RedisQURL, setup.RedisQQueueID, httpClient, logger)
+		ingestService = service.NewIngestService(redisQClient, killMailSource, groups, logger)
+		ingestService.Start(ctx)
+	}
+
 	// Initialize Main Router
 	mainRouter := mux.NewRouter()
 
@@ -333,11 +442,11 @@ func StartServer(setup *config.AppSetup) {
 
 	// Initialize Subrouters with Host Matchers
 	tpsRouter := mainRouter.MatcherFunc(hostMatcher("tps.zoolanders.space")).Subrouter()
-	registerTPSRoutes(tpsRouter, orchestrateService, tpsSessionStore, tpsEsiService)
+	registerTPSRoutes(tpsRouter, groups, trackingService, tpsSessionStore, tpsEsiService, setup.AdminIDs, setup.APITokens)
 	logger.Info("Registered TPS subdomain routes")
 
 	lootRouter := mainRouter.MatcherFunc(hostMatcher("loot.zoolanders.space")).Subrouter()
-	registerLootRoutes(lootRouter, lootSessionStore, lootEsiService)
+	registerLootRoutes(lootRouter, lootSessionStore, lootEsiService, lootSplits)
 	logger.Info("Registered Loot subdomain routes")
 
 	trustRouter := mainRouter.MatcherFunc(hostMatcher("trust.zoolanders.space")).Subrouter()
@@ -346,7 +455,7 @@ func StartServer(setup *config.AppSetup) {
 
 	// Default Router handles all other hosts
 	defaultRouter := mainRouter.NewRoute().Subrouter()
-	registerDefaultRoutes(defaultRouter, logger)
+	registerDefaultRoutes(defaultRouter, cache, logger)
 	logger.Info("Registered Default routes")
 
 	// Log all registered routes for debugging
@@ -395,8 +504,24 @@ func StartServer(setup *config.AppSetup) {
 			logger.Errorf("HTTP server Shutdown: %v", err)
 		}
 
-		// Stop PrefetchService and wait for it to finish
+		// Stop ingestion and drain the job runners first, since both write to the databases and cache.
+		// The runners are shared by every group, and stopping them cancels the running job.
+		if ingestService != nil {
+			ingestService.Stop()
+		}
+		orchestrateService.Jobs.Stop()
+		orchestrateService.Backfills.Stop()
+
+		// Then stop the prefetchers, closing the groups' databases, and only once nothing is left writing
+		// close the shared cache and the default group's database
+		groups.Close()
 		prefetchService.Stop()
+		if err := cache.Close(); err != nil {
+			logger.Errorf("Failed to close cache: %v", err)
+		}
+		if err := repository.Close(); err != nil {
+			logger.Errorf("Failed to close killmail database: %v", err)
+		}
 
 		close(idleConnsClosed)
 	}()
@@ -415,15 +540,15 @@ func StartServer(setup *config.AppSetup) {
 	logger.Info("Server gracefully stopped")
 }
 
-func initializeForHost(host string, failedChars *model.FailedCharacters, httpClient *http.Client, cache *persist.Cache, logger *logrus.Logger, secret string) (*handlers.SessionService, *service.EsiService) {
+func initializeForHost(host string, failedChars *model.FailedCharacters, trackedIDs persist.TrackedIDRepository, identities persist.IdentityRepository, trusted persist.TrustedRepository, httpClient *http.Client, cache *persist.Cache, logger *logrus.Logger, secret string) (*handlers.SessionService, *service.EsiService) {
 	// Get environment variables for the specific host
 	clientID, clientSecret, callbackURL := utils.GetESIEnv(host)
 
 	// Initialize session store for the specific host
-	sessionStore := handlers.NewSessionService(secret) // Use unique session name if needed
+	sessionStore := handlers.NewSessionService(secret, identities, trusted) // Use unique session name if needed
 
 	// Initialize ESI client for the specific host
-	esiClient := esi.NewEsiClient(config.BaseEsiURL, failedChars, httpClient, cache, logger)
+	esiClient := esi.NewEsiClient(config.BaseEsiURL, failedChars, trackedIDs, httpClient, cache, logger)
 	esiClient.InitializeOAuth(clientID, clientSecret, callbackURL)
 
 	// Initialize ESI service for the specific host
@@ -21,7 +21,20 @@ const (
 	maxDelay   = 32 * time.Second
 )
 
-func RetryWithExponentialBackoff(operation func() (interface{}, error)) (interface{}, error) {
+// retryableStatusCodes are the responses worth retrying. Rate-limited hosts are also paused by
+// RateLimitedTransport, so the next attempt waits for the host to reopen.
+var retryableStatusCodes = map[int]bool{
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	StatusEnhanceYourCalm:          true,
+}
+
+// RetryWithExponentialBackoff retries operation on retryable HTTP errors, sleeping with jitter between attempts.
+// It stops as soon as ctx is cancelled and returns the context's error.
+func RetryWithExponentialBackoff(ctx context.Context, operation func() (interface{}, error)) (interface{}, error) {
 	var result interface{}
 	var err error
 	delay := baseDelay
@@ -30,9 +43,12 @@ func RetryWithExponentialBackoff(operation func() (interface{}, error)) (interfa
 		if result, err = operation(); err == nil {
 			return result, nil
 		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 
 		var customErr *CustomError
-		if !errors.As(err, &customErr) || (customErr.StatusCode != http.StatusServiceUnavailable && customErr.StatusCode != http.StatusGatewayTimeout) && customErr.StatusCode != http.StatusInternalServerError {
+		if !errors.As(err, &customErr) || !retryableStatusCodes[customErr.StatusCode] {
 			break
 		}
 
@@ -41,7 +57,13 @@ func RetryWithExponentialBackoff(operation func() (interface{}, error)) (interfa
 		}
 
 		jitter := time.Duration(rand.Int63n(int64(delay)))
-		time.Sleep(delay + jitter)
+		timer := time.NewTimer(delay + jitter)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
 
 		delay *= 2
 		if delay > maxDelay {
@@ -54,7 +76,7 @@ func RetryWithExponentialBackoff(operation func() (interface{}, error)) (interfa
 
 // GetPageData fetches a single page of data given a URL with context.
 func GetPageData(ctx context.Context, client *http.Client, url string) ([]model.KillMail, error) {
-	result, err := RetryWithExponentialBackoff(func() (interface{}, error) {
+	result, err := RetryWithExponentialBackoff(ctx, func() (interface{}, error) {
 		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
@@ -67,7 +89,7 @@ func GetPageData(ctx context.Context, client *http.Client, url string) ([]model.
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+			return nil, NewCustomError(resp.StatusCode, fmt.Sprintf("unexpected status code: %d", resp.StatusCode))
 		}
 
 		body, err := io.ReadAll(resp.Body)
@@ -42,17 +42,19 @@ func (esi *EsiClient) GetAuthURL(state string) string {
 }
 
 // ExchangeCode exchanges the authorization code for an access token.
-func (esi *EsiClient) ExchangeCode(code string) (*oauth2.Token, error) {
-	return esi.OAuthConfig.Exchange(context.Background(), code)
+func (esi *EsiClient) ExchangeCode(ctx context.Context, code string) (*oauth2.Token, error) {
+	// Send the exchange through the shared client so it is rate limited and can be recorded
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, esi.Client)
+	return esi.OAuthConfig.Exchange(ctx, code)
 }
 
 // RefreshToken refreshes the OAuth token using the refresh token.
-func (esi *EsiClient) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+func (esi *EsiClient) RefreshToken(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
 	data.Set("refresh_token", refreshToken)
 
-	req, err := http.NewRequest(http.MethodPost, esi.OAuthConfig.Endpoint.TokenURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, esi.OAuthConfig.Endpoint.TokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		esi.Logger.Errorf("Failed to create request to refresh token: %v", err)
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -84,9 +86,9 @@ func (esi *EsiClient) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 }
 
 // GetUserInfo retrieves user information.
-func (esi *EsiClient) GetUserInfo(token *oauth2.Token) (*model.User, error) {
+func (esi *EsiClient) GetUserInfo(ctx context.Context, token *oauth2.Token) (*model.User, error) {
 	baseURL := "https://login.eveonline.com/oauth/verify"
-	data, err := esi.getEsiEntityWithTokenNoCache(baseURL, token)
+	data, err := esi.getEsiEntityWithTokenNoCache(ctx, baseURL, token)
 	if err != nil {
 		return nil, err
 	}
@@ -100,8 +102,7 @@ func (esi *EsiClient) GetUserInfo(token *oauth2.Token) (*model.User, error) {
 }
 
 // PopulateIdentities concurrently populates character data.
-// PopulateIdentities concurrently populates character data.
-func (esi *EsiClient) PopulateIdentities(userConfig *model.Identities) (map[int64]model.CharacterData, error) {
+func (esi *EsiClient) PopulateIdentities(ctx context.Context, userConfig *model.Identities) (map[int64]model.CharacterData, error) {
 	characterData := make(map[int64]model.CharacterData)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
@@ -117,7 +118,7 @@ func (esi *EsiClient) PopulateIdentities(userConfig *model.Identities) (map[int6
 		go func(id int64, token oauth2.Token) {
 			defer wg.Done()
 
-			charIdentity, err := esi.processIdentity(id, token, userConfig, &mu)
+			charIdentity, err := esi.processIdentity(ctx, id, token, userConfig, &mu)
 			if err != nil {
 				xlog.Logf("Failed to process identity for character %d: %v", id, err)
 				return
@@ -135,8 +136,8 @@ func (esi *EsiClient) PopulateIdentities(userConfig *model.Identities) (map[int6
 }
 
 // processIdentity manages token refreshing and retrieves character data.
-func (esi *EsiClient) processIdentity(id int64, token oauth2.Token, userConfig *model.Identities, mu *sync.Mutex) (*model.CharacterData, error) {
-	newToken, err := esi.RefreshToken(token.RefreshToken)
+func (esi *EsiClient) processIdentity(ctx context.Context, id int64, token oauth2.Token, userConfig *model.Identities, mu *sync.Mutex) (*model.CharacterData, error) {
+	newToken, err := esi.RefreshToken(ctx, token.RefreshToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to refresh token for character %d: %v", id, err)
 	}
@@ -146,17 +147,17 @@ func (esi *EsiClient) processIdentity(id int64, token oauth2.Token, userConfig *
 	userConfig.Tokens[fmt.Sprintf("%d", id)] = token
 	mu.Unlock()
 
-	corp, err := esi.GetCharacterCorporation(id, &token)
+	corp, err := esi.GetCharacterCorporation(ctx, id, &token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get corp for character %d: %v", id, err)
 	}
 
-	user, err := esi.GetUserInfo(&token)
+	user, err := esi.GetUserInfo(ctx, &token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user info: %v", err)
 	}
 
-	portrait, err := esi.GetCharacterPortrait(id)
+	portrait, err := esi.GetCharacterPortrait(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get character portrait: %v", err)
 	}
@@ -0,0 +1,340 @@
+// internal/api/esi/bulk.go
+
+package esi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/guarzo/zkillanalytics/internal/api"
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// esiBulkChunkSize is the most IDs ESI accepts in a single bulk POST.
+const esiBulkChunkSize = 1000
+
+// esiNamesChunkSize is the most names ESI accepts in a single /universe/ids/ POST.
+const esiNamesChunkSize = 500
+
+// EntityIDs collects character, corporation and alliance IDs that still need resolving.
+// Corporations map to the alliance they were seen in, so it can be kept when ESI does not say otherwise.
+type EntityIDs struct {
+	Characters   map[int]bool
+	Corporations map[int]int
+	Alliances    map[int]bool
+}
+
+// NewEntityIDs returns an empty EntityIDs.
+func NewEntityIDs() *EntityIDs {
+	return &EntityIDs{
+		Characters:   make(map[int]bool),
+		Corporations: make(map[int]int),
+		Alliances:    make(map[int]bool),
+	}
+}
+
+// AddKillMail records every victim and attacker entity on killMail that esiData does not already hold.
+func (ids *EntityIDs) AddKillMail(killMail *model.EsiKillMail, esiData *model.ESIData) {
+	ids.add(killMail.Victim.CharacterID, killMail.Victim.CorporationID, killMail.Victim.AllianceID, esiData)
+	for _, attacker := range killMail.Attackers {
+		ids.add(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID, esiData)
+	}
+}
+
+func (ids *EntityIDs) add(characterID, corporationID, allianceID int, esiData *model.ESIData) {
+	if characterID != 0 {
+		if _, exists := esiData.CharacterInfos[characterID]; !exists {
+			ids.Characters[characterID] = true
+		}
+	}
+	if corporationID != 0 {
+		if _, exists := esiData.CorporationInfos[corporationID]; !exists {
+			if known := ids.Corporations[corporationID]; known == 0 {
+				ids.Corporations[corporationID] = allianceID
+			}
+		}
+	}
+	if allianceID != 0 {
+		if _, exists := esiData.AllianceInfos[allianceID]; !exists {
+			ids.Alliances[allianceID] = true
+		}
+	}
+}
+
+// Empty reports whether there is nothing left to resolve.
+func (ids *EntityIDs) Empty() bool {
+	return len(ids.Characters) == 0 && len(ids.Corporations) == 0 && len(ids.Alliances) == 0
+}
+
+// ResolveEntities fills esiData with the names of ids using the bulk affiliation and names endpoints,
+// a few requests per thousand entities. Characters on the failed list are skipped, and characters ESI
+// reports as invalid are added to it. Corporations take their current alliance from character affiliations.
+// The names endpoint leaves out tickers, which are looked up for the corporations a chart shows.
+func (esi *EsiClient) ResolveEntities(ctx context.Context, ids *EntityIDs, esiData *model.ESIData) error {
+	var characterIDs []int
+	for id := range ids.Characters {
+		if !esi.Failed.Contains(id) {
+			characterIDs = append(characterIDs, id)
+		}
+	}
+
+	// Current corporation and alliance for each character
+	characterCorps := make(map[int]int, len(characterIDs))
+	affiliations, invalid, err := esi.PostCharacterAffiliation(ctx, characterIDs)
+	if err != nil {
+		return fmt.Errorf("failed to resolve character affiliations: %w", err)
+	}
+	esi.markFailed(invalid)
+	for _, affiliation := range affiliations {
+		characterCorps[affiliation.CharacterID] = affiliation.CorporationID
+		if _, exists := esiData.CorporationInfos[affiliation.CorporationID]; !exists {
+			ids.Corporations[affiliation.CorporationID] = affiliation.AllianceID
+		}
+		if affiliation.AllianceID != 0 {
+			if _, exists := esiData.AllianceInfos[affiliation.AllianceID]; !exists {
+				ids.Alliances[affiliation.AllianceID] = true
+			}
+		}
+	}
+
+	var nameIDs []int
+	for id := range characterCorps {
+		nameIDs = append(nameIDs, id)
+	}
+	for id := range ids.Corporations {
+		nameIDs = append(nameIDs, id)
+	}
+	for id := range ids.Alliances {
+		nameIDs = append(nameIDs, id)
+	}
+
+	names, invalid, err := esi.PostUniverseNames(ctx, nameIDs)
+	if err != nil {
+		return fmt.Errorf("failed to resolve names: %w", err)
+	}
+	var invalidCharacters []int
+	for _, id := range invalid {
+		if _, isCharacter := characterCorps[id]; isCharacter {
+			invalidCharacters = append(invalidCharacters, id)
+		} else {
+			esi.Logger.Warnf("ESI could not resolve a name for ID %d", id)
+		}
+	}
+	esi.markFailed(invalidCharacters)
+
+	var characters, corporations, alliances int
+	for _, name := range names {
+		switch name.Category {
+		case "character":
+			esiData.CharacterInfos[name.ID] = model.Character{Name: name.Name, CorporationID: characterCorps[name.ID]}
+			characters++
+		case "corporation":
+			esiData.CorporationInfos[name.ID] = model.Corporation{Name: name.Name, AllianceID: ids.Corporations[name.ID]}
+			corporations++
+		case "alliance":
+			esiData.AllianceInfos[name.ID] = model.Alliance{Name: name.Name}
+			alliances++
+		}
+	}
+
+	esi.Logger.Infof("Resolved %d of %d characters, %d of %d corporations and %d of %d alliances",
+		characters, len(characterCorps), corporations, len(ids.Corporations), alliances, len(ids.Alliances))
+	return nil
+}
+
+// RefreshAffiliations updates the corporation of every character in esiData, resolving any
+// corporations and alliances that are new to it.
+func (esi *EsiClient) RefreshAffiliations(ctx context.Context, esiData *model.ESIData) error {
+	var characterIDs []int
+	for id := range esiData.CharacterInfos {
+		if !esi.Failed.Contains(id) {
+			characterIDs = append(characterIDs, id)
+		}
+	}
+
+	affiliations, invalid, err := esi.PostCharacterAffiliation(ctx, characterIDs)
+	if err != nil {
+		return fmt.Errorf("failed to refresh character affiliations: %w", err)
+	}
+	esi.markFailed(invalid)
+
+	ids := NewEntityIDs()
+	for _, affiliation := range affiliations {
+		character := esiData.CharacterInfos[affiliation.CharacterID]
+		character.CorporationID = affiliation.CorporationID
+		esiData.CharacterInfos[affiliation.CharacterID] = character
+
+		if corp, exists := esiData.CorporationInfos[affiliation.CorporationID]; exists {
+			corp.AllianceID = affiliation.AllianceID
+			esiData.CorporationInfos[affiliation.CorporationID] = corp
+		}
+		ids.add(0, affiliation.CorporationID, affiliation.AllianceID, esiData)
+	}
+
+	if ids.Empty() {
+		return nil
+	}
+	return esi.ResolveEntities(ctx, ids, esiData)
+}
+
+// PostUniverseNames resolves IDs of any category to names. IDs ESI does not recognise are returned
+// separately instead of failing the batch.
+func (esi *EsiClient) PostUniverseNames(ctx context.Context, ids []int) ([]model.UniverseName, []int, error) {
+	var names []model.UniverseName
+	invalid, err := esi.postIDsInChunks(ctx, "universe/names/", ids, func(data []byte) error {
+		var chunk []model.UniverseName
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to parse universe names: %w", err)
+		}
+		names = append(names, chunk...)
+		return nil
+	})
+	return names, invalid, err
+}
+
+// PostCharacterAffiliation looks up the current corporation and alliance of each character.
+// Character IDs ESI does not recognise are returned separately instead of failing the batch.
+func (esi *EsiClient) PostCharacterAffiliation(ctx context.Context, characterIDs []int) ([]model.CharacterAffiliation, []int, error) {
+	var affiliations []model.CharacterAffiliation
+	invalid, err := esi.postIDsInChunks(ctx, "characters/affiliation/", characterIDs, func(data []byte) error {
+		var chunk []model.CharacterAffiliation
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to parse character affiliations: %w", err)
+		}
+		affiliations = append(affiliations, chunk...)
+		return nil
+	})
+	return affiliations, invalid, err
+}
+
+// PostUniverseIDs resolves exact names to the IDs of the characters, corporations and alliances bearing them.
+// Names ESI does not recognise are left out of the result.
+func (esi *EsiClient) PostUniverseIDs(ctx context.Context, names []string) (*model.UniverseIDs, error) {
+	ids := &model.UniverseIDs{}
+	for start := 0; start < len(names); start += esiNamesChunkSize {
+		end := start + esiNamesChunkSize
+		if end > len(names) {
+			end = len(names)
+		}
+		data, err := esi.postEsiJSON(ctx, "universe/ids/", names[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve names: %w", err)
+		}
+		var chunk model.UniverseIDs
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse universe IDs: %w", err)
+		}
+		ids.Characters = append(ids.Characters, chunk.Characters...)
+		ids.Corporations = append(ids.Corporations, chunk.Corporations...)
+		ids.Alliances = append(ids.Alliances, chunk.Alliances...)
+	}
+	return ids, nil
+}
+
+// postIDsInChunks posts ids to a bulk endpoint in sorted chunks, handing each response body to handle.
+func (esi *EsiClient) postIDsInChunks(ctx context.Context, endpoint string, ids []int, handle func([]byte) error) ([]int, error) {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+
+	var invalid []int
+	for start := 0; start < len(sorted); start += esiBulkChunkSize {
+		end := start + esiBulkChunkSize
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+		chunkInvalid, err := esi.postIDsBisecting(ctx, endpoint, sorted[start:end], handle)
+		if err != nil {
+			return invalid, err
+		}
+		invalid = append(invalid, chunkInvalid...)
+	}
+	return invalid, nil
+}
+
+// postIDsBisecting posts ids, and when ESI rejects the batch because an ID is invalid, splits it
+// in half until the offending IDs are isolated. The isolated IDs are returned.
+func (esi *EsiClient) postIDsBisecting(ctx context.Context, endpoint string, ids []int, handle func([]byte) error) ([]int, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	data, err := esi.postEsiIDs(ctx, endpoint, ids)
+	if err == nil {
+		return nil, handle(data)
+	}
+
+	var customErr *api.CustomError
+	if !errors.As(err, &customErr) || (customErr.StatusCode != http.StatusNotFound && customErr.StatusCode != http.StatusBadRequest) {
+		return nil, err
+	}
+	if len(ids) == 1 {
+		return ids, nil
+	}
+
+	mid := len(ids) / 2
+	invalid, err := esi.postIDsBisecting(ctx, endpoint, ids[:mid], handle)
+	if err != nil {
+		return invalid, err
+	}
+	rest, err := esi.postIDsBisecting(ctx, endpoint, ids[mid:], handle)
+	return append(invalid, rest...), err
+}
+
+// postEsiIDs posts a JSON array of IDs to endpoint and returns the response body.
+func (esi *EsiClient) postEsiIDs(ctx context.Context, endpoint string, ids []int) ([]byte, error) {
+	return esi.postEsiJSON(ctx, endpoint, ids)
+}
+
+// postEsiJSON posts body as JSON to endpoint and returns the response body.
+func (esi *EsiClient) postEsiJSON(ctx context.Context, endpoint string, body interface{}) ([]byte, error) {
+	requestURL, err := esi.buildRequestURL(endpoint, map[string]string{"datasource": "tranquility"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request URL: %w", err)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	// Define the retryable operation
+	operation := func() (interface{}, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", requestURL, bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := esi.Client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		return esi.handleResponse(resp)
+	}
+
+	result, err := api.RetryWithExponentialBackoff(ctx, operation)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]byte), nil
+}
+
+// markFailed adds characterIDs to the failed list and saves it. The list is shared by every client, so
+// it is changed and saved through its lock.
+func (esi *EsiClient) markFailed(characterIDs []int) {
+	if len(characterIDs) == 0 {
+		return
+	}
+	esi.Logger.Warnf("Adding %d characters ESI could not resolve to the failed list", len(characterIDs))
+	if err := esi.FailedStore.SaveFailedCharacters(esi.Failed.Add(characterIDs...)); err != nil {
+		esi.Logger.Errorf("Failed to save failed characters: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package esi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+const (
+	testCorporationID = 98000001
+	testAllianceID    = 99000001
+)
+
+// fakeBulkESI answers the bulk affiliation and names endpoints, rejecting batches that hold an invalid ID
+// the way ESI does, and counts the requests made to each path.
+type fakeBulkESI struct {
+	invalid map[int]bool
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (f *fakeBulkESI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests[r.Method+" "+r.URL.Path]++
+	f.mu.Unlock()
+
+	var ids []int
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&ids) != nil {
+		http.Error(w, `{"error":"unexpected request"}`, http.StatusTeapot)
+		return
+	}
+	for _, id := range ids {
+		if f.invalid[id] {
+			http.Error(w, `{"error":"Ensure all IDs are valid"}`, http.StatusNotFound)
+			return
+		}
+	}
+
+	var response interface{}
+	switch r.URL.Path {
+	case "/characters/affiliation/":
+		var affiliations []model.CharacterAffiliation
+		for _, id := range ids {
+			affiliations = append(affiliations, model.CharacterAffiliation{CharacterID: id, CorporationID: testCorporationID, AllianceID: testAllianceID})
+		}
+		response = affiliations
+	case "/universe/names/":
+		var names []model.UniverseName
+		for _, id := range ids {
+			category := "character"
+			switch id {
+			case testCorporationID:
+				category = "corporation"
+			case testAllianceID:
+				category = "alliance"
+			}
+			names = append(names, model.UniverseName{ID: id, Category: category, Name: fmt.Sprintf("%s %d", category, id)})
+		}
+		response = names
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func newTestEsiClient(server *httptest.Server, failed *model.FailedCharacters, failedStore persist.TrackedIDRepository) *EsiClient {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewEsiClient(server.URL+"/", failed, failedStore, server.Client(), nil, logger)
+}
+
+func TestResolveEntities(t *testing.T) {
+	tests := []struct {
+		name       string
+		characters []int
+		invalid    []int
+		// previouslyFailed characters are on the failed list before resolving
+		previouslyFailed []int
+		wantCharacters   []int
+		wantFailed       []int
+	}{
+		{
+			name:           "one request per endpoint",
+			characters:     []int{1, 2, 3},
+			wantCharacters: []int{1, 2, 3},
+		},
+		{
+			name:           "invalid characters are failed",
+			characters:     []int{1, 2, 3, 4},
+			invalid:        []int{3},
+			wantCharacters: []int{1, 2, 4},
+			wantFailed:     []int{3},
+		},
+		{
+			name:             "failed characters are skipped",
+			characters:       []int{1, 2},
+			previouslyFailed: []int{2},
+			wantCharacters:   []int{1},
+			wantFailed:       []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeBulkESI{invalid: make(map[int]bool), requests: make(map[string]int)}
+			for _, id := range tt.invalid {
+				fake.invalid[id] = true
+			}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			failed := &model.FailedCharacters{}
+			failed.Add(tt.previouslyFailed...)
+			failedStore := persist.NewMemoryTrackedIDRepository()
+			client := newTestEsiClient(server, failed, failedStore)
+
+			ids := NewEntityIDs()
+			for _, id := range tt.characters {
+				ids.add(id, testCorporationID, testAllianceID, &model.ESIData{})
+			}
+			esiData := &model.ESIData{
+				AllianceInfos:    make(map[int]model.Alliance),
+				CharacterInfos:   make(map[int]model.Character),
+				CorporationInfos: make(map[int]model.Corporation),
+			}
+			if err := client.ResolveEntities(context.Background(), ids, esiData); err != nil {
+				t.Fatalf("ResolveEntities: %v", err)
+			}
+
+			if len(esiData.CharacterInfos) != len(tt.wantCharacters) {
+				t.Errorf("resolved %d characters, want %d", len(esiData.CharacterInfos), len(tt.wantCharacters))
+			}
+			for _, id := range tt.wantCharacters {
+				if character := esiData.CharacterInfos[id]; character.CorporationID != testCorporationID {
+					t.Errorf("character %d = %+v, want it in corporation %d", id, character, testCorporationID)
+				}
+			}
+			if corporation := esiData.CorporationInfos[testCorporationID]; corporation.Name == "" || corporation.AllianceID != testAllianceID {
+				t.Errorf("corporation = %+v, want its name and alliance", corporation)
+			}
+			if alliance := esiData.AllianceInfos[testAllianceID]; alliance.Name == "" {
+				t.Errorf("alliance = %+v, want its name", alliance)
+			}
+
+			// Corporations and alliances are named in bulk, never looked up one at a time
+			for request, count := range fake.requests {
+				if request != "POST /characters/affiliation/" && request != "POST /universe/names/" {
+					t.Errorf("made %d requests to %s", count, request)
+				}
+			}
+			if len(tt.invalid) == 0 && (fake.requests["POST /characters/affiliation/"] != 1 || fake.requests["POST /universe/names/"] != 1) {
+				t.Errorf("requests = %v, want one per bulk endpoint", fake.requests)
+			}
+
+			saved, err := failedStore.LoadFailedCharacters()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, id := range tt.wantFailed {
+				if !failed.Contains(id) {
+					t.Errorf("character %d is not on the failed list", id)
+				}
+			}
+			for _, id := range tt.invalid {
+				if !saved.CharacterIDs[id] {
+					t.Errorf("character %d was not saved to the failed list", id)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveEntitiesConcurrently(t *testing.T) {
+	const resolvers = 8
+	fake := &fakeBulkESI{invalid: make(map[int]bool), requests: make(map[string]int)}
+	for i := 0; i < resolvers; i++ {
+		fake.invalid[1000+i] = true
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// Like the clients for each host, every resolver shares one failed list
+	failed := &model.FailedCharacters{}
+	failedStore := persist.NewMemoryTrackedIDRepository()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, resolvers)
+	for i := 0; i < resolvers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client := newTestEsiClient(server, failed, failedStore)
+			ids := NewEntityIDs()
+			esiData := &model.ESIData{
+				AllianceInfos:    make(map[int]model.Alliance),
+				CharacterInfos:   make(map[int]model.Character),
+				CorporationInfos: make(map[int]model.Corporation),
+			}
+			ids.add(i+1, testCorporationID, testAllianceID, esiData)
+			ids.add(1000+i, testCorporationID, testAllianceID, esiData)
+			errs <- client.ResolveEntities(context.Background(), ids, esiData)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("ResolveEntities: %v", err)
+		}
+	}
+	for i := 0; i < resolvers; i++ {
+		if !failed.Contains(1000 + i) {
+			t.Errorf("character %d is not on the failed list", 1000+i)
+		}
+	}
+}
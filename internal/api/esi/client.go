@@ -1,6 +1,7 @@
 package esi
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
@@ -19,19 +20,21 @@ const defaultCacheExpiration = 770 * time.Hour // slightly more than 1 month
 type EsiClient struct {
 	BaseURL     string
 	Failed      *model.FailedCharacters
+	FailedStore persist.TrackedIDRepository
 	Client      *http.Client
 	Cache       *persist.Cache
 	Logger      *logrus.Logger
 	OAuthConfig *oauth2.Config
 }
 
-func NewEsiClient(baseURL string, failed *model.FailedCharacters, client *http.Client, cache *persist.Cache, logger *logrus.Logger) *EsiClient {
+func NewEsiClient(baseURL string, failed *model.FailedCharacters, failedStore persist.TrackedIDRepository, client *http.Client, cache *persist.Cache, logger *logrus.Logger) *EsiClient {
 	return &EsiClient{
-		BaseURL: baseURL,
-		Client:  client,
-		Failed:  failed,
-		Cache:   cache,
-		Logger:  logger,
+		BaseURL:     baseURL,
+		Client:      client,
+		Failed:      failed,
+		FailedStore: failedStore,
+		Cache:       cache,
+		Logger:      logger,
 	}
 }
 
@@ -74,3 +77,46 @@ func (esi *EsiClient) generateCacheKey(endpoint string, params map[string]string
 
 	return fmt.Sprintf("esi:%s:%s", endpoint, queryParams)
 }
+
+// defaultFreshness applies to responses that carry no usable Expires header.
+const defaultFreshness = 1 * time.Hour
+
+// cachedResponse is an ESI response body stored with the headers needed to revalidate it.
+type cachedResponse struct {
+	Body    json.RawMessage `json:"body"`
+	ETag    string          `json:"etag,omitempty"`
+	Expires time.Time       `json:"expires"`
+}
+
+// loadCachedResponse reads a cached response. Entries written before headers were stored hold
+// the bare body; they are returned as already expired so they are revalidated on first use.
+func (esi *EsiClient) loadCachedResponse(cacheKey string) (*cachedResponse, bool) {
+	cachedData, found := esi.Cache.Get(cacheKey)
+	if !found {
+		return nil, false
+	}
+
+	var entry cachedResponse
+	if err := json.Unmarshal(cachedData, &entry); err == nil && len(entry.Body) > 0 {
+		return &entry, true
+	}
+	return &cachedResponse{Body: cachedData}, true
+}
+
+// storeCachedResponse keeps the response well past its expiry so its ETag can still be revalidated.
+func (esi *EsiClient) storeCachedResponse(cacheKey string, entry *cachedResponse) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		esi.Logger.Errorf("Failed to cache response for key %s: %v", cacheKey, err)
+		return
+	}
+	esi.Cache.Set(cacheKey, data, defaultCacheExpiration)
+}
+
+// responseExpiry returns when a response stops being fresh according to its Expires header.
+func responseExpiry(header http.Header) time.Time {
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		return expires
+	}
+	return time.Now().Add(defaultFreshness)
+}
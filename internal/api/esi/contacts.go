@@ -25,7 +25,6 @@ func (es *EsiClient) AddContacts(characterID int64, token *oauth2.Token, contact
 	params := url.Values{}
 	params.Set("standing", strconv.FormatFloat(5.0, 'f', 1, 64))
 
-	client := &http.Client{}
 	req, err := http.NewRequest("POST", baseURL+"?"+params.Encode(), bytes.NewBuffer(contactIDsJSON))
 	if err != nil {
 		xlog.Logf("Error creating request: %v", err)
@@ -39,7 +38,7 @@ func (es *EsiClient) AddContacts(characterID int64, token *oauth2.Token, contact
 	req.Header.Set("Cache-Control", "no-cache")
 
 	// Execute the request
-	resp, err := client.Do(req)
+	resp, err := es.Client.Do(req)
 	if err != nil {
 		xlog.Logf("Error executing request: %v", err)
 		return fmt.Errorf("error executing request: %w", err)
@@ -80,7 +79,6 @@ func (es *EsiClient) DeleteContacts(characterID int64, token *oauth2.Token, cont
 	}
 	params.Set("datasource", "tranquility")
 
-	client := &http.Client{}
 	req, err := http.NewRequest("DELETE", baseURL+"?"+params.Encode(), bytes.NewBuffer(contactIDsJSON))
 	if err != nil {
 		xlog.Logf("Error creating request: %v", err)
@@ -94,7 +92,7 @@ func (es *EsiClient) DeleteContacts(characterID int64, token *oauth2.Token, cont
 	req.Header.Set("Cache-Control", "no-cache")
 
 	// Execute the request
-	resp, err := client.Do(req)
+	resp, err := es.Client.Do(req)
 	if err != nil {
 		xlog.Logf("Error executing request: %v", err)
 		return fmt.Errorf("error executing request: %w", err)
@@ -3,18 +3,20 @@ package esi
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"golang.org/x/oauth2"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/guarzo/zkillanalytics/internal/api"
 	"github.com/guarzo/zkillanalytics/internal/xlog"
 )
 
 // buildRequest constructs an HTTP GET request, optionally with query parameters and token.
-func (esi *EsiClient) buildRequest(baseURL string, token *oauth2.Token, params ...map[string]string) (*http.Request, error) {
+func (esi *EsiClient) buildRequest(ctx context.Context, baseURL string, token *oauth2.Token, params ...map[string]string) (*http.Request, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse base URL: %v", err)
@@ -34,7 +36,7 @@ func (esi *EsiClient) buildRequest(baseURL string, token *oauth2.Token, params .
 	u.RawQuery = q.Encode()
 
 	// Create the request
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
@@ -48,14 +50,14 @@ func (esi *EsiClient) buildRequest(baseURL string, token *oauth2.Token, params .
 }
 
 // getEsiEntityWithToken retrieves entity data from ESI, using an OAuth token if provided, and caches the response.
-func (esi *EsiClient) getEsiEntityWithTokenNoCache(address string, token *oauth2.Token, params ...map[string]string) ([]byte, error) {
+func (esi *EsiClient) getEsiEntityWithTokenNoCache(ctx context.Context, address string, token *oauth2.Token, params ...map[string]string) ([]byte, error) {
 	// If params are not provided, initialize with an empty map to prevent out-of-range issues.
 	queryParams := make(map[string]string)
 	if len(params) > 0 && params[0] != nil {
 		queryParams = params[0]
 	}
 
-	req, err := esi.buildRequest(address, token, queryParams)
+	req, err := esi.buildRequest(ctx, address, token, queryParams)
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +76,9 @@ func (esi *EsiClient) getEsiEntityWithTokenNoCache(address string, token *oauth2
 	return data, nil
 }
 
-func (esi *EsiClient) getEsiEntityWithToken(address string, token *oauth2.Token, params ...map[string]string) ([]byte, error) {
+// getEsiEntityWithToken retrieves entity data from ESI, using an OAuth token if provided. Like public
+// entities, the response is reused until ESI's Expires header passes and then revalidated with If-None-Match.
+func (esi *EsiClient) getEsiEntityWithToken(ctx context.Context, address string, token *oauth2.Token, params ...map[string]string) ([]byte, error) {
 	// If params are not provided, initialize with an empty map to prevent out-of-range issues.
 	queryParams := make(map[string]string)
 	if len(params) > 0 && params[0] != nil {
@@ -83,16 +87,41 @@ func (esi *EsiClient) getEsiEntityWithToken(address string, token *oauth2.Token,
 		xlog.LogIndirect(fmt.Sprintf("No query parameters provided for cache ESI request, %s", address))
 	}
 
-	cacheKey := esi.generateCacheKey(address, queryParams)
-	if cachedData, found := esi.Cache.Get(cacheKey); found {
-		return cachedData, nil
+	return esi.getRevalidated(ctx, address, esi.generateCacheKey(address, queryParams), false, func() (*http.Request, error) {
+		return esi.buildRequest(ctx, address, token, queryParams)
+	})
+}
+
+// getEsiEntity retrieves an entity, reusing the cached copy until ESI's Expires header passes
+// and then revalidating it with If-None-Match.
+func (esi *EsiClient) getEsiEntity(ctx context.Context, endpoint string, entity interface{}) error {
+	return esi.getCachedEsiEntity(ctx, endpoint, entity, false)
+}
+
+// getImmutableEsiEntity retrieves an entity that never changes once published, such as a killmail,
+// so any cached copy is used without revalidation.
+func (esi *EsiClient) getImmutableEsiEntity(ctx context.Context, endpoint string, entity interface{}) error {
+	return esi.getCachedEsiEntity(ctx, endpoint, entity, true)
+}
+
+// getUncachedEsiEntity retrieves an immutable entity, reading any cached copy but leaving the cache untouched.
+func (esi *EsiClient) getUncachedEsiEntity(ctx context.Context, endpoint string, entity interface{}) error {
+	params := map[string]string{"datasource": "tranquility"}
+	if cached, found := esi.loadCachedResponse(esi.generateCacheKey(endpoint, params)); found {
+		if err := json.Unmarshal(cached.Body, entity); err == nil {
+			return nil
+		}
 	}
 
-	// Define the retryable operation
-	operation := func() (interface{}, error) {
-		req, err := esi.buildRequest(address, token, queryParams)
+	requestURL, err := esi.buildRequestURL(endpoint, params)
+	if err != nil {
+		return fmt.Errorf("failed to build request URL: %w", err)
+	}
+
+	result, err := api.RetryWithExponentialBackoff(ctx, func() (interface{}, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to create request: %v", err)
 		}
 
 		resp, err := esi.Client.Do(req)
@@ -101,45 +130,58 @@ func (esi *EsiClient) getEsiEntityWithToken(address string, token *oauth2.Token,
 		}
 		defer resp.Body.Close()
 
-		data, err := esi.handleResponse(resp)
-		if err != nil {
-			return nil, err
-		}
-
-		// Cache the response data with the default expiration.
-		esi.Cache.Set(cacheKey, data, defaultCacheExpiration)
-		return data, nil
-	}
-
-	// Call the retry function
-	result, err := api.RetryWithExponentialBackoff(operation)
+		return esi.handleResponse(resp)
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return result.([]byte), nil
+	return json.Unmarshal(result.([]byte), entity)
 }
 
-func (esi *EsiClient) getEsiEntity(ctx context.Context, endpoint string, entity interface{}) error {
+func (esi *EsiClient) getCachedEsiEntity(ctx context.Context, endpoint string, entity interface{}, immutable bool) error {
 	params := map[string]string{"datasource": "tranquility"}
-	cacheKey := esi.generateCacheKey(endpoint, params)
-	if cachedData, found := esi.Cache.Get(cacheKey); found {
-		if err := json.Unmarshal(cachedData, entity); err == nil {
-			return nil
-		}
-	}
-
 	requestURL, err := esi.buildRequestURL(endpoint, params)
 	if err != nil {
 		return fmt.Errorf("failed to build request URL: %w", err)
 	}
 
-	// Define the retryable operation
-	operation := func() (interface{}, error) {
+	data, err := esi.getRevalidated(ctx, endpoint, esi.generateCacheKey(endpoint, params), immutable, func() (*http.Request, error) {
 		req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %v", err)
 		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the response into the provided entity
+	return json.Unmarshal(data, entity)
+}
+
+// getRevalidated returns the response body cached under cacheKey while it is fresh, or always if the
+// response is immutable. Otherwise it sends the request newRequest builds, revalidating any cached copy
+// with its ETag, and caches the result with its Expires time.
+func (esi *EsiClient) getRevalidated(ctx context.Context, endpoint, cacheKey string, immutable bool, newRequest func() (*http.Request, error)) ([]byte, error) {
+	cached, hasCached := esi.loadCachedResponse(cacheKey)
+	if hasCached && (immutable || time.Now().Before(cached.Expires)) {
+		if json.Valid(cached.Body) {
+			return cached.Body, nil
+		}
+		hasCached = false
+	}
+
+	// Define the retryable operation
+	operation := func() (interface{}, error) {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		if hasCached && cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
 
 		resp, err := esi.Client.Do(req)
 		if err != nil {
@@ -147,31 +189,53 @@ func (esi *EsiClient) getEsiEntity(ctx context.Context, endpoint string, entity
 		}
 		defer resp.Body.Close()
 
+		// Unchanged since the cached copy; keep its body and extend its freshness.
+		if resp.StatusCode == http.StatusNotModified && hasCached {
+			cached.Expires = responseExpiry(resp.Header)
+			esi.storeCachedResponse(cacheKey, cached)
+			return []byte(cached.Body), nil
+		}
+
 		data, err := esi.handleResponse(resp)
 		if err != nil {
 			return nil, err
 		}
 
-		// Cache the result.
-		esi.Cache.Set(cacheKey, data, defaultCacheExpiration)
+		// Cache the result with the headers needed to revalidate it.
+		esi.storeCachedResponse(cacheKey, &cachedResponse{
+			Body:    data,
+			ETag:    resp.Header.Get("ETag"),
+			Expires: responseExpiry(resp.Header),
+		})
 		return data, nil
 	}
 
 	// Call the retry function
-	result, err := api.RetryWithExponentialBackoff(operation)
+	result, err := api.RetryWithExponentialBackoff(ctx, operation)
 	if err != nil {
-		return err
+		// A stale copy is better than nothing unless the entity is gone or the token no longer grants access.
+		var customErr *api.CustomError
+		if hasCached && ctx.Err() == nil && !(errors.As(err, &customErr) && refusedStatusCodes[customErr.StatusCode]) {
+			esi.Logger.Warnf("Revalidation of %s failed, using cached copy: %v", endpoint, err)
+			return cached.Body, nil
+		}
+		return nil, err
 	}
+	return result.([]byte), nil
+}
 
-	// Unmarshal the response into the provided entity
-	return json.Unmarshal(result.([]byte), entity)
+// refusedStatusCodes are the failures for which a stale cached copy must not be served.
+var refusedStatusCodes = map[int]bool{
+	http.StatusNotFound:     true,
+	http.StatusUnauthorized: true,
+	http.StatusForbidden:    true,
 }
 
 // handleResponse processes the HTTP response and returns the response body or an error.
 func (esi *EsiClient) handleResponse(resp *http.Response) ([]byte, error) {
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("non-OK HTTP status: %s, body: %s", resp.Status, string(bodyBytes))
+		return nil, api.NewCustomError(resp.StatusCode, fmt.Sprintf("non-OK HTTP status: %s, body: %s", resp.Status, string(bodyBytes)))
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
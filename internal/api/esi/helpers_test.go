@@ -0,0 +1,105 @@
+package esi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+func TestGetEsiEntityWithTokenRevalidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		expires time.Duration
+		// changed serves a new body and ETag to the second request
+		changed bool
+		// wantRequests are the requests ESI sees, each shown by the If-None-Match it carried
+		wantRequests []string
+		wantBody     string
+	}{
+		{name: "fresh copy reused", expires: time.Hour, wantRequests: []string{""}, wantBody: `{"v":1}`},
+		{name: "expired copy revalidated", expires: -time.Hour, wantRequests: []string{"", `"v1"`}, wantBody: `{"v":1}`},
+		{name: "expired copy replaced", expires: -time.Hour, changed: true, wantRequests: []string{"", `"v1"`}, wantBody: `{"v":2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(wd)
+
+			var mu sync.Mutex
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer access" {
+					t.Errorf("request without the token: %q", r.Header.Get("Authorization"))
+				}
+				mu.Lock()
+				requests = append(requests, r.Header.Get("If-None-Match"))
+				second := len(requests) > 1
+				mu.Unlock()
+
+				w.Header().Set("Expires", time.Now().Add(tt.expires).UTC().Format(http.TimeFormat))
+				switch {
+				case second && tt.changed:
+					w.Header().Set("ETag", `"v2"`)
+					io.WriteString(w, `{"v":2}`)
+				case second && r.Header.Get("If-None-Match") == `"v1"`:
+					w.WriteHeader(http.StatusNotModified)
+				default:
+					w.Header().Set("ETag", `"v1"`)
+					io.WriteString(w, `{"v":1}`)
+				}
+			}))
+			defer server.Close()
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			cache, err := persist.OpenCache(filepath.Join(dir, "cache.db"), 0, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cache.Close()
+			client := NewEsiClient(server.URL+"/", nil, nil, server.Client(), cache, logger)
+
+			token := &oauth2.Token{AccessToken: "access"}
+			var body []byte
+			for i := 0; i < 2; i++ {
+				body, err = client.getEsiEntityWithToken(context.Background(), server.URL+"/characters/1/", token, map[string]string{"datasource": "tranquility"})
+				if err != nil {
+					t.Fatalf("request %d: %v", i+1, err)
+				}
+			}
+
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(requests) != len(tt.wantRequests) {
+				t.Fatalf("ESI saw %d requests (%q), want %d", len(requests), requests, len(tt.wantRequests))
+			}
+			for i, want := range tt.wantRequests {
+				if requests[i] != want {
+					t.Errorf("request %d sent If-None-Match %q, want %q", i+1, requests[i], want)
+				}
+			}
+		})
+	}
+}
@@ -5,23 +5,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/guarzo/zkillanalytics/internal/model"
-	"github.com/guarzo/zkillanalytics/internal/persist"
 	"golang.org/x/oauth2"
 	"strings"
 )
 
 // CharacterIDSearch searches for a character ID by name using a character ID context.
-func (esi *EsiClient) CharacterIDSearch(characterID int64, name string, token *oauth2.Token) (int32, error) {
-	return esi.IDSearch(characterID, name, "character", token)
+func (esi *EsiClient) CharacterIDSearch(ctx context.Context, characterID int64, name string, token *oauth2.Token) (int32, error) {
+	return esi.IDSearch(ctx, characterID, name, "character", token)
 }
 
 // CorporationIDSearch searches for a corporation ID by name using a character ID context.
-func (esi *EsiClient) CorporationIDSearch(characterID int64, name string, token *oauth2.Token) (int32, error) {
-	return esi.IDSearch(characterID, name, "corporation", token)
+func (esi *EsiClient) CorporationIDSearch(ctx context.Context, characterID int64, name string, token *oauth2.Token) (int32, error) {
+	return esi.IDSearch(ctx, characterID, name, "corporation", token)
 }
 
 // IDSearch performs the search logic for Character and Corporation IDs by category.
-func (esi *EsiClient) IDSearch(characterID int64, name, category string, token *oauth2.Token) (int32, error) {
+func (esi *EsiClient) IDSearch(ctx context.Context, characterID int64, name, category string, token *oauth2.Token) (int32, error) {
 	// Build the search endpoint using the characterID context.
 	baseURL := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/search/", characterID)
 	params := map[string]string{
@@ -33,7 +32,7 @@ func (esi *EsiClient) IDSearch(characterID int64, name, category string, token *
 		"token":      token.AccessToken,
 	}
 
-	esi.Logger.Infof("Searching for %s ID by name: %s, character ID %s", category, name, characterID)
+	esi.Logger.Infof("Searching for %s ID by name: %s, character ID %d", category, name, characterID)
 
 	// Generate cache key to prevent unnecessary API calls.
 	cacheKey := esi.generateCacheKey(baseURL, params)
@@ -48,7 +47,7 @@ func (esi *EsiClient) IDSearch(characterID int64, name, category string, token *
 
 	esi.Logger.Infof("Searching for %s ID by name: %s, character ID %d, baseURL %s", category, name, characterID, baseURL)
 	// Execute the request and handle response.
-	bodyBytes, err := esi.getEsiEntityWithToken(baseURL, token, params)
+	bodyBytes, err := esi.getEsiEntityWithToken(ctx, baseURL, token, params)
 	if err != nil {
 		return 0, err
 	}
@@ -71,7 +70,7 @@ func (esi *EsiClient) IDSearch(characterID int64, name, category string, token *
 	if len(ids) > 1 {
 		found := false
 		for _, id := range ids {
-			data, err := esi.GetPublicCharacterData(int64(id), token)
+			data, err := esi.GetPublicCharacterData(ctx, int64(id), token)
 			if err != nil {
 				continue
 			}
@@ -90,14 +89,14 @@ func (esi *EsiClient) IDSearch(characterID int64, name, category string, token *
 }
 
 // GetPublicCharacterData fetches public character data.
-func (esi *EsiClient) GetPublicCharacterData(characterID int64, token *oauth2.Token) (*model.CharacterResponse, error) {
-	return esi.GetCharacterData(characterID, token)
+func (esi *EsiClient) GetPublicCharacterData(ctx context.Context, characterID int64, token *oauth2.Token) (*model.CharacterResponse, error) {
+	return esi.GetCharacterData(ctx, characterID, token)
 }
 
 // GetCharacterData retrieves detailed character data from the ESI API.
-func (esi *EsiClient) GetCharacterData(characterID int64, token *oauth2.Token) (*model.CharacterResponse, error) {
+func (esi *EsiClient) GetCharacterData(ctx context.Context, characterID int64, token *oauth2.Token) (*model.CharacterResponse, error) {
 	url := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/?datasource=tranquility", characterID)
-	data, err := esi.getEsiEntityWithToken(url, token)
+	data, err := esi.getEsiEntityWithToken(ctx, url, token)
 	if err != nil {
 		return nil, err
 	}
@@ -111,8 +110,8 @@ func (esi *EsiClient) GetCharacterData(characterID int64, token *oauth2.Token) (
 }
 
 // GetCharacterCorporation retrieves the corporation ID for a character.
-func (esi *EsiClient) GetCharacterCorporation(characterID int64, token *oauth2.Token) (int32, error) {
-	data, err := esi.GetCharacterData(characterID, token)
+func (esi *EsiClient) GetCharacterCorporation(ctx context.Context, characterID int64, token *oauth2.Token) (int32, error) {
+	data, err := esi.GetCharacterData(ctx, characterID, token)
 	if err != nil {
 		return 0, err
 	}
@@ -123,7 +122,7 @@ func (esi *EsiClient) GetCharacterCorporation(characterID int64, token *oauth2.T
 // GetCharacterInfo retrieves character information from the ESI API, handling 404 errors by adding the character ID to a failed list.
 func (esi *EsiClient) GetCharacterInfo(ctx context.Context, characterID int) (*model.Character, error) {
 	// Check if character ID is already in the failed list
-	if _, exists := esi.Failed.CharacterIDs[characterID]; exists {
+	if esi.Failed.Contains(characterID) {
 		esi.Logger.Warnf("Skipping character %d as it previously failed with a 404", characterID)
 		return nil, &model.NotFoundError{CharacterID: characterID}
 	}
@@ -139,10 +138,8 @@ func (esi *EsiClient) GetCharacterInfo(ctx context.Context, characterID int) (*m
 		// Handle 404 error specifically
 		if strings.Contains(err.Error(), "404") {
 			esi.Logger.Warnf("Character %d not found, adding to failed list", characterID)
-			esi.Failed.CharacterIDs[characterID] = true
-
 			// Save the updated failed characters list
-			if saveErr := persist.SaveFailedCharacters(esi.Failed); saveErr != nil {
+			if saveErr := esi.FailedStore.SaveFailedCharacters(esi.Failed.Add(characterID)); saveErr != nil {
 				esi.Logger.Errorf("Failed to save failed character ID %d: %v", characterID, saveErr)
 			}
 			return nil, &model.NotFoundError{CharacterID: characterID}
@@ -156,7 +153,7 @@ func (esi *EsiClient) GetCharacterInfo(ctx context.Context, characterID int) (*m
 	return &character, nil
 }
 
-func (esi *EsiClient) GetCharacterPortrait(characterID int64) (string, error) {
+func (esi *EsiClient) GetCharacterPortrait(ctx context.Context, characterID int64) (string, error) {
 	url := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/portrait/?datasource=tranquility", characterID)
 	cacheKey := fmt.Sprintf("portrait:%d", characterID)
 
@@ -167,7 +164,7 @@ func (esi *EsiClient) GetCharacterPortrait(characterID int64) (string, error) {
 		}
 	}
 
-	data, err := esi.getEsiEntityWithToken(url, nil)
+	data, err := esi.getEsiEntityWithToken(ctx, url, nil)
 	if err != nil {
 		return "", err
 	}
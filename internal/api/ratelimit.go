@@ -0,0 +1,208 @@
+// internal/api/ratelimit.go
+
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	esiErrorLimitRemainHeader = "X-ESI-Error-Limit-Remain"
+	esiErrorLimitResetHeader  = "X-ESI-Error-Limit-Reset"
+
+	// errorLimitThreshold pauses a host once this few errors remain in its window.
+	errorLimitThreshold = 10
+	// defaultRetryAfter is used for 420/429 responses that do not say how long to wait.
+	defaultRetryAfter = 60 * time.Second
+	// StatusEnhanceYourCalm is ESI's error-limited status code.
+	StatusEnhanceYourCalm = 420
+)
+
+// RateLimitedTransport is an http.RoundTripper shared by every outbound client. It tracks each host's
+// ESI error budget and Retry-After responses, holding all requests to a host until its pause expires.
+// Waiting happens before the per-attempt timeout starts and ends as soon as the request context is cancelled.
+type RateLimitedTransport struct {
+	Wrapped        http.RoundTripper
+	RequestTimeout time.Duration
+	Logger         *logrus.Logger
+
+	mu          sync.Mutex
+	pausedUntil map[string]time.Time
+}
+
+// NewRateLimitedTransport wraps a transport with per-host rate limiting.
+// A positive requestTimeout bounds each attempt, excluding time spent paused.
+func NewRateLimitedTransport(wrapped http.RoundTripper, requestTimeout time.Duration, logger *logrus.Logger) *RateLimitedTransport {
+	if wrapped == nil {
+		wrapped = http.DefaultTransport
+	}
+	return &RateLimitedTransport{
+		Wrapped:        wrapped,
+		RequestTimeout: requestTimeout,
+		Logger:         logger,
+		pausedUntil:    make(map[string]time.Time),
+	}
+}
+
+// NewRateLimitedClient returns a client sending requests through a rate-limited copy of client's transport.
+// The client's timeout becomes the per-attempt timeout so that pauses do not count against it.
+func NewRateLimitedClient(client *http.Client, logger *logrus.Logger) *http.Client {
+	return &http.Client{
+		Transport:     NewRateLimitedTransport(client.Transport, client.Timeout, logger),
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+	}
+}
+
+// RoundTrip implements the RoundTripper interface.
+func (t *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.wait(req.Context(), host); err != nil {
+		return nil, err
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if t.RequestTimeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), t.RequestTimeout)
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := t.Wrapped.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+
+	t.observe(host, resp)
+	return resp, nil
+}
+
+// PausedUntil reports when requests to host may resume; the zero time means it is not paused.
+func (t *RateLimitedTransport) PausedUntil(host string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pausedUntil[host]
+}
+
+// wait blocks until host is no longer paused or ctx is done.
+func (t *RateLimitedTransport) wait(ctx context.Context, host string) error {
+	for {
+		until := t.PausedUntil(host)
+		delay := time.Until(until)
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// observe updates the host's pause from the response status and ESI error-limit headers.
+func (t *RateLimitedTransport) observe(host string, resp *http.Response) {
+	now := time.Now()
+
+	switch resp.StatusCode {
+	case StatusEnhanceYourCalm, http.StatusTooManyRequests:
+		delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+		if !ok {
+			delay, ok = parseSeconds(resp.Header.Get(esiErrorLimitResetHeader))
+		}
+		if !ok {
+			delay = defaultRetryAfter
+		}
+		t.pause(host, now.Add(delay), resp.StatusCode)
+		return
+	case http.StatusServiceUnavailable:
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			t.pause(host, now.Add(delay), resp.StatusCode)
+			return
+		}
+	}
+
+	remain, ok := parseInt(resp.Header.Get(esiErrorLimitRemainHeader))
+	if !ok || remain > errorLimitThreshold {
+		return
+	}
+	reset, ok := parseSeconds(resp.Header.Get(esiErrorLimitResetHeader))
+	if !ok {
+		return
+	}
+	t.pause(host, now.Add(reset), resp.StatusCode)
+}
+
+// pause extends the host's pause to until, never shortening an existing one.
+func (t *RateLimitedTransport) pause(host string, until time.Time, statusCode int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until.After(t.pausedUntil[host]) {
+		t.pausedUntil[host] = until
+		if t.Logger != nil {
+			t.Logger.Warnf("Pausing requests to %s for %v (status %d)", host, time.Until(until).Round(time.Second), statusCode)
+		}
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if delay, ok := parseSeconds(value); ok {
+		return delay, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+func parseSeconds(value string) (time.Duration, bool) {
+	seconds, ok := parseInt(value)
+	if !ok || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func parseInt(value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// cancelOnClose releases the per-attempt context once the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -37,12 +37,12 @@ func NewZkillClient(baseURL string, client *http.Client, cache *persist.Cache, l
 	}
 }
 
-func (zk *ZkillClient) getZkillData(ctx context.Context, cacheKey, requestURL string) ([]model.KillMail, error) {
+func (zk *ZkillClient) getZkillData(ctx context.Context, cacheKey, requestURL string, useCache bool) ([]model.KillMail, error) {
 	// Check if this is the current month to avoid caching
 	currentYear, currentMonth, _ := time.Now().Date()
 	isCurrentMonth := strings.Contains(cacheKey, fmt.Sprintf(":%d:%02d:", currentYear, currentMonth))
 
-	if !isCurrentMonth {
+	if useCache && !isCurrentMonth {
 		// Check if the data is in cache
 		cachedData, found := zk.Cache.Get(cacheKey)
 		if found {
@@ -76,7 +76,7 @@ func (zk *ZkillClient) getZkillData(ctx context.Context, cacheKey, requestURL st
 }
 
 // fetchPageData is a helper method to fetch killmails from a specific API endpoint.
-func (zk *ZkillClient) fetchPageData(ctx context.Context, apiType, entityType string, entityID, page, year, month int) ([]model.KillMail, error) {
+func (zk *ZkillClient) fetchPageData(ctx context.Context, apiType, entityType string, entityID, page, year, month int, useCache bool) ([]model.KillMail, error) {
 	// Construct the request URL based on the apiType and generate a cache key.
 	requestURL := fmt.Sprintf("%s/api/%s/%sID/%d/year/%d/month/%d/page/%d/",
 		zk.BaseURL, apiType, entityType, entityID, year, month, page)
@@ -84,15 +84,26 @@ func (zk *ZkillClient) fetchPageData(ctx context.Context, apiType, entityType st
 	cacheKey := fmt.Sprintf("zkill:%s:%sID:%d:%d:%02d:%d", apiType, entityType, entityID, year, month, page)
 
 	zk.Logger.Debugf("Fetching %s from URL: %s", apiType, requestURL)
-	return zk.getZkillData(ctx, cacheKey, requestURL)
+	return zk.getZkillData(ctx, cacheKey, requestURL, useCache)
 }
 
 // GetKillsPageData fetches killmails where entities are attackers.
 func (zk *ZkillClient) GetKillsPageData(ctx context.Context, entityType string, entityID, page, year, month int) ([]model.KillMail, error) {
-	return zk.fetchPageData(ctx, "kills", entityType, entityID, page, year, month)
+	return zk.fetchPageData(ctx, "kills", entityType, entityID, page, year, month, true)
 }
 
 // GetLossPageData fetches killmails where entities are victims (losses).
 func (zk *ZkillClient) GetLossPageData(ctx context.Context, entityType string, entityID, page, year, month int) ([]model.KillMail, error) {
-	return zk.fetchPageData(ctx, "losses", entityType, entityID, page, year, month)
+	return zk.fetchPageData(ctx, "losses", entityType, entityID, page, year, month, true)
+}
+
+// GetKillsPageDataNoCache fetches killmails where entities are attackers, always going to zKillboard.
+// The fresh page still replaces any cached copy for past months.
+func (zk *ZkillClient) GetKillsPageDataNoCache(ctx context.Context, entityType string, entityID, page, year, month int) ([]model.KillMail, error) {
+	return zk.fetchPageData(ctx, "kills", entityType, entityID, page, year, month, false)
+}
+
+// GetLossPageDataNoCache fetches killmails where entities are victims, always going to zKillboard.
+func (zk *ZkillClient) GetLossPageDataNoCache(ctx context.Context, entityType string, entityID, page, year, month int) ([]model.KillMail, error) {
+	return zk.fetchPageData(ctx, "losses", entityType, entityID, page, year, month, false)
 }
@@ -0,0 +1,79 @@
+// internal/api/zkill/history.go
+
+package zkill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/guarzo/zkillanalytics/internal/api"
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// HistoryFileName returns the name zKillboard gives a day's history file, such as 20240131.json.
+func HistoryFileName(day time.Time) string {
+	return day.Format("20060102") + ".json"
+}
+
+// ParseHistory decodes a zKillboard history file, a JSON object mapping killmail IDs to hashes.
+// The killmails are returned in ascending ID order with only their ID and hash set.
+func ParseHistory(r io.Reader) ([]model.KillMail, error) {
+	var hashes map[string]string
+	if err := json.NewDecoder(r).Decode(&hashes); err != nil {
+		return nil, fmt.Errorf("failed to decode history: %w", err)
+	}
+
+	killMails := make([]model.KillMail, 0, len(hashes))
+	for key, hash := range hashes {
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid killmail ID %q in history: %w", key, err)
+		}
+		killMails = append(killMails, model.KillMail{KillMailID: id, ZKB: model.ZKB{Hash: hash}})
+	}
+
+	sort.Slice(killMails, func(i, j int) bool {
+		return killMails[i].KillMailID < killMails[j].KillMailID
+	})
+	return killMails, nil
+}
+
+// GetHistory downloads the history file for day. Days zKillboard has no file for yield no killmails.
+func (zk *ZkillClient) GetHistory(ctx context.Context, day time.Time) ([]model.KillMail, error) {
+	requestURL := zk.BaseURL + config.ZkillHistoryPath + HistoryFileName(day)
+	zk.Logger.Debugf("Fetching history from URL: %s", requestURL)
+
+	result, err := api.RetryWithExponentialBackoff(ctx, func() (interface{}, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := zk.Client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch history: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return []model.KillMail{}, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, api.NewCustomError(resp.StatusCode, fmt.Sprintf("unexpected status code: %d", resp.StatusCode))
+		}
+
+		return ParseHistory(resp.Body)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]model.KillMail), nil
+}
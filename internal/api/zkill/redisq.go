@@ -0,0 +1,96 @@
+// internal/api/zkill/redisq.go
+
+package zkill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guarzo/zkillanalytics/internal/api"
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// redisQTimeToWait is how long, in seconds, the listen endpoint may hold a request open waiting for a killmail.
+// It stays below the shared client's per-request timeout.
+const redisQTimeToWait = 5
+
+// RedisQPackage is a single killmail delivered by a RedisQ listen endpoint.
+// KillMail is only populated by feeds that embed the ESI killmail; otherwise
+// it must be hydrated from ESI using the zkb hash.
+type RedisQPackage struct {
+	KillID   int64              `json:"killID"`
+	KillMail *model.EsiKillMail `json:"killmail"`
+	ZKB      model.ZKB          `json:"zkb"`
+}
+
+type redisQResponse struct {
+	Package *RedisQPackage `json:"package"`
+}
+
+// RedisQClient long-polls a zKillboard RedisQ-style listen endpoint.
+type RedisQClient struct {
+	URL     string
+	QueueID string
+	Client  *http.Client
+	Logger  *logrus.Logger
+}
+
+// NewRedisQClient initializes and returns a new RedisQClient.
+func NewRedisQClient(listenURL, queueID string, client *http.Client, logger *logrus.Logger) *RedisQClient {
+	return &RedisQClient{
+		URL:     listenURL,
+		QueueID: queueID,
+		Client:  client,
+		Logger:  logger,
+	}
+}
+
+// Listen waits for the next killmail on the queue. It returns a nil package when
+// the wait elapsed without any killmail being published.
+func (rq *RedisQClient) Listen(ctx context.Context) (*RedisQPackage, error) {
+	u, err := url.Parse(rq.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RedisQ URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("queueID", rq.QueueID)
+	query.Set("ttw", strconv.Itoa(redisQTimeToWait))
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := rq.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch RedisQ package: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, api.NewCustomError(resp.StatusCode, string(body))
+	}
+
+	var result redisQResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RedisQ package: %w", err)
+	}
+
+	if result.Package != nil {
+		rq.Logger.Debugf("Received killmail %d from RedisQ", result.Package.KillID)
+	}
+	return result.Package, nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 
@@ -17,6 +18,19 @@ type AppSetup struct {
 	Version    string
 	Key        []byte
 	Secret     string
+
+	RedisQURL      string
+	RedisQQueueID  string
+	EsiConcurrency int
+	SDEDir         string
+	CacheMaxBytes  int64
+	BackupsKept    int
+	PreviousKeys   [][]byte
+	TrackedFile    string
+	AdminIDs       []int64
+	APITokens      []string
+
+	RangeEarliestDate time.Time
 }
 
 // NewAppSetup initializes and returns a Config struct with values from environment variables
@@ -49,6 +63,8 @@ func NewAppSetup() (*AppSetup, error) {
 	// Handle SECRET_KEY, generate if missing
 	secret, key := utils.GetSecretKey()
 
+	redisQURL, redisQQueueID := utils.GetRedisQConfig(RedisQURL)
+
 	return &AppSetup{
 		Port:       port,
 		UserAgent:  userAgent,
@@ -56,5 +72,18 @@ func NewAppSetup() (*AppSetup, error) {
 		Version:    version,
 		Key:        key,
 		Secret:     secret,
+
+		RedisQURL:      redisQURL,
+		RedisQQueueID:  redisQQueueID,
+		EsiConcurrency: utils.GetEsiConcurrency(),
+		SDEDir:         utils.GetSDEDir(),
+		CacheMaxBytes:  utils.GetCacheMaxBytes(),
+		BackupsKept:    utils.GetBackupsKept(),
+		PreviousKeys:   utils.GetPreviousSecretKeys(),
+		TrackedFile:    utils.GetTrackedFile(),
+		AdminIDs:       utils.GetAdminCharacterIDs(),
+		APITokens:      utils.GetAPITokens(),
+
+		RangeEarliestDate: utils.GetRangeEarliestDate(),
 	}, nil
 }
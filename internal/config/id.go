@@ -1,10 +1,13 @@
 package config
 
-var CorporationIDs = []int{98648442, 98730557, 98763685, 98743419, 98670318}
+// DefaultTracked is the tracked set written to the tracked entities file when it does not exist yet.
+var DefaultTracked = TrackedEntities{
+	Corporations: []int{98648442, 98730557, 98763685, 98743419, 98670318},
 
-var CharacterIDs = []int{1959376155, 2121524689, 96180548, 2118868995, 2118016167, 2114311509, 537223062, 2115754172, 629507683, 640170087, 2119887294, 1406208348, 1872552403, 2112148425, 404850015, 92063989, 96066721, 2114591694, 2115648488, 2116275733}
+	Characters: []int{1959376155, 2121524689, 96180548, 2118868995, 2118016167, 2114311509, 537223062, 2115754172, 629507683, 640170087, 2119887294, 1406208348, 1872552403, 2112148425, 404850015, 92063989, 96066721, 2114591694, 2115648488, 2116275733},
 
-// AllianceIDs are the IDs of the alliances
-var AllianceIDs = []int{99010452}
+	// Alliances are the IDs of the alliances
+	Alliances: []int{99010452},
 
-var ExcludeCharacters = []int{2116875456, 2120850653, 2121334187, 2121355778}
+	ExcludeCharacters: []int{2116875456, 2120850653, 2121334187, 2121355778},
+}
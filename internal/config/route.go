@@ -29,10 +29,12 @@ const (
 	All Route = iota
 	Config
 	Snippets
+	Range
 )
 
 var RouteToString = map[Route]string{
 	All:      "All",
 	Config:   "Config",
 	Snippets: "Snippets",
+	Range:    "Range",
 }
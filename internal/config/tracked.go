@@ -1,42 +1,215 @@
 package config
 
-func TrackedCharacterID(characterID int) bool {
-	for _, id := range CharacterIDs {
-		if id == characterID {
-			return true
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// TrackedEntities are the corporations, alliances and characters whose killmails are collected,
+// and the characters left out of every chart.
+type TrackedEntities struct {
+	Corporations      []int `json:"corporations" yaml:"corporations"`
+	Alliances         []int `json:"alliances" yaml:"alliances"`
+	Characters        []int `json:"characters" yaml:"characters"`
+	ExcludeCharacters []int `json:"exclude_characters" yaml:"exclude_characters"`
+}
+
+// Normalize sorts each list and drops duplicates, rejecting IDs that cannot belong to an EVE entity.
+func (t *TrackedEntities) Normalize() error {
+	for _, list := range []struct {
+		name string
+		ids  *[]int
+	}{
+		{"corporations", &t.Corporations},
+		{"alliances", &t.Alliances},
+		{"characters", &t.Characters},
+		{"exclude_characters", &t.ExcludeCharacters},
+	} {
+		ids := append([]int{}, *list.ids...)
+		sort.Ints(ids)
+		ids = slices.Compact(ids)
+		if len(ids) > 0 && ids[0] <= 0 {
+			return fmt.Errorf("invalid ID %d in %s", ids[0], list.name)
 		}
+		*list.ids = ids
 	}
-	return false
+	return nil
 }
 
-func TrackedAllianceID(allianceID int) bool {
-	for _, id := range AllianceIDs {
-		if id == allianceID {
-			return true
+// Clone returns a copy of t that shares no slices with it.
+func (t TrackedEntities) Clone() TrackedEntities {
+	return TrackedEntities{
+		Corporations:      append([]int{}, t.Corporations...),
+		Alliances:         append([]int{}, t.Alliances...),
+		Characters:        append([]int{}, t.Characters...),
+		ExcludeCharacters: append([]int{}, t.ExcludeCharacters...),
+	}
+}
+
+// DefaultGroup names the group whose entities are the top-level lists of the tracked entities file.
+// Its dashboards are served at the root rather than under /g/{group}/.
+const DefaultGroup = "default"
+
+// TrackedConfig is the tracked entities file: the default group's lists, and any other named groups.
+type TrackedConfig struct {
+	TrackedEntities `yaml:",inline"`
+	Groups          map[string]TrackedEntities `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// Normalize validates group names and normalizes every group's lists.
+func (c *TrackedConfig) Normalize() error {
+	if err := c.TrackedEntities.Normalize(); err != nil {
+		return err
+	}
+	for name, group := range c.Groups {
+		if !ValidGroupName(name) || name == DefaultGroup {
+			return fmt.Errorf("invalid group name %q", name)
 		}
+		if err := group.Normalize(); err != nil {
+			return fmt.Errorf("group %s: %w", name, err)
+		}
+		c.Groups[name] = group
 	}
-	return false
+	return nil
 }
 
-func TrackedCorporationID(corporationID int) bool {
-	for _, id := range CorporationIDs {
-		if id == corporationID {
-			return true
+// Group returns the named group's entities from the file.
+func (c *TrackedConfig) Group(name string) (*TrackedEntities, bool) {
+	if name == DefaultGroup {
+		return &c.TrackedEntities, true
+	}
+	group, ok := c.Groups[name]
+	if !ok {
+		return nil, false
+	}
+	return &group, true
+}
+
+// ValidGroupName reports whether name can be used for a group: lowercase letters, digits and dashes,
+// starting with a letter or digit, so it is safe in URLs and directory names.
+func ValidGroupName(name string) bool {
+	if name == "" || len(name) > 64 || name[0] == '-' {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
 		}
 	}
-	return false
+	return true
 }
 
-func ExcludeCharacterID(characterID int) bool {
-	for _, id := range ExcludeCharacters {
-		if id == characterID {
+// DisplayCharacter reports whether a character is tracked, directly or through its corporation or
+// alliance, and not excluded.
+func (t *TrackedEntities) DisplayCharacter(characterID, corporationID, allianceID int) bool {
+	return !slices.Contains(t.ExcludeCharacters, characterID) &&
+		(slices.Contains(t.Characters, characterID) || slices.Contains(t.Corporations, corporationID) ||
+			slices.Contains(t.Alliances, allianceID))
+}
+
+// TrackedVictim reports whether a victim is a tracked character or in a tracked corporation.
+func (t *TrackedEntities) TrackedVictim(characterID, corporationID int) bool {
+	return slices.Contains(t.Characters, characterID) || slices.Contains(t.Corporations, corporationID)
+}
+
+var (
+	trackedMu sync.RWMutex
+	groups    = map[string]TrackedEntities{DefaultGroup: DefaultTracked.Clone()}
+)
+
+// Tracked returns a copy of the default group's tracked entities currently in effect.
+func Tracked() TrackedEntities {
+	tracked, _ := Group(DefaultGroup)
+	return tracked
+}
+
+// Group returns a copy of the named group's tracked entities currently in effect.
+func Group(name string) (TrackedEntities, bool) {
+	trackedMu.RLock()
+	defer trackedMu.RUnlock()
+	tracked, ok := groups[name]
+	return tracked.Clone(), ok
+}
+
+// GroupNames returns the names of the groups in effect, the default group first.
+func GroupNames() []string {
+	trackedMu.RLock()
+	defer trackedMu.RUnlock()
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if name != DefaultGroup {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultGroup}, names...)
+}
+
+// SetTrackedConfig puts the default group and every named group of c into effect, dropping any others.
+func SetTrackedConfig(c TrackedConfig) {
+	next := map[string]TrackedEntities{DefaultGroup: c.TrackedEntities.Clone()}
+	for name, group := range c.Groups {
+		next[name] = group.Clone()
+	}
+	trackedMu.Lock()
+	groups = next
+	trackedMu.Unlock()
+}
+
+// CurrentTrackedConfig returns the groups in effect as a tracked entities file.
+func CurrentTrackedConfig() TrackedConfig {
+	trackedMu.RLock()
+	defer trackedMu.RUnlock()
+	c := TrackedConfig{TrackedEntities: groups[DefaultGroup].Clone()}
+	for name, group := range groups {
+		if name == DefaultGroup {
+			continue
+		}
+		if c.Groups == nil {
+			c.Groups = make(map[string]TrackedEntities)
+		}
+		c.Groups[name] = group.Clone()
+	}
+	return c
+}
+
+// TrackedCharacterInAnyGroup reports whether any group in effect lists the character.
+func TrackedCharacterInAnyGroup(characterID int) bool {
+	trackedMu.RLock()
+	defer trackedMu.RUnlock()
+	for _, group := range groups {
+		if slices.Contains(group.Characters, characterID) {
 			return true
 		}
 	}
 	return false
 }
 
+// The predicates below check the default group.
+
+func TrackedCharacterID(characterID int) bool {
+	tracked := Tracked()
+	return slices.Contains(tracked.Characters, characterID)
+}
+
+func TrackedAllianceID(allianceID int) bool {
+	tracked := Tracked()
+	return slices.Contains(tracked.Alliances, allianceID)
+}
+
+func TrackedCorporationID(corporationID int) bool {
+	tracked := Tracked()
+	return slices.Contains(tracked.Corporations, corporationID)
+}
+
+func ExcludeCharacterID(characterID int) bool {
+	tracked := Tracked()
+	return slices.Contains(tracked.ExcludeCharacters, characterID)
+}
+
 func DisplayCharacter(characterID, corporationID, allianceID int) bool {
-	return !ExcludeCharacterID(characterID) &&
-		(TrackedCharacterID(characterID) || TrackedCorporationID(corporationID) || TrackedAllianceID(allianceID))
+	tracked := Tracked()
+	return tracked.DisplayCharacter(characterID, corporationID, allianceID)
 }
@@ -1,3 +1,8 @@
 package config
 
 const ZkillURL = "https://zkillboard.com"
+
+const RedisQURL = "https://zkillredisq.stream/listen.php"
+
+// ZkillHistoryPath is where zKillboard publishes its daily killmail ID to hash files.
+const ZkillHistoryPath = "/api/history/"
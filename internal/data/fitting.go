@@ -0,0 +1,66 @@
+package data
+
+import (
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// FittedItem is a victim item resolved to its type name and slot.
+type FittedItem struct {
+	TypeID            int
+	Name              string
+	Flag              int
+	Slot              model.Slot
+	Singleton         int
+	QuantityDropped   int64
+	QuantityDestroyed int64
+	Contents          []FittedItem
+}
+
+// Quantity returns how many of the item were on the ship, dropped or destroyed.
+func (f FittedItem) Quantity() int64 {
+	return f.QuantityDropped + f.QuantityDestroyed
+}
+
+// Fitting is a lost ship's fit and cargo grouped by slot.
+type Fitting struct {
+	ShipTypeID int
+	ShipName   string
+	Slots      map[model.Slot][]FittedItem
+}
+
+// Items returns the items in slot, in killmail order.
+func (f *Fitting) Items(slot model.Slot) []FittedItem {
+	return f.Slots[slot]
+}
+
+// ResolveFitting resolves a victim's items through the type names and groups them by slot.
+// Items inside containers are kept as the container's Contents and take the container's slot.
+func (iv *InvTypeService) ResolveFitting(victim model.Victim) *Fitting {
+	fitting := &Fitting{
+		ShipTypeID: victim.ShipTypeID,
+		ShipName:   iv.QueryInvType(victim.ShipTypeID),
+		Slots:      make(map[model.Slot][]FittedItem),
+	}
+
+	for _, item := range victim.Items {
+		fitted := iv.resolveItem(item, model.SlotForFlag(item.Flag))
+		fitting.Slots[fitted.Slot] = append(fitting.Slots[fitted.Slot], fitted)
+	}
+	return fitting
+}
+
+func (iv *InvTypeService) resolveItem(item model.Item, slot model.Slot) FittedItem {
+	fitted := FittedItem{
+		TypeID:            item.ItemTypeID,
+		Name:              iv.QueryInvType(item.ItemTypeID),
+		Flag:              item.Flag,
+		Slot:              slot,
+		Singleton:         item.Singleton,
+		QuantityDropped:   item.QuantityDropped,
+		QuantityDestroyed: item.QuantityDestroyed,
+	}
+	for _, content := range item.Items {
+		fitted.Contents = append(fitted.Contents, iv.resolveItem(content, slot))
+	}
+	return fitted
+}
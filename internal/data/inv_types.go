@@ -67,10 +67,8 @@ func (iv *InvTypeService) LoadInvTypes() error {
 }
 
 func (iv *InvTypeService) QueryInvType(id int) string {
-	for _, invType := range iv.invTypes {
-		if invType.ID == id {
-			return invType.Name
-		}
+	if name, ok := iv.invTypeMap[id]; ok {
+		return name
 	}
 	return "Unknown"
 }
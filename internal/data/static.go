@@ -0,0 +1,281 @@
+package data
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// staticTable names the files a static data table may be read from, in order of preference.
+type staticTable struct {
+	idColumn string
+	files    []string
+}
+
+var (
+	typesTable          = staticTable{"typeid", []string{"invTypes.csv", "types.yaml", "types.csv"}}
+	groupsTable         = staticTable{"groupid", []string{"invGroups.csv", "groups.yaml"}}
+	categoriesTable     = staticTable{"categoryid", []string{"invCategories.csv", "categories.yaml"}}
+	regionsTable        = staticTable{"regionid", []string{"mapRegions.csv", "mapRegions.yaml"}}
+	constellationsTable = staticTable{"constellationid", []string{"mapConstellations.csv", "mapConstellations.yaml"}}
+	systemsTable        = staticTable{"solarsystemid", []string{"mapSolarSystems.csv", "mapSolarSystems.yaml"}}
+	wormholeClassTable  = staticTable{"locationid", []string{"mapLocationWormholeClasses.csv"}}
+)
+
+// StaticDataService holds the parts of the static data export (SDE) used to group killmails,
+// keyed by ID for constant-time lookups.
+type StaticDataService struct {
+	types   map[int]model.TypeInfo
+	systems map[int]model.SolarSystem
+	logger  *logrus.Logger
+}
+
+// NewStaticDataService creates an empty StaticDataService.
+func NewStaticDataService(logger *logrus.Logger) *StaticDataService {
+	return &StaticDataService{
+		types:   make(map[int]model.TypeInfo),
+		systems: make(map[int]model.SolarSystem),
+		logger:  logger,
+	}
+}
+
+// Type returns a type with its group and category.
+func (sd *StaticDataService) Type(id int) (model.TypeInfo, bool) {
+	info, ok := sd.types[id]
+	return info, ok
+}
+
+// TypeName returns a type's name, or "Unknown".
+func (sd *StaticDataService) TypeName(id int) string {
+	if info, ok := sd.types[id]; ok {
+		return info.Name
+	}
+	return "Unknown"
+}
+
+// SolarSystem returns a solar system with its constellation, region and wormhole class.
+func (sd *StaticDataService) SolarSystem(id int) (model.SolarSystem, bool) {
+	system, ok := sd.systems[id]
+	return system, ok
+}
+
+// LoadFromDir loads whatever SDE tables are present in dir. Each table is read from a Fuzzwork-style CSV
+// with a header row, or from the official YAML, so the minimal static/types.csv also loads as names only.
+func (sd *StaticDataService) LoadFromDir(dir string) error {
+	categories, err := readStaticTable(dir, categoriesTable)
+	if err != nil {
+		return err
+	}
+	groups, err := readStaticTable(dir, groupsTable)
+	if err != nil {
+		return err
+	}
+	types, err := readStaticTable(dir, typesTable)
+	if err != nil {
+		return err
+	}
+
+	for id, row := range types {
+		info := model.TypeInfo{
+			TypeID:        id,
+			Name:          row.text("typename", "name"),
+			GroupID:       row.int("groupid"),
+			MarketGroupID: row.int("marketgroupid"),
+			Volume:        row.float("volume"),
+		}
+		if group, ok := groups[info.GroupID]; ok {
+			info.GroupName = group.text("groupname", "name")
+			info.CategoryID = group.int("categoryid")
+			info.CategoryName = categories[info.CategoryID].text("categoryname", "name")
+		}
+		sd.types[id] = info
+	}
+
+	regions, err := readStaticTable(dir, regionsTable)
+	if err != nil {
+		return err
+	}
+	constellations, err := readStaticTable(dir, constellationsTable)
+	if err != nil {
+		return err
+	}
+	systems, err := readStaticTable(dir, systemsTable)
+	if err != nil {
+		return err
+	}
+	wormholeClasses, err := readStaticTable(dir, wormholeClassTable)
+	if err != nil {
+		return err
+	}
+
+	for id, row := range systems {
+		system := model.SolarSystem{
+			SolarSystemID:   id,
+			Name:            row.text("solarsystemname", "name"),
+			Security:        row.float("security", "securitystatus"),
+			ConstellationID: row.int("constellationid"),
+			RegionID:        row.int("regionid"),
+			WormholeClassID: row.int("wormholeclassid"),
+		}
+		constellation := constellations[system.ConstellationID]
+		if system.RegionID == 0 {
+			system.RegionID = constellation.int("regionid")
+		}
+		system.ConstellationName = constellation.text("constellationname", "name")
+		system.RegionName = regions[system.RegionID].text("regionname", "name")
+
+		// Classes are recorded on the system, or inherited from its constellation or region
+		if system.WormholeClassID == 0 {
+			for _, location := range []int{id, system.ConstellationID, system.RegionID} {
+				if class := wormholeClasses[location].int("wormholeclassid"); class != 0 {
+					system.WormholeClassID = class
+					break
+				}
+			}
+		}
+		sd.systems[id] = system
+	}
+
+	sd.logger.Infof("Loaded static data from %s: %d types, %d solar systems", dir, len(sd.types), len(sd.systems))
+	return nil
+}
+
+// staticRow is one table row keyed by lower-cased column name.
+type staticRow map[string]string
+
+func (r staticRow) text(columns ...string) string {
+	for _, column := range columns {
+		if value, ok := r[column]; ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (r staticRow) int(columns ...string) int {
+	value, err := strconv.Atoi(r.text(columns...))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func (r staticRow) float(columns ...string) float64 {
+	value, err := strconv.ParseFloat(r.text(columns...), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// readStaticTable reads the first of the table's files found in dir. A table with no file is empty.
+func readStaticTable(dir string, table staticTable) (map[int]staticRow, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[int]staticRow{}, nil
+		}
+		return nil, fmt.Errorf("failed to read static data directory %s: %w", dir, err)
+	}
+
+	for _, name := range table.files {
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.EqualFold(entry.Name(), name) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if strings.EqualFold(filepath.Ext(name), ".csv") {
+				return readStaticCSV(path, table.idColumn)
+			}
+			return readStaticYAML(path, table.idColumn)
+		}
+	}
+	return map[int]staticRow{}, nil
+}
+
+// readStaticCSV reads a CSV whose first row names its columns.
+func readStaticCSV(path, idColumn string) (map[int]staticRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header of %s: %w", path, err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	rows := make(map[int]staticRow)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		row := make(staticRow, len(header))
+		for i, value := range record {
+			if i < len(header) && value != "None" {
+				row[header[i]] = value
+			}
+		}
+		id, err := strconv.Atoi(row[idColumn])
+		if err != nil {
+			continue
+		}
+		rows[id] = row
+	}
+	return rows, nil
+}
+
+// readStaticYAML reads an official SDE YAML file, a mapping from ID to fields. Localized
+// fields such as name keep their English text.
+func readStaticYAML(path, idColumn string) (map[int]staticRow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	var entries map[int]map[string]interface{}
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	rows := make(map[int]staticRow, len(entries))
+	for id, fields := range entries {
+		row := staticRow{idColumn: strconv.Itoa(id)}
+		for key, value := range fields {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				if english, ok := v["en"]; ok {
+					row[strings.ToLower(key)] = fmt.Sprint(english)
+				}
+			case []interface{}:
+				// Lists such as a system's stargates are not needed
+			default:
+				row[strings.ToLower(key)] = fmt.Sprint(v)
+			}
+		}
+		rows[id] = row
+	}
+	return rows, nil
+}
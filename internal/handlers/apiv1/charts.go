@@ -0,0 +1,196 @@
+package apiv1
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/handlers"
+	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/service"
+	"github.com/guarzo/zkillanalytics/internal/visuals"
+)
+
+const (
+	// jobWait is how long a request waits on its data before answering 202 and asking to be retried.
+	jobWait = 10 * time.Second
+	// retryAfterSeconds is the Retry-After sent with a 202.
+	retryAfterSeconds = 5
+
+	// Ranges that include today change as killmails arrive, so clients may keep them for less time.
+	openRangeMaxAge   = 5 * time.Minute
+	closedRangeMaxAge = time.Hour
+	definitionsMaxAge = time.Hour
+)
+
+// chartInfo describes a chart definition.
+type chartInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+}
+
+// chartResult is a chart's data for a range.
+type chartResult struct {
+	chartInfo
+	Data interface{} `json:"data"`
+}
+
+// rangeResponse holds charts prepared for a group and date range.
+type rangeResponse struct {
+	Group     string        `json:"group"`
+	Range     string        `json:"range"`
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	KillMails int           `json:"killmails"`
+	Charts    []chartResult `json:"charts"`
+}
+
+// pendingResponse is returned while the data for a range is still being fetched.
+type pendingResponse struct {
+	Message string            `json:"message"`
+	Job     service.JobStatus `json:"job"`
+}
+
+// ListChartsHandler lists the chart definitions.
+func ListChartsHandler(groups *service.GroupRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var charts []chartInfo
+		for _, chart := range visuals.Charts() {
+			charts = append(charts, infoFor(chart))
+		}
+		writeCachedJSON(w, r, charts, definitionsMaxAge, groups.Logger)
+	}
+}
+
+// ListGroupsHandler lists the tracked groups.
+func ListGroupsHandler(groups *service.GroupRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeCachedJSON(w, r, config.GroupNames(), openRangeMaxAge, groups.Logger)
+	}
+}
+
+// GroupChartsHandler returns every chart for the group in the path and the range in the query.
+func GroupChartsHandler(groups *service.GroupRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeCharts(w, r, groups, visuals.Charts())
+	}
+}
+
+// GroupChartHandler returns the chart named in the path for its group and the range in the query.
+func GroupChartHandler(groups *service.GroupRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["chart"]
+		chart, ok := visuals.FindChart(name)
+		if !ok {
+			handlers.WriteJSONError(w, "Unknown chart", name, http.StatusNotFound, groups.Logger)
+			return
+		}
+		writeCharts(w, r, groups, []visuals.Chart{chart})
+	}
+}
+
+// writeCharts prepares charts from the data of the requested group and range. Data that takes longer than
+// jobWait to fetch is answered with 202 and the job's status; retrying the request with the job's ID in the job
+// parameter collects the job's result, which is kept for the retry even once the job has finished.
+func writeCharts(w http.ResponseWriter, r *http.Request, groups *service.GroupRegistry, charts []visuals.Chart) {
+	group := mux.Vars(r)["group"]
+	orchestrateService, err := groups.Get(group)
+	if errors.Is(err, service.ErrUnknownGroup) {
+		handlers.WriteJSONError(w, "Unknown group", group, http.StatusNotFound, groups.Logger)
+		return
+	}
+	if err != nil {
+		handlers.WriteJSONError(w, "Failed to open group", group, http.StatusInternalServerError, groups.Logger)
+		return
+	}
+
+	now := time.Now().UTC()
+	dateRange, err := handlers.ParseRangeQuery(r.URL.Query(), now)
+	if err != nil {
+		handlers.WriteJSONError(w, "Invalid date range", err.Error(), http.StatusBadRequest, groups.Logger)
+		return
+	}
+
+	job := orchestrateService.ResumeGetAllData(r.URL.Query().Get("job"), orchestrateService.GetTrackedCorporations(),
+		orchestrateService.GetTrackedAlliances(), orchestrateService.GetTrackedCharacters(), dateRange.Start, dateRange.End)
+	waitCtx, cancel := context.WithTimeout(r.Context(), jobWait)
+	defer cancel()
+	if !job.WaitDone(waitCtx) {
+		job.Detach()
+		if r.Context().Err() == nil {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			w.Header().Set("Cache-Control", "no-store")
+			handlers.WriteJSONResponse(w, pendingResponse{Message: "Data is being fetched, retry shortly with the job ID", Job: job.Status()}, http.StatusAccepted, groups.Logger)
+		}
+		return
+	}
+	result, err := job.Wait(r.Context())
+	if err != nil {
+		handlers.WriteJSONError(w, "Failed to fetch killmails", err.Error(), http.StatusInternalServerError, groups.Logger)
+		return
+	}
+	chartData := result.(*model.ChartData)
+
+	response := rangeResponse{
+		Group:     orchestrateService.Group,
+		Range:     dateRange.Label,
+		From:      dateRange.StartDate(),
+		To:        dateRange.EndDate(),
+		KillMails: len(chartData.KillMails),
+	}
+	for _, chart := range charts {
+		response.Charts = append(response.Charts, chartResult{
+			chartInfo: infoFor(chart),
+			Data:      visuals.PrepareChart(orchestrateService, chart, chartData),
+		})
+	}
+
+	maxAge := openRangeMaxAge
+	if dateRange.End.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)) {
+		maxAge = closedRangeMaxAge
+	}
+	writeCachedJSON(w, r, response, maxAge, groups.Logger)
+}
+
+func infoFor(chart visuals.Chart) chartInfo {
+	return chartInfo{Name: chart.Name, Description: chart.Description, Type: chart.Type}
+}
+
+// writeCachedJSON writes data with an ETag and a Cache-Control max-age, answering 304 Not Modified
+// when the client already holds the same response.
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, data interface{}, maxAge time.Duration, logger *logrus.Logger) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		handlers.WriteJSONError(w, "Failed to encode response", err.Error(), http.StatusInternalServerError, logger)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("Vary", "Authorization, Cookie")
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if match = strings.TrimSpace(match); match == etag || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		logger.Errorf("Failed to write API response: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package apiv1
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/guarzo/zkillanalytics/internal/persist"
+	"github.com/guarzo/zkillanalytics/internal/service"
+	"github.com/guarzo/zkillanalytics/internal/visuals"
+)
+
+// object is a JSON object in the OpenAPI description.
+type object = map[string]interface{}
+
+// OpenAPIHandler serves the OpenAPI 3 description of the API.
+func OpenAPIHandler(groups *service.GroupRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeCachedJSON(w, r, openAPIDocument(), definitionsMaxAge, groups.Logger)
+	}
+}
+
+// openAPIDocument describes the API, listing the chart names from the chart definitions.
+func openAPIDocument() object {
+	var chartNames []string
+	for _, chart := range visuals.Charts() {
+		chartNames = append(chartNames, chart.Name)
+	}
+
+	ref := func(name string) object { return object{"$ref": "#/components/" + name} }
+	jsonContent := func(schema object) object {
+		return object{"application/json": object{"schema": schema}}
+	}
+	errorResponse := func(description string) object {
+		return object{"description": description, "content": jsonContent(ref("schemas/Error"))}
+	}
+	chartsOperation := func(summary string, parameters ...object) object {
+		return object{
+			"summary":    summary,
+			"parameters": append(parameters, ref("parameters/from"), ref("parameters/to"), ref("parameters/preset"), ref("parameters/week")),
+			"responses": object{
+				"200": object{
+					"description": "Chart data for the range",
+					"headers":     object{"ETag": ref("headers/ETag"), "Cache-Control": ref("headers/Cache-Control")},
+					"content":     jsonContent(ref("schemas/RangeCharts")),
+				},
+				"202": object{
+					"description": "The data is still being fetched; retry after the Retry-After delay",
+					"headers":     object{"Retry-After": object{"schema": object{"type": "integer"}}},
+					"content":     jsonContent(ref("schemas/Pending")),
+				},
+				"304": object{"description": "Unchanged since the ETag given in If-None-Match"},
+				"400": errorResponse("Invalid date range"),
+				"401": errorResponse("Missing or invalid API token"),
+				"404": errorResponse("Unknown group or chart"),
+			},
+		}
+	}
+	queryParameter := func(name, description string, schema object) object {
+		return object{"name": name, "in": "query", "description": description, "schema": schema}
+	}
+	pathParameter := func(name, description string, schema object) object {
+		return object{"name": name, "in": "path", "required": true, "description": description, "schema": schema}
+	}
+
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":   "zkillanalytics",
+			"version": "1",
+			"description": fmt.Sprintf("Chart data behind the TPS dashboards. Give a range as from and to dates, a preset or an ISO week, "+
+				"covering at most %d days.", persist.MaxDateRangeDays),
+		},
+		"servers":  []object{{"url": "/api/v1"}},
+		"security": []object{{"bearer": []string{}}},
+		"paths": object{
+			"/charts": object{"get": object{
+				"summary": "List the charts",
+				"responses": object{"200": object{
+					"description": "Chart definitions",
+					"content":     jsonContent(object{"type": "array", "items": ref("schemas/Chart")}),
+				}},
+			}},
+			"/groups": object{"get": object{
+				"summary": "List the tracked groups",
+				"responses": object{"200": object{
+					"description": "Group names, the default group first",
+					"content":     jsonContent(object{"type": "array", "items": object{"type": "string"}}),
+				}},
+			}},
+			"/groups/{group}/charts": object{"get": chartsOperation("Every chart for a group and range",
+				ref("parameters/group"))},
+			"/groups/{group}/charts/{chart}": object{"get": chartsOperation("One chart for a group and range",
+				ref("parameters/group"), pathParameter("chart", "Chart name", object{"type": "string", "enum": chartNames}))},
+		},
+		"components": object{
+			"securitySchemes": object{"bearer": object{"type": "http", "scheme": "bearer"}},
+			"parameters": object{
+				"group":  pathParameter("group", "Tracked group, such as default", object{"type": "string"}),
+				"from":   queryParameter("from", "First day of the range; requires to", object{"type": "string", "format": "date"}),
+				"to":     queryParameter("to", "Last day of the range; requires from", object{"type": "string", "format": "date"}),
+				"preset": queryParameter("preset", "Named range, used instead of from and to", object{"type": "string", "enum": persist.DateRangePresets}),
+				"week":   queryParameter("week", "ISO week such as 2024-W05, used instead of a preset", object{"type": "string", "pattern": `^\d{4}-W\d{2}$`}),
+			},
+			"headers": object{
+				"ETag":          object{"description": "Send back in If-None-Match to get 304 when unchanged", "schema": object{"type": "string"}},
+				"Cache-Control": object{"description": "How long the response may be reused; shorter for ranges including today", "schema": object{"type": "string"}},
+			},
+			"schemas": object{
+				"Chart": object{
+					"type": "object",
+					"properties": object{
+						"name":        object{"type": "string", "enum": chartNames},
+						"description": object{"type": "string"},
+						"type":        object{"type": "string", "description": "How the dashboard draws it: bar, line, matrix or wordCloud"},
+					},
+				},
+				"RangeCharts": object{
+					"type": "object",
+					"properties": object{
+						"group":     object{"type": "string"},
+						"range":     object{"type": "string", "description": "Label of the range"},
+						"from":      object{"type": "string", "format": "date"},
+						"to":        object{"type": "string", "format": "date"},
+						"killmails": object{"type": "integer"},
+						"charts": object{"type": "array", "items": object{
+							"allOf": []object{ref("schemas/Chart"), {
+								"type":       "object",
+								"properties": object{"data": object{"description": "The chart's data, shaped as the dashboard uses it"}},
+							}},
+						}},
+					},
+				},
+				"Pending": object{
+					"type": "object",
+					"properties": object{
+						"message": object{"type": "string"},
+						"job":     object{"type": "object", "description": "Status of the data job, with its progress"},
+					},
+				},
+				"Error": object{
+					"type":       "object",
+					"properties": object{"error": object{"type": "string"}},
+				},
+			},
+		},
+	}
+}
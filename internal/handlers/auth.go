@@ -1,16 +1,20 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
 
 	"github.com/guarzo/zkillanalytics/internal/model"
 	"github.com/guarzo/zkillanalytics/internal/persist"
@@ -25,7 +29,9 @@ var (
 	))
 )
 
-func AuthMiddleware(sessionStore *SessionService, esiService *service.EsiService) mux.MiddlewareFunc {
+// AuthMiddleware sends visitors without a valid session to the landing page. Paths starting with one of
+// publicPrefixes are let through as well, for routes that check access themselves.
+func AuthMiddleware(sessionStore *SessionService, esiService *service.EsiService, publicPrefixes ...string) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// List of public routes that don't require authentication
@@ -36,8 +42,9 @@ func AuthMiddleware(sessionStore *SessionService, esiService *service.EsiService
 				"/logout":   true,
 				"/callback": true,
 			}
-
-			host := utils.GetHost(r.Host)
+			for _, prefix := range publicPrefixes {
+				publicRoutes[prefix] = true
+			}
 
 			log.Printf("Incoming request path: %s, host: %s", r.URL.Path, r.Host)
 
@@ -75,27 +82,88 @@ func AuthMiddleware(sessionStore *SessionService, esiService *service.EsiService
 				return
 			}
 
-			// Ensure token exists for the logged-in user
-			_, err = persist.GetMainIdentityToken(loggedInUser, host)
-			if err != nil {
-				// If token is missing, redirect to the landing page
+			if err := validateLoggedInUser(sessionStore, session, esiService, loggedInUser, w, r); err != nil {
 				handleAuthErrorWithRedirect(w, r, err.Error(), "/landing")
 				return
 			}
 
-			_, err = ValidateIdentities(session, esiService, r, w)
+			// If user is authenticated, proceed to the next handler
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validateLoggedInUser checks that the logged-in user still has an identity token for the host and
+// that their identities are still valid.
+func validateLoggedInUser(sessionStore *SessionService, session *sessions.Session, esiService *service.EsiService, loggedInUser int64, w http.ResponseWriter, r *http.Request) error {
+	// Ensure token exists for the logged-in user
+	if _, err := persist.GetMainIdentityToken(sessionStore.Identities, loggedInUser, utils.GetHost(r.Host)); err != nil {
+		return err
+	}
+
+	if _, err := ValidateIdentities(sessionStore, session, esiService, r, w); err != nil {
+		xlog.Logf("Failed to validate identities")
+		return err
+	}
+	return nil
+}
+
+// AdminMiddleware limits routes to logged-in users whose main character is one of adminIDs.
+// It must run after AuthMiddleware, which ensures there is a logged-in user.
+func AdminMiddleware(sessionStore *SessionService, adminIDs []int64, logger *logrus.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, err := sessionStore.Get(r, SessionName)
 			if err != nil {
-				xlog.Logf("Failed to validate identities")
-				handleAuthErrorWithRedirect(w, r, err.Error(), "/landing")
+				WriteJSONResponse(w, ErrorResponse{Error: "Not logged in"}, http.StatusUnauthorized, logger)
+				return
+			}
+
+			loggedInUser := GetSessionValues(session).LoggedInUser
+			if loggedInUser == 0 || !slices.Contains(adminIDs, loggedInUser) {
+				xlog.Logf("Character %d denied access to admin route %s", loggedInUser, r.URL.Path)
+				WriteJSONResponse(w, ErrorResponse{Error: "Admin access required"}, http.StatusForbidden, logger)
 				return
 			}
 
-			// If user is authenticated, proceed to the next handler
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// APIAuthMiddleware lets API requests through with one of tokens as a bearer token, or from a logged-in user
+// whose session passes the same checks as AuthMiddleware. Register its routes as public prefixes of AuthMiddleware.
+func APIAuthMiddleware(sessionStore *SessionService, esiService *service.EsiService, tokens []string, logger *logrus.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+				for _, token := range tokens {
+					if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+				WriteJSONResponse(w, ErrorResponse{Error: "Invalid API token"}, http.StatusUnauthorized, logger)
+				return
+			}
+
+			if session, err := sessionStore.Get(r, SessionName); err == nil {
+				if loggedInUser := GetSessionValues(session).LoggedInUser; loggedInUser != 0 {
+					if err := validateLoggedInUser(sessionStore, session, esiService, loggedInUser, w, r); err != nil {
+						WriteJSONResponse(w, ErrorResponse{Error: "Session is no longer valid, log in again"}, http.StatusUnauthorized, logger)
+						return
+					}
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			WriteJSONResponse(w, ErrorResponse{Error: "API token or login required"}, http.StatusUnauthorized, logger)
+		})
+	}
+}
+
 func GetAuthenticatedCharacterIDs(identities map[int64]model.CharacterData) []int64 {
 	authenticatedCharacters := make([]int64, 0, len(identities))
 	for id := range identities {
@@ -131,7 +199,7 @@ func CallbackHandler(s *SessionService, esiService *service.EsiService) http.Han
 
 		xlog.Logf("Received OAuth callback with code: %s, state: %s, host: %s", code, state, host)
 
-		token, err := esiService.EsiClient.ExchangeCode(code)
+		token, err := esiService.EsiClient.ExchangeCode(r.Context(), code)
 		if err != nil {
 			handleAuthErrorWithRedirect(w, r, fmt.Sprintf("Failed to exchange token: %v", err), "/")
 			return
@@ -141,7 +209,7 @@ func CallbackHandler(s *SessionService, esiService *service.EsiService) http.Han
 		xlog.Logf("Exchanged code for token: TokenType=%s, Expiry=%s", token.TokenType, token.Expiry)
 
 		// Get user information
-		user, err := esiService.EsiClient.GetUserInfo(token)
+		user, err := esiService.EsiClient.GetUserInfo(r.Context(), token)
 		if err != nil {
 			handleAuthErrorWithRedirect(w, r, fmt.Sprintf("Failed to get user info: %v", err), "/")
 			return
@@ -198,7 +266,7 @@ func CallbackHandler(s *SessionService, esiService *service.EsiService) http.Han
 		xlog.Logf("MainIdentity: %d", mainIdentity)
 
 		// Update identities with the new token
-		err = persist.UpdateIdentities(mainIdentity, host, func(userConfig *model.Identities) error {
+		err = persist.UpdateIdentities(s.Identities, mainIdentity, host, func(userConfig *model.Identities) error {
 			xlog.Logf("Updating token for CharacterID: %d", user.CharacterID)
 			userConfig.Tokens[fmt.Sprintf("%d", user.CharacterID)] = *token
 			return nil
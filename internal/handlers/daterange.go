@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+// ParseRangeQuery reads a date range from a week, preset, or from and to query parameter, in that order of
+// precedence. Ranges running past today are cut short at today, and ranges starting after it, or outside
+// the limits of persist.CheckDateRangeLimits, are rejected.
+func ParseRangeQuery(query url.Values, now time.Time) (persist.DateRange, error) {
+	var dateRange persist.DateRange
+	var err error
+	switch {
+	case query.Get("week") != "":
+		dateRange, err = persist.ISOWeekDateRange(query.Get("week"))
+	case query.Get("preset") != "":
+		dateRange, err = persist.PresetDateRange(query.Get("preset"), now)
+	case query.Get("from") != "" && query.Get("to") != "":
+		dateRange, err = persist.ExplicitDateRange(query.Get("from"), query.Get("to"))
+	default:
+		return dateRange, fmt.Errorf("give from and to dates, a preset (%v) or an ISO week such as 2024-W05", persist.DateRangePresets)
+	}
+	if err != nil {
+		return dateRange, err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if dateRange.Start.After(today) {
+		return dateRange, fmt.Errorf("%s starts after today", dateRange.Label)
+	}
+	if dateRange.End.After(today) {
+		dateRange.End = today
+	}
+	return dateRange, persist.CheckDateRangeLimits(dateRange, now)
+}
@@ -17,15 +17,18 @@ import (
 	"github.com/guarzo/zkillanalytics/internal/xlog"
 )
 
-// ValidUser now checks if a character is explicitly listed in the configuration or is a trusted character.
-func ValidUser(character model.CharacterData) bool {
-	return slices.Contains(config.CharacterIDs, int(character.CharacterID)) ||
-		IsTrustedCharacter(character.CharacterID)
+// ValidUser now checks if a character is explicitly listed in any tracked group or is a trusted character.
+func ValidUser(trusted persist.TrustedRepository, character model.CharacterData) bool {
+	return config.TrackedCharacterInAnyGroup(int(character.CharacterID)) ||
+		IsTrustedCharacter(trusted, character.CharacterID)
 }
 
 // IsTrustedCharacter checks if the character is in the list of trusted characters, ignoring corporations.
-func IsTrustedCharacter(characterID int64) bool {
-	trustedCharacters, _ := persist.LoadTrustedCharacters()
+func IsTrustedCharacter(trusted persist.TrustedRepository, characterID int64) bool {
+	trustedCharacters, err := trusted.LoadTrusted()
+	if err != nil {
+		return false
+	}
 
 	for _, char := range trustedCharacters.TrustedCharacters {
 		if char.CharacterID == characterID {
@@ -83,7 +86,7 @@ func ResetIdentitiesHandler(s *SessionService) http.HandlerFunc {
 			return
 		}
 
-		err := persist.DeleteIdentity(mainIdentity, host)
+		err := s.Identities.DeleteIdentities(mainIdentity, host)
 		if err != nil {
 			xlog.Logf("Failed to delete identity %d: %v", mainIdentity, err)
 		}
@@ -111,7 +114,7 @@ func SameIdentities(users []int64, identities map[int64]model.CharacterData) boo
 	return true
 }
 
-func ValidateIdentities(session *sessions.Session, esiService *service.EsiService, r *http.Request, w http.ResponseWriter) (map[int64]model.CharacterData, error) {
+func ValidateIdentities(s *SessionService, session *sessions.Session, esiService *service.EsiService, r *http.Request, w http.ResponseWriter) (map[int64]model.CharacterData, error) {
 	sessionValues := GetSessionValues(session)
 	storeData, etag, canSkip := CheckIfCanSkip(session)
 	identities := storeData.Identities
@@ -128,23 +131,23 @@ func ValidateIdentities(session *sessions.Session, esiService *service.EsiServic
 		needIdentityPopulation := len(authenticatedUsers) == 0 || !SameIdentities(authenticatedUsers, storeData.Identities) || time.Since(time.Unix(sessionValues.LastRefreshTime, 0)) > 15*time.Minute
 
 		if needIdentityPopulation {
-			userConfig, err := persist.LoadIdentities(sessionValues.LoggedInUser, host)
+			userConfig, err := s.Identities.LoadIdentities(sessionValues.LoggedInUser, host)
 
 			if err != nil {
 				xlog.Logf("Failed to load identities: %v", err)
 				return nil, fmt.Errorf("failed to load identities: %w", err)
 			}
 
-			identities, err = esiService.EsiClient.PopulateIdentities(userConfig)
+			identities, err = esiService.EsiClient.PopulateIdentities(r.Context(), userConfig)
 			if err != nil {
 				return nil, fmt.Errorf("failed to populate identities: %w", err)
 			}
 
-			if !ValidUser(identities[sessionValues.LoggedInUser]) {
+			if !ValidUser(s.Trusted, identities[sessionValues.LoggedInUser]) {
 				return nil, fmt.Errorf("not a valid user - ask in discord if you think this is a mistake")
 			}
 
-			if err = persist.SaveIdentities(sessionValues.LoggedInUser, userConfig, host); err != nil {
+			if err = s.Identities.SaveIdentities(sessionValues.LoggedInUser, userConfig, host); err != nil {
 				return nil, fmt.Errorf("failed to save identities: %w", err)
 			}
 
@@ -29,8 +29,12 @@ type SessionValues struct {
 	PreviousEtagUsed       string
 }
 
+// SessionService holds the session cookie store along with the identities and trust lists
+// that decide who may log in.
 type SessionService struct {
-	store *sessions.CookieStore
+	store      *sessions.CookieStore
+	Identities persist.IdentityRepository
+	Trusted    persist.TrustedRepository
 }
 
 func GetSessionValues(session *sessions.Session) SessionValues {
@@ -62,9 +66,11 @@ func GetSessionValues(session *sessions.Session) SessionValues {
 	return s
 }
 
-func NewSessionService(secret string) *SessionService {
+func NewSessionService(secret string, identities persist.IdentityRepository, trusted persist.TrustedRepository) *SessionService {
 	return &SessionService{
-		store: sessions.NewCookieStore([]byte(secret)),
+		store:      sessions.NewCookieStore([]byte(secret)),
+		Identities: identities,
+		Trusted:    trusted,
 	}
 }
 
@@ -0,0 +1,37 @@
+package tps
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/service"
+)
+
+// requestGroup returns the tracked group named in the request path, or the default group outside /g/{group}/.
+func requestGroup(r *http.Request) string {
+	if group := mux.Vars(r)["group"]; group != "" {
+		return group
+	}
+	return config.DefaultGroup
+}
+
+// GroupHandler serves a request with the handler build returns for the service of the group in the path,
+// responding 404 for groups that are not in the tracked entities file.
+func GroupHandler(groups *service.GroupRegistry, build func(orchestrateService *service.OrchestrateService) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orchestrateService, err := groups.Get(requestGroup(r))
+		if errors.Is(err, service.ErrUnknownGroup) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			groups.Logger.Errorf("Error opening group %s: %v", requestGroup(r), err)
+			http.Error(w, "Failed to open group", http.StatusInternalServerError)
+			return
+		}
+		build(orchestrateService)(w, r)
+	}
+}
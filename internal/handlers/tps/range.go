@@ -0,0 +1,55 @@
+package tps
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/handlers"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+	"github.com/guarzo/zkillanalytics/internal/service"
+	"github.com/guarzo/zkillanalytics/internal/visuals"
+)
+
+// RangeHandler renders every chart for an ad-hoc date range, given as from and to dates, a named preset
+// or an ISO week. Each range is rendered once and served from the charts directory afterwards.
+func RangeHandler(orchestrateService *service.OrchestrateService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dateRange, err := handlers.ParseRangeQuery(r.URL.Query(), time.Now().UTC())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid date range: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		dir := persist.GetChartsDirectory()
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create charts directory: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		filePath := generateFilePath(dir, orchestrateService.Group, config.Range, dateRange.StartDate(), dateRange.EndDate())
+		if _, err := os.Stat(filePath); err == nil {
+			orchestrateService.Logger.Infof("Serving existing chart for %s from %s to %s", dateRange.Label, dateRange.StartDate(), dateRange.EndDate())
+			http.ServeFile(w, r, filePath)
+			return
+		}
+
+		orchestrateService.Logger.Infof("Creating chart for %s from %s to %s", dateRange.Label, dateRange.StartDate(), dateRange.EndDate())
+		data, ok := waitForChartData(w, r, orchestrateService, config.Range, []chartRange{{start: dateRange.Start, end: dateRange.End}})
+		if !ok {
+			return
+		}
+		chartData := data[0]
+
+		label := fmt.Sprintf("%s (%s to %s)", dateRange.Label, dateRange.StartDate(), dateRange.EndDate())
+		timeFrames := []visuals.TimeFrame{{Name: "Range", Label: label, Data: chartData}}
+		if err := visuals.RenderTimeFrames(orchestrateService, timeFrames, filePath); err != nil {
+			http.Error(w, fmt.Sprintf("Error creating charts: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		http.ServeFile(w, r, filePath)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/persist"
 	"github.com/guarzo/zkillanalytics/internal/service"
 )
@@ -18,25 +17,18 @@ func RefreshTPSHandler(orchestrateService *service.OrchestrateService) http.Hand
 		}
 		orchestrateService.Logger.Infof("Charts directory emptied for refresh")
 
-		err = persist.DeleteCurrentMonthFile()
-		if err != nil {
-			orchestrateService.Logger.Errorf("Error removing current month data: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		orchestrateService.Logger.Infof("removed current month data")
-
-		begin, end := persist.GetDateRange(config.MonthToDate)
-
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 		defer cancel()
 
-		_, err = orchestrateService.GetAllData(ctx, config.CorporationIDs, config.AllianceIDs, config.CharacterIDs, begin, end)
+		now := time.Now()
+		newData, err := orchestrateService.RefreshMonth(ctx, orchestrateService.GetTrackedCorporations(), orchestrateService.GetTrackedAlliances(),
+			orchestrateService.GetTrackedCharacters(), now.Year(), int(now.Month()))
 		if err != nil {
 			orchestrateService.Logger.Errorf("Error fetching updated killmails: %v", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		orchestrateService.Logger.Infof("Updated current month data")
+		orchestrateService.Logger.Infof("Updated current month data with %d new killmails", len(newData.KillMails))
 
 		// Set the Content-Type header to indicate plain text response
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -1,18 +1,25 @@
 package tps
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"path"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/model"
 	"github.com/guarzo/zkillanalytics/internal/persist"
 	"github.com/guarzo/zkillanalytics/internal/service"
 )
 
+// jobRenderWait is how long a chart request waits on its data before showing the loading page.
+const jobRenderWait = 5 * time.Second
+
 // TPSHandler is an HTTP handler that generates a bar chart based on the mode
 func TPSHandler(route config.Route, orchestrateService *service.OrchestrateService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -33,7 +40,7 @@ func TPSHandler(route config.Route, orchestrateService *service.OrchestrateServi
 			return
 		}
 
-		filePath := generateFilePath(dir, route, startDate, endDate)
+		filePath := generateFilePath(dir, orchestrateService.Group, route, startDate, endDate)
 
 		// Check if the file already exists
 		if _, err := os.Stat(filePath); err == nil {
@@ -44,24 +51,33 @@ func TPSHandler(route config.Route, orchestrateService *service.OrchestrateServi
 
 		orchestrateService.Logger.Infof("Creating chart for %s from %s to %s based on mode %s", config.RouteToString[route], startDate, endDate, modeStr)
 
-		corporations := orchestrateService.GetTrackedCorporations()
-		alliances := orchestrateService.GetTrackedAlliances()
-		characters := orchestrateService.GetTrackedCharacters()
+		start, end, err := persist.ParseDateRange(startDate, endDate)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid date range: %s", err), http.StatusInternalServerError)
+			return
+		}
 
-		chartData, err := orchestrateService.GetAllData(r.Context(), corporations, alliances, characters, startDate, endDate)
+		lastMonthStart, lastMonthEnd, err := snippetRange(orchestrateService, config.PreviousMonth)
 		if err != nil {
-			if err.Error() == "another GetAllData operation is in progress" {
-				orchestrateService.Logger.Warnf("Another GetAllData operation is in progress, %v", err)
-				LoadingHandler(w, r)
-			} else {
-				orchestrateService.Logger.Errorf("Error fetching detailed killmails: %v", err)
-				http.Error(w, fmt.Sprintf("Error fetching detailed killmails: %s", err), http.StatusInternalServerError)
-			}
+			http.Error(w, fmt.Sprintf("Invalid date range: %s", err), http.StatusInternalServerError)
+			return
+		}
+		mtdStart, mtdEnd, err := snippetRange(orchestrateService, config.MonthToDate)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid date range: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		data, ok := waitForChartData(w, r, orchestrateService, route, []chartRange{
+			{start: start, end: end},
+			{start: lastMonthStart, end: lastMonthEnd},
+			{start: mtdStart, end: mtdEnd},
+		})
+		if !ok {
 			return
 		}
 
-		if err := generateChart(orchestrateService, route, chartData, filePath, w); err != nil {
-			http.Error(w, fmt.Sprintf("Error creating bar chart: %s", err), http.StatusInternalServerError)
+		if !generateChart(w, orchestrateService, route, data[0], data[1], data[2], filePath) {
 			return
 		}
 
@@ -69,3 +85,152 @@ func TPSHandler(route config.Route, orchestrateService *service.OrchestrateServi
 		http.ServeFile(w, r, filePath)
 	}
 }
+
+// chartRange is a span of whole days a chart needs data for.
+type chartRange struct {
+	start, end time.Time
+}
+
+// waitForChartData submits the data jobs for every range, or claims those named in order by the request's job
+// parameters, then gives them one shared moment so cached ranges render directly. It returns the data in the
+// order of ranges. If any job is still running it serves the loading page for all of them instead, leaving
+// their results for the page's reload, and on failure an error; both return false.
+func waitForChartData(w http.ResponseWriter, r *http.Request, orchestrateService *service.OrchestrateService, route config.Route, ranges []chartRange) ([]*model.ChartData, bool) {
+	corporations := orchestrateService.GetTrackedCorporations()
+	alliances := orchestrateService.GetTrackedAlliances()
+	characters := orchestrateService.GetTrackedCharacters()
+
+	jobIDs := r.URL.Query()["job"]
+	jobs := make([]*service.Job, len(ranges))
+	for i, dataRange := range ranges {
+		var jobID string
+		if i < len(jobIDs) {
+			jobID = jobIDs[i]
+		}
+		jobs[i] = orchestrateService.ResumeGetAllData(jobID, corporations, alliances, characters, dataRange.start, dataRange.end)
+	}
+
+	waitCtx, cancel := context.WithTimeout(r.Context(), jobRenderWait)
+	defer cancel()
+	var pending []string
+	for _, job := range jobs {
+		if !job.WaitDone(waitCtx) {
+			pending = append(pending, job.ID)
+		}
+	}
+	if len(pending) > 0 {
+		ids := make([]string, len(jobs))
+		for i, job := range jobs {
+			job.Detach()
+			ids[i] = job.ID
+		}
+		if r.Context().Err() == nil {
+			orchestrateService.Logger.Infof("Jobs %v for %s are still running, serving loading page", pending, config.RouteToString[route])
+			LoadingHandler(w, r, ids)
+		}
+		return nil, false
+	}
+
+	data := make([]*model.ChartData, len(jobs))
+	var waitErr error
+	for i, job := range jobs {
+		// Every job has finished, so this only collects its result
+		result, err := job.Wait(r.Context())
+		if err != nil {
+			if waitErr == nil {
+				waitErr = err
+			}
+			continue
+		}
+		data[i] = result.(*model.ChartData)
+	}
+	if waitErr != nil {
+		orchestrateService.Logger.Errorf("Error fetching detailed killmails: %v", waitErr)
+		http.Error(w, fmt.Sprintf("Error fetching detailed killmails: %s", waitErr), http.StatusInternalServerError)
+		return nil, false
+	}
+	return data, true
+}
+
+// JobStatusHandler reports the state of a data job so the loading page can poll it.
+func JobStatusHandler(orchestrateService *service.OrchestrateService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := orchestrateService.Jobs.Job(mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(job.Status()); err != nil {
+			orchestrateService.Logger.Errorf("Error encoding job status: %v", err)
+		}
+	}
+}
+
+// jobEventsHeartbeat is how often an idle event stream sends a comment to keep proxies from closing it.
+const jobEventsHeartbeat = 15 * time.Second
+
+// JobEventsHandler streams a data job's progress as Server-Sent Events. Each snapshot is sent as a
+// progress event, and the stream ends with a done or failed event carrying the job's final status.
+func JobEventsHandler(orchestrateService *service.OrchestrateService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := orchestrateService.Jobs.Job(mux.Vars(r)["id"])
+		if !ok {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		events, unsubscribe := job.Subscribe()
+		defer unsubscribe()
+		heartbeat := time.NewTicker(jobEventsHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event := <-events:
+				if err := writeServerSentEvent(w, "progress", event); err != nil {
+					return
+				}
+			case <-job.Done():
+				status := job.Status()
+				name := "done"
+				if status.State == service.JobFailed {
+					name = "failed"
+				}
+				writeServerSentEvent(w, name, status)
+				flusher.Flush()
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+	return err
+}
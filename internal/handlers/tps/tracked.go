@@ -0,0 +1,147 @@
+package tps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/handlers"
+	"github.com/guarzo/zkillanalytics/internal/service"
+)
+
+// trackedAddRequest adds entities to a tracked list by ID, by exact name, or both.
+type trackedAddRequest struct {
+	IDs   []int    `json:"ids"`
+	Names []string `json:"names"`
+}
+
+// trackedResponse is the tracked entities after a change, with the IDs the change added.
+type trackedResponse struct {
+	Tracked config.TrackedEntities `json:"tracked"`
+	Added   []int                  `json:"added,omitempty"`
+}
+
+// GetTrackedHandler returns the entities in effect for the group in the path.
+func GetTrackedHandler(trackingService *service.TrackingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tracked, ok := config.Group(requestGroup(r))
+		if !ok {
+			handlers.WriteJSONError(w, "Unknown group", requestGroup(r), http.StatusNotFound, trackingService.Logger)
+			return
+		}
+		handlers.WriteJSONResponse(w, tracked, http.StatusOK, trackingService.Logger)
+	}
+}
+
+// ReplaceTrackedHandler replaces every list of the group in the path with the ones in the request body.
+func ReplaceTrackedHandler(trackingService *service.TrackingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var replacement config.TrackedEntities
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&replacement); err != nil {
+			handlers.WriteJSONError(w, "Invalid request payload", err.Error(), http.StatusBadRequest, trackingService.Logger)
+			return
+		}
+
+		tracked, err := trackingService.Update(requestGroup(r), func(current *config.TrackedEntities) error {
+			*current = replacement
+			return nil
+		})
+		if err != nil {
+			handlers.WriteJSONError(w, "Failed to replace tracked entities", err.Error(), http.StatusBadRequest, trackingService.Logger)
+			return
+		}
+		handlers.WriteJSONResponse(w, trackedResponse{Tracked: tracked}, http.StatusOK, trackingService.Logger)
+	}
+}
+
+// AddTrackedHandler adds entities to the tracked list named in the path, in the path's group. Names are resolved through ESI,
+// and nothing is added if any of them cannot be.
+func AddTrackedHandler(trackingService *service.TrackingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := mux.Vars(r)["list"]
+		if !slices.Contains(service.TrackedListNames, list) {
+			handlers.WriteJSONError(w, "Unknown tracked list", list, http.StatusNotFound, trackingService.Logger)
+			return
+		}
+
+		var request trackedAddRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			handlers.WriteJSONError(w, "Invalid request payload", err.Error(), http.StatusBadRequest, trackingService.Logger)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+		resolved, unresolved, err := trackingService.ResolveNames(ctx, list, request.Names)
+		if err != nil {
+			handlers.WriteJSONError(w, "Failed to resolve names", err.Error(), http.StatusBadGateway, trackingService.Logger)
+			return
+		}
+		if len(unresolved) > 0 {
+			handlers.WriteJSONError(w, fmt.Sprintf("No %s found named", strings.TrimSuffix(list, "s")), strings.Join(unresolved, ", "), http.StatusBadRequest, trackingService.Logger)
+			return
+		}
+
+		var added []int
+		tracked, err := trackingService.Update(requestGroup(r), func(current *config.TrackedEntities) error {
+			ids, err := service.TrackedList(current, list)
+			if err != nil {
+				return err
+			}
+			for _, id := range append(request.IDs, resolved...) {
+				if !slices.Contains(*ids, id) && !slices.Contains(added, id) {
+					added = append(added, id)
+				}
+			}
+			*ids = append(*ids, added...)
+			return nil
+		})
+		if err != nil {
+			handlers.WriteJSONError(w, "Failed to add tracked entities", err.Error(), http.StatusBadRequest, trackingService.Logger)
+			return
+		}
+		trackingService.Logger.Infof("Added %v to tracked %s of group %s", added, list, requestGroup(r))
+		handlers.WriteJSONResponse(w, trackedResponse{Tracked: tracked, Added: added}, http.StatusOK, trackingService.Logger)
+	}
+}
+
+// RemoveTrackedHandler removes the entity in the path from the tracked list and group named in it.
+func RemoveTrackedHandler(trackingService *service.TrackingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil || id <= 0 {
+			handlers.WriteJSONError(w, "Invalid identifier format", vars["id"], http.StatusBadRequest, trackingService.Logger)
+			return
+		}
+
+		tracked, err := trackingService.Update(requestGroup(r), func(current *config.TrackedEntities) error {
+			ids, err := service.TrackedList(current, vars["list"])
+			if err != nil {
+				return err
+			}
+			index := slices.Index(*ids, id)
+			if index < 0 {
+				return fmt.Errorf("%d is not in tracked %s", id, vars["list"])
+			}
+			*ids = slices.Delete(*ids, index, index+1)
+			return nil
+		})
+		if err != nil {
+			handlers.WriteJSONError(w, "Failed to remove tracked entity", err.Error(), http.StatusNotFound, trackingService.Logger)
+			return
+		}
+		trackingService.Logger.Infof("Removed %d from tracked %s of group %s", id, vars["list"], requestGroup(r))
+		handlers.WriteJSONResponse(w, trackedResponse{Tracked: tracked}, http.StatusOK, trackingService.Logger)
+	}
+}
@@ -2,7 +2,6 @@ package tps
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
@@ -31,33 +30,36 @@ func getDataMode(modeStr, lastPart string) config.DataMode {
 	return dataMode
 }
 
-func generateFilePath(dir string, route config.Route, startDate, endDate string) string {
+// generateFilePath names a group's chart file, hashing the group with its tracked and excluded IDs so
+// groups never share a chart and editing a group's entities renders it afresh.
+func generateFilePath(dir string, group string, route config.Route, startDate, endDate string) string {
+	tracked, _ := config.Group(group)
 	return persist.GenerateChartFileName(dir, config.RouteToString[route], startDate, endDate,
-		persist.HashParams(persist.IntSliceToString(config.CorporationIDs)+persist.IntSliceToString(config.AllianceIDs)+persist.IntSliceToString(config.CharacterIDs)))
+		persist.HashParams(group+persist.IntSliceToString(tracked.Corporations)+persist.IntSliceToString(tracked.Alliances)+
+			persist.IntSliceToString(tracked.Characters)+persist.IntSliceToString(tracked.ExcludeCharacters)))
 }
 
-func generateChart(orchestrator *service.OrchestrateService, route config.Route, chartData *model.ChartData, filePath string, w http.ResponseWriter) error {
+// generateChart renders the chart file from the data for the chart's range, the last month and the month to
+// date. It writes an error itself and returns false if rendering fails.
+func generateChart(w http.ResponseWriter, orchestrator *service.OrchestrateService, route config.Route, chartData, lastMonthData, mtdData *model.ChartData, filePath string) bool {
 	fmt.Println("Generating chart for", config.RouteToString[route])
 	switch route {
 	//case persist-trust.Config:
 	//	configHandler(w)
 	//	return nil
 	default:
-		orchestrator.Logger.Infof("Fetching data for %v", route)
-		lastMonthData, err := fetchDataForSnippets(orchestrator, config.PreviousMonth)
-		if err != nil {
-			return err
-		}
-		mtdData, err := fetchDataForSnippets(orchestrator, config.MonthToDate)
-		if err != nil {
-			return err
-		}
 		orchestrator.Logger.Infof("Rendering chart for %v", route)
-		return visuals.RenderCharts(orchestrator, chartData, lastMonthData, mtdData, filePath)
+		if err := visuals.RenderCharts(orchestrator, chartData, lastMonthData, mtdData, filePath); err != nil {
+			http.Error(w, fmt.Sprintf("Error creating bar chart: %s", err), http.StatusInternalServerError)
+			return false
+		}
+		return true
 	}
 }
 
-func fetchDataForSnippets(orchestrator *service.OrchestrateService, dataMode config.DataMode) (*model.ChartData, error) {
+// snippetRange returns the date range of a snippet shown beside the main chart, so its data can be
+// submitted along with the chart's own.
+func snippetRange(orchestrator *service.OrchestrateService, dataMode config.DataMode) (time.Time, time.Time, error) {
 	// Retrieve initial date range in string format
 	startDateStr, endDateStr := persist.GetDateRange(dataMode)
 
@@ -65,12 +67,12 @@ func fetchDataForSnippets(orchestrator *service.OrchestrateService, dataMode con
 	startDate, err := time.Parse("2006-01-02", startDateStr)
 	if err != nil {
 		orchestrator.Logger.Errorf("Invalid start date format: %v", err)
-		return nil, err
+		return time.Time{}, time.Time{}, err
 	}
 	endDate, err := time.Parse("2006-01-02", endDateStr)
 	if err != nil {
 		orchestrator.Logger.Errorf("Invalid end date format: %v", err)
-		return nil, err
+		return time.Time{}, time.Time{}, err
 	}
 
 	// Adjust date ranges explicitly for each DataMode
@@ -92,11 +94,12 @@ func fetchDataForSnippets(orchestrator *service.OrchestrateService, dataMode con
 		orchestrator.Logger.Infof("Expected MTD Data: %s to %s", startDateStr, endDateStr)
 	}
 
-	// Fetch data with adjusted date range
-	return orchestrator.GetAllData(context.TODO(), config.CorporationIDs, config.AllianceIDs, config.CharacterIDs, startDateStr, endDateStr)
+	return startDate, endDate, nil
 }
 
-func LoadingHandler(w http.ResponseWriter, r *http.Request) {
+// LoadingHandler renders the loading page, which follows the given jobs in turn and, once all of them finish,
+// reloads the page with their IDs so it collects their results instead of submitting the jobs again.
+func LoadingHandler(w http.ResponseWriter, r *http.Request, jobIDs []string) {
 	fmt.Println("loading page redirect")
 
 	// Parse template
@@ -110,7 +113,7 @@ func LoadingHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Execute the template into a buffer first
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, nil); err != nil {
+	if err := tmpl.Execute(&buf, map[string][]string{"JobIDs": jobIDs}); err != nil {
 		fmt.Printf("Error executing template %s: %v\n", tmplPath, err)
 		http.Error(w, "Failed to render loading page", http.StatusInternalServerError)
 		return
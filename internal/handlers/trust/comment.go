@@ -3,7 +3,6 @@ package trust
 import (
 	"encoding/json"
 	"github.com/guarzo/zkillanalytics/internal/handlers"
-	"github.com/guarzo/zkillanalytics/internal/persist"
 	"github.com/guarzo/zkillanalytics/internal/xlog"
 	"net/http"
 )
@@ -29,7 +28,7 @@ func UpdateCommentHandler(s *handlers.SessionService) http.HandlerFunc {
 		}
 		xlog.Logf("Received Update Comment request for: %v", request)
 
-		data, err := persist.LoadTrustedCharacters()
+		data, err := s.Trusted.LoadTrusted()
 		if err != nil {
 			xlog.Logf("Error loading trusted characters: %v", err)
 			sendJSONError(w, "Error loading trusted characters", http.StatusInternalServerError)
@@ -71,7 +70,7 @@ func UpdateCommentHandler(s *handlers.SessionService) http.HandlerFunc {
 			return
 		}
 
-		if err := persist.SaveTrustedCharacters(data); err != nil {
+		if err := s.Trusted.SaveTrusted(data); err != nil {
 			xlog.Logf("Error saving trusted characters: %v", err)
 			sendJSONError(w, "Error saving trusted characters", http.StatusInternalServerError)
 			return
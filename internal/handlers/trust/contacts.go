@@ -60,7 +60,7 @@ func AddContactsHandler(s *handlers.SessionService, esiService *service.EsiServi
 		}
 		sessionValues := handlers.GetSessionValues(session)
 
-		token, err := persist.LoadIdentityToken(sessionValues.LoggedInUser, request.CharacterID, host)
+		token, err := persist.LoadIdentityToken(s.Identities, sessionValues.LoggedInUser, request.CharacterID, host)
 		if err != nil {
 			xlog.Logf("Error loading identity token for CharacterID %v: %v", request.CharacterID, err)
 			sendJSONError(w, fmt.Sprintf("Character token not found: %v", err), http.StatusInternalServerError)
@@ -69,7 +69,7 @@ func AddContactsHandler(s *handlers.SessionService, esiService *service.EsiServi
 		xlog.Logf("Loaded token for CharacterID %v: %+v", request.CharacterID, token)
 
 		// Load trusted contacts
-		trustedData, err := persist.LoadTrustedCharacters()
+		trustedData, err := s.Trusted.LoadTrusted()
 		if err != nil {
 			xlog.Logf("Error loading trusted contacts: %v", err)
 			sendJSONError(w, "Failed to load trusted contacts", http.StatusInternalServerError)
@@ -131,7 +131,7 @@ func DeleteContactsHandler(s *handlers.SessionService, esiService *service.EsiSe
 		}
 		sessionValues := handlers.GetSessionValues(session)
 
-		token, err := persist.LoadIdentityToken(sessionValues.LoggedInUser, request.CharacterID, host)
+		token, err := persist.LoadIdentityToken(s.Identities, sessionValues.LoggedInUser, request.CharacterID, host)
 		if err != nil {
 			xlog.Logf("Error loading identity token for CharacterID %v: %v", request.CharacterID, err)
 			sendJSONError(w, fmt.Sprintf("Character token not found: %v", err), http.StatusInternalServerError)
@@ -140,7 +140,7 @@ func DeleteContactsHandler(s *handlers.SessionService, esiService *service.EsiSe
 		xlog.Logf("Loaded token for CharacterID %v: %+v", request.CharacterID, token)
 
 		// Load untrusted contacts
-		untrustedData, err := persist.LoadTrustedCharacters() // Corrected function
+		untrustedData, err := s.Trusted.LoadTrusted() // Corrected function
 		if err != nil {
 			xlog.Logf("Error loading untrusted contacts: %v", err)
 			sendJSONError(w, "Failed to load untrusted contacts", http.StatusInternalServerError)
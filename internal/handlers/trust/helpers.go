@@ -19,17 +19,18 @@ var (
 
 const Title = "Who to Trust?"
 
-func prepareHomeData(sessionValues handlers.SessionValues, identities map[int64]model.CharacterData) model.StoreData {
-	trustedCharacters, err := persist.LoadTrustedCharacters()
+func prepareHomeData(trusted persist.TrustedRepository, sessionValues handlers.SessionValues, identities map[int64]model.CharacterData) model.StoreData {
+	trustedCharacters, err := trusted.LoadTrusted()
 	if err != nil {
 		xlog.Logf("Error loading trusted characters %v", err)
+		trustedCharacters = &model.TrustedCharacters{}
 	}
 
 	return model.StoreData{
 		Title:                 Title,
 		LoggedIn:              true,
 		Identities:            identities,
-		TabulatorIdentities:   convertIdentitiesToTabulatorData(identities),
+		TabulatorIdentities:   convertIdentitiesToTabulatorData(trustedCharacters, identities),
 		MainIdentity:          sessionValues.LoggedInUser,
 		TrustedCharacters:     trustedCharacters.TrustedCharacters,
 		TrustedCorporations:   trustedCharacters.TrustedCorporations,
@@ -38,9 +39,7 @@ func prepareHomeData(sessionValues handlers.SessionValues, identities map[int64]
 	}
 }
 
-func isTrusted(character model.CharacterData) bool {
-	trustedCharacters, _ := persist.LoadTrustedCharacters()
-
+func isTrusted(trustedCharacters *model.TrustedCharacters, character model.CharacterData) bool {
 	for _, char := range trustedCharacters.TrustedCharacters {
 		if char.CharacterID == character.CharacterID {
 			return true
@@ -54,7 +53,7 @@ func isTrusted(character model.CharacterData) bool {
 	return false
 }
 
-func convertIdentitiesToTabulatorData(identities map[int64]model.CharacterData) []map[string]interface{} {
+func convertIdentitiesToTabulatorData(trustedCharacters *model.TrustedCharacters, identities map[int64]model.CharacterData) []map[string]interface{} {
 	var tabulatorData []map[string]interface{}
 
 	for id, characterData := range identities {
@@ -62,7 +61,7 @@ func convertIdentitiesToTabulatorData(identities map[int64]model.CharacterData)
 			"CharacterID":   characterData.CharacterID,
 			"CharacterName": characterData.CharacterName,
 			"Portrait":      characterData.Portrait,
-			"IsTrusted":     isTrusted(identities[id]),
+			"IsTrusted":     isTrusted(trustedCharacters, identities[id]),
 			"CorporationID": characterData.CorporationID,
 		}
 		tabulatorData = append(tabulatorData, row)
@@ -28,14 +28,14 @@ func HomeHandler(s *handlers.SessionService, esiService *service.EsiService) htt
 			return
 		}
 
-		identities, err := handlers.ValidateIdentities(session, esiService, r, w)
+		identities, err := handlers.ValidateIdentities(s, session, esiService, r, w)
 		if err != nil {
 			errorMessage := fmt.Sprintf("Failed to validate identities: %s", err.Error())
 			handlers.HandleErrorWithRedirect(w, r, errorMessage, "/logout")
 			return
 		}
 
-		data := prepareHomeData(sessionValues, identities)
+		data := prepareHomeData(s.Trusted, sessionValues, identities)
 		session.Values["trustedDataDirty"] = false
 
 		etag, err = handlers.UpdateAndStoreSession(data, etag, session, r, w)
@@ -13,7 +13,6 @@ import (
 
 	"github.com/guarzo/zkillanalytics/internal/handlers"
 	"github.com/guarzo/zkillanalytics/internal/model"
-	"github.com/guarzo/zkillanalytics/internal/persist"
 	"github.com/guarzo/zkillanalytics/internal/xlog"
 
 	"github.com/guarzo/zkillanalytics/internal/service"
@@ -30,7 +29,7 @@ type EntityData struct {
 }
 
 // Helper function to parse and resolve the identifier.
-func resolveIdentifier(identifier string, entityType string, mainIdentity int64, token *oauth2.Token, esiService *service.EsiService) (EntityData, error) {
+func resolveIdentifier(ctx context.Context, identifier string, entityType string, mainIdentity int64, token *oauth2.Token, esiService *service.EsiService) (EntityData, error) {
 	identifier = strings.TrimSpace(identifier)
 	if identifier == "" {
 		return EntityData{}, fmt.Errorf("identifier is empty")
@@ -48,9 +47,9 @@ func resolveIdentifier(identifier string, entityType string, mainIdentity int64,
 	var resolvedID int32
 	var err error
 	if entityType == "character" {
-		resolvedID, err = esiService.EsiClient.CharacterIDSearch(mainIdentity, identifier, token)
+		resolvedID, err = esiService.EsiClient.CharacterIDSearch(ctx, mainIdentity, identifier, token)
 	} else if entityType == "corporation" {
-		resolvedID, err = esiService.EsiClient.CorporationIDSearch(mainIdentity, identifier, token)
+		resolvedID, err = esiService.EsiClient.CorporationIDSearch(ctx, mainIdentity, identifier, token)
 		if err != nil {
 			esiService.Logger.Warnf("Failed to resolve %s identifier %s to an ID: %v", entityType, identifier, err)
 			return EntityData{}, fmt.Errorf("identifier resolution failed: %v", err)
@@ -93,7 +92,7 @@ func handleAddEntity(s *handlers.SessionService, trustedService *service.Trusted
 	}
 
 	// Resolve identifier
-	resolvedData, err := resolveIdentifier(request.Identifier, entityType, mainIdentity, &token, esiService)
+	resolvedData, err := resolveIdentifier(r.Context(), request.Identifier, entityType, mainIdentity, &token, esiService)
 	if err != nil {
 		trustedService.Logger.Warnf("Identifier resolution error: %v", err)
 		handlers.WriteJSONError(w, "Identifier resolution failed", request.Identifier, http.StatusBadRequest, trustedService.Logger)
@@ -101,7 +100,7 @@ func handleAddEntity(s *handlers.SessionService, trustedService *service.Trusted
 	}
 
 	// Fetch entity data after resolution
-	fetchedData, err := fetchEntityData(entityType, resolvedData, &token, esiService)
+	fetchedData, err := fetchEntityData(r.Context(), entityType, resolvedData, &token, esiService)
 	if err != nil {
 		trustedService.Logger.Errorf("Entity data fetching error: %v", err)
 		handlers.WriteJSONError(w, "Entity data retrieval failed", request.Identifier, http.StatusInternalServerError, trustedService.Logger)
@@ -109,7 +108,7 @@ func handleAddEntity(s *handlers.SessionService, trustedService *service.Trusted
 	}
 
 	// Retrieve 'AddedBy' information
-	addedBy, err := esiService.EsiClient.GetPublicCharacterData(mainIdentity, &token)
+	addedBy, err := esiService.EsiClient.GetPublicCharacterData(r.Context(), mainIdentity, &token)
 	if err != nil {
 		trustedService.Logger.Errorf("Error retrieving character data for AddedBy: %v", err)
 		handlers.WriteJSONError(w, "Failed to validate AddedBy character", request.Identifier, http.StatusInternalServerError, trustedService.Logger)
@@ -292,17 +291,17 @@ func RemoveUntrustedCorporationHandler(s *handlers.SessionService, trustedServic
 }
 
 // Utility function to fetch entity data
-func fetchEntityData(entityType string, data EntityData, token *oauth2.Token, esiService *service.EsiService) (EntityData, error) {
+func fetchEntityData(ctx context.Context, entityType string, data EntityData, token *oauth2.Token, esiService *service.EsiService) (EntityData, error) {
 	if entityType == "character" {
-		characterData, err := esiService.EsiClient.GetPublicCharacterData(data.ID, token)
+		characterData, err := esiService.EsiClient.GetPublicCharacterData(ctx, data.ID, token)
 		if err != nil {
 			return EntityData{}, fmt.Errorf("error retrieving character data: %v", err)
 		}
-		corpID, err := esiService.EsiClient.GetCharacterCorporation(data.ID, token)
+		corpID, err := esiService.EsiClient.GetCharacterCorporation(ctx, data.ID, token)
 		if err != nil {
 			return EntityData{}, fmt.Errorf("error retrieving character's corporation ID: %v", err)
 		}
-		corp, err := esiService.EsiClient.GetCorporationInfo(context.Background(), int(corpID))
+		corp, err := esiService.EsiClient.GetCorporationInfo(ctx, int(corpID))
 		if err != nil {
 			return EntityData{}, fmt.Errorf("error retrieving corporation info: %v", err)
 		}
@@ -311,7 +310,7 @@ func fetchEntityData(entityType string, data EntityData, token *oauth2.Token, es
 		data.CorporationName = corp.Name
 		return data, nil
 	} else if entityType == "corporation" {
-		corp, err := esiService.EsiClient.GetCorporationInfo(context.Background(), int(data.ID))
+		corp, err := esiService.EsiClient.GetCorporationInfo(ctx, int(data.ID))
 		if err != nil {
 			return EntityData{}, fmt.Errorf("error retrieving corporation name: %v", err)
 		}
@@ -341,7 +340,7 @@ func UpdateIsOnCouchHandler(s *handlers.SessionService) http.HandlerFunc {
 		xlog.Logf("Received Update IsOnCouch request: %+v", request)
 
 		// Load the data
-		data, err := persist.LoadTrustedCharacters()
+		data, err := s.Trusted.LoadTrusted()
 		if err != nil {
 			xlog.Logf("Error loading trusted characters: %v", err)
 			sendJSONError(w, "Error loading trusted characters", http.StatusInternalServerError)
@@ -371,7 +370,7 @@ func UpdateIsOnCouchHandler(s *handlers.SessionService) http.HandlerFunc {
 		}
 
 		// Save the updated data
-		if err := persist.SaveTrustedCharacters(data); err != nil {
+		if err := s.Trusted.SaveTrusted(data); err != nil {
 			xlog.Logf("Error saving trusted characters: %v", err)
 			sendJSONError(w, "Error saving trusted characters", http.StatusInternalServerError)
 			return
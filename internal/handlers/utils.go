@@ -52,7 +52,7 @@ func GetSessionIdentity(s *SessionService, r *http.Request, logger *logrus.Logge
 		return 0, oauth2.Token{}, fmt.Errorf("main identity not found")
 	}
 
-	token, err := persist.GetMainIdentityToken(mainIdentity, host)
+	token, err := persist.GetMainIdentityToken(s.Identities, mainIdentity, host)
 	if err != nil {
 		logger.Errorf("Error retrieving token for main identity: %v", err)
 		return 0, oauth2.Token{}, fmt.Errorf("failed to retrieve token")
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -53,11 +54,76 @@ type Namer interface {
 	GetName() string
 }
 
-// FailedCharacters represents a structure to hold failed character IDs.
+// UniverseName is one entry returned by ESI's bulk /universe/names/ endpoint.
+type UniverseName struct {
+	Category string `json:"category"`
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+}
+
+// UniverseIDs is the subset of ESI's bulk /universe/ids/ response for characters, corporations and alliances.
+type UniverseIDs struct {
+	Characters   []UniverseID `json:"characters"`
+	Corporations []UniverseID `json:"corporations"`
+	Alliances    []UniverseID `json:"alliances"`
+}
+
+// UniverseID is a name ESI resolved to an ID.
+type UniverseID struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// CharacterAffiliation is one entry returned by ESI's bulk /characters/affiliation/ endpoint.
+type CharacterAffiliation struct {
+	AllianceID    int `json:"alliance_id"`
+	CharacterID   int `json:"character_id"`
+	CorporationID int `json:"corporation_id"`
+	FactionID     int `json:"faction_id"`
+}
+
+// FailedCharacters represents a structure to hold failed character IDs. One list is shared by every
+// ESI client, so once loaded it is read and changed through its methods.
 type FailedCharacters struct {
+	mu           sync.RWMutex
 	CharacterIDs map[int]bool `json:"character_ids"`
 }
 
+// Contains reports whether characterID is on the list.
+func (f *FailedCharacters) Contains(characterID int) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.CharacterIDs[characterID]
+}
+
+// Add puts characterIDs on the list and returns a copy of the list to save.
+func (f *FailedCharacters) Add(characterIDs ...int) *FailedCharacters {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.CharacterIDs == nil {
+		f.CharacterIDs = make(map[int]bool)
+	}
+	for _, id := range characterIDs {
+		f.CharacterIDs[id] = true
+	}
+	return f.copyLocked()
+}
+
+// Copy returns a copy of the list that can be saved while others add to it.
+func (f *FailedCharacters) Copy() *FailedCharacters {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.copyLocked()
+}
+
+func (f *FailedCharacters) copyLocked() *FailedCharacters {
+	failed := &FailedCharacters{CharacterIDs: make(map[int]bool, len(f.CharacterIDs))}
+	for id, value := range f.CharacterIDs {
+		failed.CharacterIDs[id] = value
+	}
+	return failed
+}
+
 // NotFoundError is a custom error type for representing 404 errors.
 type NotFoundError struct {
 	CharacterID int
@@ -0,0 +1,63 @@
+package model
+
+// Item is an item on a victim's ship as reported by ESI. Containers hold their contents in Items.
+type Item struct {
+	ItemTypeID        int    `json:"item_type_id"`
+	Flag              int    `json:"flag"`
+	Singleton         int    `json:"singleton"`
+	QuantityDropped   int64  `json:"quantity_dropped,omitempty"`
+	QuantityDestroyed int64  `json:"quantity_destroyed,omitempty"`
+	Items             []Item `json:"items,omitempty"`
+}
+
+// Quantity returns how many of the item were on the ship, dropped or destroyed.
+func (i Item) Quantity() int64 {
+	return i.QuantityDropped + i.QuantityDestroyed
+}
+
+// Slot groups inventory flags by where an item sits on a ship.
+type Slot string
+
+const (
+	SlotHigh      Slot = "high"
+	SlotMid       Slot = "mid"
+	SlotLow       Slot = "low"
+	SlotRig       Slot = "rig"
+	SlotSubsystem Slot = "subsystem"
+	SlotDroneBay  Slot = "drone_bay"
+	SlotFighter   Slot = "fighter_bay"
+	SlotCargo     Slot = "cargo"
+	SlotImplant   Slot = "implant"
+	SlotOther     Slot = "other"
+)
+
+// FittingSlots lists the slots in the order a fit is usually displayed.
+var FittingSlots = []Slot{SlotHigh, SlotMid, SlotLow, SlotRig, SlotSubsystem, SlotDroneBay, SlotFighter, SlotCargo, SlotImplant, SlotOther}
+
+// SlotForFlag maps an EVE inventory flag to its slot.
+func SlotForFlag(flag int) Slot {
+	switch {
+	case flag >= 27 && flag <= 34: // HiSlot0-7
+		return SlotHigh
+	case flag >= 19 && flag <= 26: // MedSlot0-7
+		return SlotMid
+	case flag >= 11 && flag <= 18: // LoSlot0-7
+		return SlotLow
+	case flag >= 92 && flag <= 99: // RigSlot0-7
+		return SlotRig
+	case flag >= 125 && flag <= 132: // SubSystemSlot0-7
+		return SlotSubsystem
+	case flag == 87: // DroneBay
+		return SlotDroneBay
+	case flag == 158 || (flag >= 159 && flag <= 163): // FighterBay and launch tubes
+		return SlotFighter
+	case flag == 89: // Implant
+		return SlotImplant
+	case flag == 5, // Cargo
+		flag >= 133 && flag <= 155, // Specialized holds and the fleet hangar
+		flag >= 176 && flag <= 179: // Later specialized holds
+		return SlotCargo
+	default:
+		return SlotOther
+	}
+}
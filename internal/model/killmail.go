@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -34,10 +35,11 @@ type Attacker struct {
 }
 
 type Victim struct {
-	CharacterID   int           `json:"character_id"`
-	CorporationID int           `json:"corporation_id"`
-	DamageTaken   int           `json:"damage_taken"`
-	Items         []interface{} `json:"items"`
+	AllianceID    int    `json:"alliance_id"`
+	CharacterID   int    `json:"character_id"`
+	CorporationID int    `json:"corporation_id"`
+	DamageTaken   int    `json:"damage_taken"`
+	Items         []Item `json:"items"`
 	Position      struct {
 		X float64 `json:"x"`
 		Y float64 `json:"y"`
@@ -60,8 +62,39 @@ type DetailedKillMail struct {
 	EsiKillMail
 }
 
+// detailedKillMailJSON is the stored form of a DetailedKillMail. KillMail and EsiKillMail both tag their
+// ID as killmail_id, which encoding/json resolves by dropping it, so the ID is written once from here.
+type detailedKillMailJSON struct {
+	EsiKillMail
+	ZKB ZKB `json:"zkb"`
+}
+
+// MarshalJSON writes the ESI killmail fields alongside the zkb block, keeping killmail_id.
+func (d DetailedKillMail) MarshalJSON() ([]byte, error) {
+	stored := detailedKillMailJSON{EsiKillMail: d.EsiKillMail, ZKB: d.ZKB}
+	if stored.KillMailID == 0 {
+		stored.KillMailID = int(d.KillMail.KillMailID)
+	}
+	return json.Marshal(stored)
+}
+
+// UnmarshalJSON reads a stored DetailedKillMail, setting the ID on both halves.
+func (d *DetailedKillMail) UnmarshalJSON(data []byte) error {
+	var stored detailedKillMailJSON
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	d.EsiKillMail = stored.EsiKillMail
+	d.KillMail = KillMail{KillMailID: int64(stored.KillMailID), ZKB: stored.ZKB}
+	return nil
+}
+
 type KillMailData struct {
 	KillMails []DetailedKillMail
+
+	// Unhydrated maps a feed's cursor key to the lowest killmail ID on it whose ESI details could not
+	// be fetched, so the feed's cursor can be held below it until a later refresh hydrates it.
+	Unhydrated map[string]int64
 }
 
 type ESIData struct {
@@ -73,4 +106,19 @@ type ESIData struct {
 type ChartData struct {
 	KillMails []DetailedKillMail
 	ESIData
+
+	// Group is the tracked group the killmails were collected for
+	Group string
+}
+
+// FetchCursor records the newest killmail seen for one tracked entity feed.
+type FetchCursor struct {
+	LastKillMailID   int64     `json:"last_killmail_id"`
+	LastKillMailTime time.Time `json:"last_killmail_time"`
+}
+
+// MonthCursors holds the fetch cursors for a month, keyed by feed (e.g. "kills:corporation:123").
+type MonthCursors struct {
+	RefreshedAt time.Time              `json:"refreshed_at"`
+	Cursors     map[string]FetchCursor `json:"cursors"`
 }
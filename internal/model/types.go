@@ -17,6 +17,31 @@ type InvType struct {
 	Name string
 }
 
+// TypeInfo describes an item type from the static data export.
+type TypeInfo struct {
+	TypeID        int
+	Name          string
+	GroupID       int
+	GroupName     string
+	CategoryID    int
+	CategoryName  string
+	MarketGroupID int
+	Volume        float64
+}
+
+// SolarSystem describes a solar system from the static data export.
+// WormholeClassID is set for wormhole space and for k-space systems whose class is recorded.
+type SolarSystem struct {
+	SolarSystemID     int
+	Name              string
+	Security          float64
+	ConstellationID   int
+	ConstellationName string
+	RegionID          int
+	RegionName        string
+	WormholeClassID   int
+}
+
 type LootSplit struct {
 	TotalBuyPrice string            `json:"totalBuyPrice"`
 	SplitDetails  map[string]Amount `json:"splitDetails"` // Custom type to handle mixed input
@@ -0,0 +1,216 @@
+package persist
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Backups of a file are kept in this directory alongside it
+	backupDirectory  = ".backups"
+	backupTimeFormat = "20060102T150405.000000000"
+
+	// DefaultBackupsKept is how many backups of each file are kept when none is configured.
+	DefaultBackupsKept = 10
+)
+
+// BackupsKept is how many timestamped backups of each file WriteFileAtomic keeps.
+var BackupsKept = DefaultBackupsKept
+
+// Backup is a previous version of a persisted file.
+type Backup struct {
+	ID   string
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// WriteFileAtomic replaces filename with data so that a crash or full disk leaves either the old or
+// the new contents. The data is written to a temporary file in the same directory, fsynced and renamed
+// into place. The current contents are first copied to a timestamped backup.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	if err := backupFile(filename); err != nil {
+		return err
+	}
+	return replaceFile(filename, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// replaceFile writes a file through write to a temporary file, fsyncs it and renames it over filename.
+func replaceFile(filename string, perm os.FileMode, write func(io.Writer) error) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directories for %s: %v", filename, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", filename, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions on %s: %w", filename, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", filename, err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes a rename in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !os.IsPermission(err) {
+		return fmt.Errorf("failed to sync directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+// backupFile copies filename's current contents to a new backup and prunes the oldest beyond BackupsKept.
+// A missing file has nothing to back up.
+func backupFile(filename string) error {
+	if BackupsKept <= 0 {
+		return nil
+	}
+
+	src, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s for backup: %w", filename, err)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	backupPath := filepath.Join(backupDir(filename), filepath.Base(filename)+"."+time.Now().UTC().Format(backupTimeFormat))
+	err = replaceFile(backupPath, info.Mode().Perm(), func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", filename, err)
+	}
+
+	backups, err := ListBackups(filename)
+	if err != nil {
+		return err
+	}
+	for _, old := range backups[min(len(backups), BackupsKept):] {
+		if err := os.Remove(old.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune backup %s: %w", old.Path, err)
+		}
+	}
+	return nil
+}
+
+// ListBackups returns the backups of filename, newest first.
+func ListBackups(filename string) ([]Backup, error) {
+	entries, err := os.ReadDir(backupDir(filename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(filename) + "."
+	var backups []Backup
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		id := strings.TrimPrefix(entry.Name(), prefix)
+		backupTime, err := time.Parse(backupTimeFormat, id)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{
+			ID:   id,
+			Path: filepath.Join(backupDir(filename), entry.Name()),
+			Time: backupTime,
+			Size: info.Size(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Time.After(backups[j].Time) })
+	return backups, nil
+}
+
+// RestoreBackup replaces filename with the backup with the given ID. The version being replaced
+// is itself backed up, so a restore can be undone.
+func RestoreBackup(filename, id string) error {
+	backups, err := ListBackups(filename)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if backup.ID != id {
+			continue
+		}
+		data, err := os.ReadFile(backup.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read backup %s: %w", backup.Path, err)
+		}
+		perm := os.FileMode(0644)
+		if info, err := os.Stat(backup.Path); err == nil {
+			perm = info.Mode().Perm()
+		}
+		return WriteFileAtomic(filename, data, perm)
+	}
+	return fmt.Errorf("no backup %s of %s", id, filename)
+}
+
+// RemoveFileAndBackups removes filename along with every backup of it. A missing file is not an error,
+// so backups left behind by an earlier failed removal are still cleared.
+func RemoveFileAndBackups(filename string) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	backups, err := ListBackups(filename)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if err := os.Remove(backup.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove backup %s: %w", backup.Path, err)
+		}
+	}
+	return nil
+}
+
+func backupDir(filename string) string {
+	return filepath.Join(filepath.Dir(filename), backupDirectory)
+}
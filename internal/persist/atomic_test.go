@@ -0,0 +1,118 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		kept   int
+		// wantBackups are the backed up contents, newest first
+		wantBackups []string
+	}{
+		{name: "new file", writes: []string{"a"}, kept: 3},
+		{name: "each write backs up the last", writes: []string{"a", "b", "c"}, kept: 3, wantBackups: []string{"b", "a"}},
+		{name: "oldest backups pruned", writes: []string{"a", "b", "c", "d"}, kept: 2, wantBackups: []string{"c", "b"}},
+		{name: "backups disabled", writes: []string{"a", "b"}, kept: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(kept int) { BackupsKept = kept }(BackupsKept)
+			BackupsKept = tt.kept
+			path := filepath.Join(t.TempDir(), "state.json")
+
+			for _, data := range tt.writes {
+				if err := WriteFileAtomic(path, []byte(data), 0644); err != nil {
+					t.Fatalf("WriteFileAtomic(%q): %v", data, err)
+				}
+			}
+			assertFileContents(t, path, tt.writes[len(tt.writes)-1])
+
+			backups, err := ListBackups(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(backups) != len(tt.wantBackups) {
+				t.Fatalf("got %d backups, want %d", len(backups), len(tt.wantBackups))
+			}
+			for i, backup := range backups {
+				assertFileContents(t, backup.Path, tt.wantBackups[i])
+			}
+		})
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	for _, data := range []string{"old", "new"} {
+		if err := WriteFileAtomic(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := ListBackups(path)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("ListBackups = %d backups, %v; want 1", len(backups), err)
+	}
+
+	if err := RestoreBackup(path, backups[0].ID); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	assertFileContents(t, path, "old")
+
+	// The restored-over version is kept, so the restore can be undone
+	backups, err = ListBackups(path)
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("ListBackups after restore = %d backups, %v; want 2", len(backups), err)
+	}
+	assertFileContents(t, backups[0].Path, "new")
+
+	if err := RestoreBackup(path, "19700101T000000.000000000"); err == nil {
+		t.Error("restoring an unknown backup succeeded")
+	}
+}
+
+func TestRemoveFileAndBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	other := filepath.Join(dir, "other.json")
+	for _, p := range []string{path, path, other, other} {
+		if err := WriteFileAtomic(p, []byte(filepath.Base(p)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RemoveFileAndBackups(path); err != nil {
+		t.Fatalf("RemoveFileAndBackups: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file still exists: %v", err)
+	}
+	if backups, err := ListBackups(path); err != nil || len(backups) != 0 {
+		t.Errorf("ListBackups = %d backups, %v; want none", len(backups), err)
+	}
+	// Backups of other files in the directory are left alone
+	if backups, err := ListBackups(other); err != nil || len(backups) != 1 {
+		t.Errorf("ListBackups(other) = %d backups, %v; want 1", len(backups), err)
+	}
+
+	// Removing again is not an error
+	if err := RemoveFileAndBackups(path); err != nil {
+		t.Errorf("second RemoveFileAndBackups: %v", err)
+	}
+}
+
+func assertFileContents(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s holds %q, want %q", filepath.Base(path), got, want)
+	}
+}
@@ -1,97 +1,408 @@
 package persist
 
 import (
+	"container/list"
+	"encoding/binary"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/patrickmn/go-cache"
 	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
 )
 
 const cacheDirectory = "data/tps/cache"
 const defaultExpiration = 25 * time.Hour
-const cleanupInterval = 26 * time.Hour
 
-// Cache struct to manage in-memory caching with optional persistence.
+// DefaultCacheMaxBytes caps the cache when no size is configured.
+const DefaultCacheMaxBytes = 1024 * 1024 * 1024
+
+// Each entry is stored as its expiry and write time, in Unix nanoseconds, followed by the value
+const cacheHeaderSize = 16
+
+const otherNamespace = "other"
+
+// cacheNamespaces are the key prefixes given their own bucket and share of the size cap.
+// Keys without one of these prefixes share the remainder.
+var cacheNamespaces = map[string]float64{
+	"zkill":    0.5,
+	"esi":      0.4,
+	"portrait": 0.05,
+}
+
+// CacheStats reports one namespace's size and activity since the cache was opened.
+type CacheStats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// Cache is a durable key-value cache kept in an embedded bbolt database. Each namespace
+// (the key prefix before the first colon) is capped in size and evicts its least recently used entries.
 type Cache struct {
-	cache  *cache.Cache
+	db     *bolt.DB
 	Logger *logrus.Logger
+
+	mu         sync.Mutex
+	namespaces map[string]*cacheNamespace // fixed once the cache is open
+}
+
+// cacheNamespace tracks a namespace's entries in least recently used order. Writers hold writeMu from
+// choosing what to change until the index matches the database, so both see writes in the same order.
+type cacheNamespace struct {
+	writeMu sync.Mutex
+
+	order   *list.List
+	entries map[string]*list.Element
+	stats   CacheStats
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// OpenCache opens or creates the cache database, dropping expired entries. maxBytes caps the total
+// size of cached values and is divided between namespaces. A cache.json left by the previous
+// in-memory cache in the same directory is imported and removed.
+func OpenCache(fileName string, maxBytes int64, logger *logrus.Logger) (*Cache, error) {
+	if err := os.MkdirAll(GenerateRelativeDirectoryPath(cacheDirectory), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultCacheMaxBytes
+	}
+
+	db, err := bolt.Open(fileName, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache %s: %w", fileName, err)
+	}
+
+	c := &Cache{
+		db:         db,
+		Logger:     logger,
+		namespaces: make(map[string]*cacheNamespace),
+	}
+	remaining := 1.0
+	for name, share := range cacheNamespaces {
+		c.namespaces[name] = newCacheNamespace(int64(float64(maxBytes) * share))
+		remaining -= share
+	}
+	c.namespaces[otherNamespace] = newCacheNamespace(int64(float64(maxBytes) * remaining))
+
+	if err := c.loadIndex(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := c.importLegacyFile(); err != nil {
+		logger.Warnf("Failed to import legacy cache file: %v", err)
+	}
+	return c, nil
 }
 
-func NewCache(logger *logrus.Logger) *Cache {
-	return &Cache{
-		cache:  cache.New(defaultExpiration, cleanupInterval),
-		Logger: logger,
+func newCacheNamespace(maxBytes int64) *cacheNamespace {
+	return &cacheNamespace{
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		stats:   CacheStats{MaxBytes: maxBytes},
 	}
 }
 
+// loadIndex rebuilds the recency order from write times and removes expired entries.
+func (c *Cache) loadIndex() error {
+	now := time.Now()
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for name, ns := range c.namespaces {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return err
+			}
+
+			type stored struct {
+				entry   *cacheEntry
+				written int64
+			}
+			var live []stored
+			var expired [][]byte
+			err = bucket.ForEach(func(k, v []byte) error {
+				if len(v) < cacheHeaderSize {
+					expired = append(expired, append([]byte(nil), k...))
+					return nil
+				}
+				expires, written := decodeCacheHeader(v)
+				if !expires.IsZero() && now.After(expires) {
+					expired = append(expired, append([]byte(nil), k...))
+					return nil
+				}
+				live = append(live, stored{
+					entry:   &cacheEntry{key: string(k), size: int64(len(v) - cacheHeaderSize)},
+					written: written,
+				})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range expired {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+
+			// Oldest writes go to the back, to be evicted first
+			sort.Slice(live, func(i, j int) bool { return live[i].written > live[j].written })
+			for _, s := range live {
+				ns.entries[s.entry.key] = ns.order.PushBack(s.entry)
+				ns.stats.Bytes += s.entry.size
+			}
+			ns.stats.Entries = len(ns.entries)
+		}
+		return nil
+	})
+}
+
 // Get retrieves a value from the cache by key.
 func (c *Cache) Get(key string) ([]byte, bool) {
-	value, found := c.cache.Get(key)
-	if !found {
+	name := cacheNamespaceOf(key)
+
+	var value []byte
+	var expires time.Time
+	err := c.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(name)).Get([]byte(key))
+		if len(v) < cacheHeaderSize {
+			return nil
+		}
+		expires, _ = decodeCacheHeader(v)
+		value = append([]byte(nil), v[cacheHeaderSize:]...)
+		return nil
+	})
+	if err != nil {
+		c.Logger.Warnf("Failed to read cache key %s: %v", key, err)
+	}
+
+	c.mu.Lock()
+	ns := c.namespaces[name]
+	if value == nil || err != nil {
+		ns.stats.Misses++
+		c.mu.Unlock()
 		return nil, false
 	}
-	byteSlice, ok := value.([]byte)
-	return byteSlice, ok
+	if !expires.IsZero() && time.Now().After(expires) {
+		ns.stats.Misses++
+		c.mu.Unlock()
+		c.expire(name, key)
+		return nil, false
+	}
+	ns.stats.Hits++
+	if element, ok := ns.entries[key]; ok {
+		ns.order.MoveToFront(element)
+	}
+	c.mu.Unlock()
+	return value, true
 }
 
 // Set stores data in the cache with the associated key and expiration duration.
+// A zero expiration uses the default; a negative one never expires.
 func (c *Cache) Set(key string, value []byte, expiration time.Duration) {
-	c.cache.Set(key, value, expiration)
+	name := cacheNamespaceOf(key)
+	now := time.Now()
+
+	var expires time.Time
+	switch {
+	case expiration == 0:
+		expires = now.Add(defaultExpiration)
+	case expiration > 0:
+		expires = now.Add(expiration)
+	}
+
+	ns := c.namespaces[name]
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+
+	// Choose the evictions first and update the index only once the write has landed,
+	// so a failed write leaves the index as it was
+	entry := &cacheEntry{key: key, size: int64(len(value))}
+	c.mu.Lock()
+	evicted := ns.evictionsFor(entry)
+	c.mu.Unlock()
+
+	data := make([]byte, cacheHeaderSize+len(value))
+	encodeCacheHeader(data, expires, now)
+	copy(data[cacheHeaderSize:], value)
+
+	// Batch coalesces concurrent writers into one transaction
+	err := c.db.Batch(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if err := bucket.Put([]byte(key), data); err != nil {
+			return err
+		}
+		for _, k := range evicted {
+			if err := bucket.Delete([]byte(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Logger.Errorf("Failed to write cache key %s: %v", key, err)
+		return
+	}
+
+	c.mu.Lock()
+	c.removeLocked(ns, key)
+	ns.entries[key] = ns.order.PushFront(entry)
+	ns.stats.Bytes += entry.size
+	for _, k := range evicted {
+		c.removeLocked(ns, k)
+		ns.stats.Evictions++
+	}
+	ns.stats.Entries = len(ns.entries)
+	c.mu.Unlock()
 }
 
-// SaveToFile saves the entire cache to a file in JSON format.
-func (c *Cache) SaveToFile(filename string) error {
-	items := c.cache.Items()
+// evictionsFor returns the least recently used keys to drop so that entry fits under the namespace's cap.
+// The newest entry is always kept, even on its own over the cap. The caller holds c.mu.
+func (ns *cacheNamespace) evictionsFor(entry *cacheEntry) []string {
+	bytes := ns.stats.Bytes + entry.size
+	remaining := len(ns.entries) + 1
+	if element, ok := ns.entries[entry.key]; ok {
+		bytes -= element.Value.(*cacheEntry).size
+		remaining--
+	}
 
-	// Convert to map[string]cacheItem for serialization
-	serializable := make(map[string]cacheItem, len(items))
-	for k, v := range items {
-		byteSlice, ok := v.Object.([]byte)
-		if !ok {
-			c.Logger.Warnf("Skipping key %s as its value is not []byte", k)
+	var evicted []string
+	for element := ns.order.Back(); element != nil && bytes > ns.stats.MaxBytes && remaining > 1; element = element.Prev() {
+		oldest := element.Value.(*cacheEntry)
+		if oldest.key == entry.key {
 			continue
 		}
-		serializable[k] = cacheItem{
-			Value:      byteSlice,
-			Expiration: time.Unix(0, v.Expiration),
+		bytes -= oldest.size
+		remaining--
+		evicted = append(evicted, oldest.key)
+	}
+	return evicted
+}
+
+// Stats returns each namespace's size and activity.
+func (c *Cache) Stats() map[string]CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make(map[string]CacheStats, len(c.namespaces))
+	for name, ns := range c.namespaces {
+		stats[name] = ns.stats
+	}
+	return stats
+}
+
+// Close closes the cache database.
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+func (c *Cache) removeLocked(ns *cacheNamespace, key string) {
+	element, ok := ns.entries[key]
+	if !ok {
+		return
+	}
+	ns.stats.Bytes -= element.Value.(*cacheEntry).size
+	ns.order.Remove(element)
+	delete(ns.entries, key)
+	ns.stats.Entries = len(ns.entries)
+}
+
+// expire deletes key if it is still expired, as a Set since it was read may have replaced it.
+func (c *Cache) expire(name, key string) {
+	ns := c.namespaces[name]
+	ns.writeMu.Lock()
+	defer ns.writeMu.Unlock()
+
+	var deleted bool
+	err := c.db.Batch(func(tx *bolt.Tx) error {
+		// Batch may retry the function, so only its final run's result counts
+		deleted = false
+		bucket := tx.Bucket([]byte(name))
+		if v := bucket.Get([]byte(key)); len(v) >= cacheHeaderSize {
+			if expires, _ := decodeCacheHeader(v); expires.IsZero() || time.Now().Before(expires) {
+				return nil
+			}
 		}
+		deleted = true
+		return bucket.Delete([]byte(key))
+	})
+	if err != nil {
+		c.Logger.Errorf("Failed to delete cache key %s: %v", key, err)
+		return
+	}
+	if deleted {
+		c.mu.Lock()
+		c.removeLocked(ns, key)
+		c.mu.Unlock()
 	}
-	return WriteJSONToFile(filename, serializable)
 }
 
-// LoadFromFile loads the cache from a JSON file.
-func (c *Cache) LoadFromFile(filename string) error {
-	var serializable map[string]cacheItem
-	if err := ReadJSONFromFile(filename, &serializable); err != nil {
+// importLegacyFile loads the JSON dump written by the previous in-memory cache, then removes it.
+func (c *Cache) importLegacyFile() error {
+	fileName := fmt.Sprintf("%s/cache.json", GenerateRelativeDirectoryPath(cacheDirectory))
+
+	var legacy map[string]legacyCacheItem
+	if err := ReadJSONFromFile(fileName, &legacy); err != nil {
 		if os.IsNotExist(err) {
-			c.Logger.Warnf("Cache file does not exist: %s", filename)
 			return nil
 		}
 		return err
 	}
 
-	// Set each item in the cache with its expiration check
-	for k, item := range serializable {
-		ttl := time.Until(item.Expiration)
-		if ttl > 0 {
-			c.cache.Set(k, item.Value, ttl)
-		} else {
-			c.Logger.Infof("Skipping expired cache item: %s", k)
+	imported := 0
+	for key, item := range legacy {
+		if ttl := time.Until(item.Expiration); ttl > 0 {
+			c.Set(key, item.Value, ttl)
+			imported++
 		}
 	}
-	c.Logger.Infof("Cache successfully loaded from file: %s", filename)
-	return nil
+	c.Logger.Infof("Imported %d cache entries from %s", imported, fileName)
+	return os.Remove(fileName)
+}
+
+// legacyCacheItem is an entry of the JSON dump written by the previous in-memory cache.
+type legacyCacheItem struct {
+	Value      []byte
+	Expiration time.Time
 }
 
 // GenerateCacheDataFileName generates the filename for storing cache data.
 func GenerateCacheDataFileName() string {
-	return fmt.Sprintf("%s/cache.json", GenerateRelativeDirectoryPath(cacheDirectory))
+	return fmt.Sprintf("%s/cache.db", GenerateRelativeDirectoryPath(cacheDirectory))
 }
 
-// cacheItem represents an item stored in the cache with its expiration.
-type cacheItem struct {
-	Value      []byte
-	Expiration time.Time
+func cacheNamespaceOf(key string) string {
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		if _, ok := cacheNamespaces[key[:i]]; ok {
+			return key[:i]
+		}
+	}
+	return otherNamespace
+}
+
+func encodeCacheHeader(data []byte, expires, written time.Time) {
+	var expiresNano int64
+	if !expires.IsZero() {
+		expiresNano = expires.UnixNano()
+	}
+	binary.BigEndian.PutUint64(data[0:8], uint64(expiresNano))
+	binary.BigEndian.PutUint64(data[8:16], uint64(written.UnixNano()))
+}
+
+func decodeCacheHeader(data []byte) (time.Time, int64) {
+	var expires time.Time
+	if nano := int64(binary.BigEndian.Uint64(data[0:8])); nano != 0 {
+		expires = time.Unix(0, nano)
+	}
+	return expires, int64(binary.BigEndian.Uint64(data[8:16]))
 }
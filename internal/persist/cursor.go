@@ -0,0 +1,31 @@
+package persist
+
+import (
+	"os"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// GenerateCursorFileName creates the filename holding the default store's fetch cursors for a month.
+func GenerateCursorFileName(year, month int) string {
+	return DefaultKillMailStore.CursorFileName(year, month)
+}
+
+// LoadMonthCursors loads the default store's fetch cursors for a month, returning nil if none were recorded.
+func LoadMonthCursors(year, month int) (*model.MonthCursors, error) {
+	return DefaultKillMailStore.LoadMonthCursors(year, month)
+}
+
+// SaveMonthCursors saves the default store's fetch cursors for a month.
+func SaveMonthCursors(year, month int, cursors *model.MonthCursors) error {
+	return DefaultKillMailStore.SaveMonthCursors(year, month, cursors)
+}
+
+// DeleteMonthCursors removes the fetch cursors for a month, if any.
+func DeleteMonthCursors(year, month int) error {
+	err := os.Remove(GenerateCursorFileName(year, month))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package persist
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Named presets for ad-hoc date ranges.
+const (
+	PresetLast7Days       = "last-7-days"
+	PresetLast30Days      = "last-30-days"
+	PresetPreviousQuarter = "previous-quarter"
+)
+
+// DateRangePresets lists the presets PresetDateRange accepts.
+var DateRangePresets = []string{PresetLast7Days, PresetLast30Days, PresetPreviousQuarter}
+
+// MaxDateRangeDays is the longest ad-hoc range accepted, in days.
+const MaxDateRangeDays = 366
+
+// EarliestRangeDate is the first day an ad-hoc range may start on. The zero value allows ranges
+// from January 1st of the previous year.
+var EarliestRangeDate time.Time
+
+var isoWeekPattern = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+// DateRange is a span of whole days from Start through End, with a label for display.
+type DateRange struct {
+	Label string
+	Start time.Time
+	End   time.Time
+}
+
+// StartDate returns the first day of the range in YYYY-MM-DD form.
+func (d DateRange) StartDate() string {
+	return d.Start.Format("2006-01-02")
+}
+
+// EndDate returns the last day of the range in YYYY-MM-DD form.
+func (d DateRange) EndDate() string {
+	return d.End.Format("2006-01-02")
+}
+
+// CheckDateRangeLimits rejects ranges longer than MaxDateRangeDays or starting before the earliest allowed
+// date, since every month a range touches may have to be fetched from zKillboard on request.
+func CheckDateRangeLimits(d DateRange, now time.Time) error {
+	if days := int(d.End.Sub(d.Start).Hours()/24) + 1; days > MaxDateRangeDays {
+		return fmt.Errorf("%s spans %d days, more than the %d allowed", d.Label, days, MaxDateRangeDays)
+	}
+	earliest := EarliestRangeDate
+	if earliest.IsZero() {
+		earliest = time.Date(now.Year()-1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if d.Start.Before(earliest) {
+		return fmt.Errorf("%s starts before %s, the earliest date available", d.Label, earliest.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// ExplicitDateRange returns the range from one day through another, both in YYYY-MM-DD form.
+func ExplicitDateRange(from, to string) (DateRange, error) {
+	start, end, err := ParseDateRange(from, to)
+	if err != nil {
+		return DateRange{}, err
+	}
+	if end.Before(start) {
+		return DateRange{}, fmt.Errorf("end date %s is before start date %s", to, from)
+	}
+	return DateRange{Label: "Custom range", Start: start, End: end}, nil
+}
+
+// PresetDateRange returns a named preset's range as of now. Rolling presets end today.
+func PresetDateRange(preset string, now time.Time) (DateRange, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch preset {
+	case PresetLast7Days:
+		return DateRange{Label: "Last 7 days", Start: today.AddDate(0, 0, -6), End: today}, nil
+	case PresetLast30Days:
+		return DateRange{Label: "Last 30 days", Start: today.AddDate(0, 0, -29), End: today}, nil
+	case PresetPreviousQuarter:
+		quarterStart := time.Date(today.Year(), time.Month((int(today.Month())-1)/3*3+1), 1, 0, 0, 0, 0, time.UTC)
+		start := quarterStart.AddDate(0, -3, 0)
+		return DateRange{
+			Label: fmt.Sprintf("Q%d %d", (int(start.Month())-1)/3+1, start.Year()),
+			Start: start,
+			End:   quarterStart.AddDate(0, 0, -1),
+		}, nil
+	}
+	return DateRange{}, fmt.Errorf("unknown preset %q", preset)
+}
+
+// ISOWeekDateRange returns Monday through Sunday of an ISO 8601 week written like 2024-W05.
+func ISOWeekDateRange(week string) (DateRange, error) {
+	match := isoWeekPattern.FindStringSubmatch(week)
+	if match == nil {
+		return DateRange{}, fmt.Errorf("invalid ISO week %q, expected YYYY-Www", week)
+	}
+	year, _ := strconv.Atoi(match[1])
+	number, _ := strconv.Atoi(match[2])
+
+	// Week 1 is the week holding January 4th
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	firstMonday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+	start := firstMonday.AddDate(0, 0, (number-1)*7)
+	if y, w := start.ISOWeek(); number < 1 || y != year || w != number {
+		return DateRange{}, fmt.Errorf("%d has no ISO week %d", year, number)
+	}
+	return DateRange{Label: week, Start: start, End: start.AddDate(0, 0, 6)}, nil
+}
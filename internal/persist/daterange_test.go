@@ -0,0 +1,184 @@
+package persist
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestISOWeekDateRange(t *testing.T) {
+	tests := []struct {
+		week      string
+		wantStart time.Time
+		wantErr   bool
+	}{
+		// 2024 starts on a Monday
+		{week: "2024-W01", wantStart: date(2024, time.January, 1)},
+		{week: "2024-W05", wantStart: date(2024, time.January, 29)},
+		// Week 1 of 2021 is the first holding January 4th
+		{week: "2021-W01", wantStart: date(2021, time.January, 4)},
+		// and week 1 of 2026 starts in December
+		{week: "2026-W01", wantStart: date(2025, time.December, 29)},
+		// 2020 has 53 weeks, the last ending in 2021
+		{week: "2020-W53", wantStart: date(2020, time.December, 28)},
+		{week: "2021-W53", wantErr: true},
+		{week: "2024-W00", wantErr: true},
+		{week: "2024-W5", wantErr: true},
+		{week: "2024-05", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.week, func(t *testing.T) {
+			got, err := ISOWeekDateRange(tt.week)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ISOWeekDateRange(%q) = %s to %s, want an error", tt.week, got.StartDate(), got.EndDate())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ISOWeekDateRange(%q): %v", tt.week, err)
+			}
+			wantEnd := tt.wantStart.AddDate(0, 0, 6)
+			if !got.Start.Equal(tt.wantStart) || !got.End.Equal(wantEnd) {
+				t.Errorf("ISOWeekDateRange(%q) = %s to %s, want %s to %s",
+					tt.week, got.StartDate(), got.EndDate(), tt.wantStart.Format("2006-01-02"), wantEnd.Format("2006-01-02"))
+			}
+			if got.Start.Weekday() != time.Monday {
+				t.Errorf("ISOWeekDateRange(%q) starts on a %s", tt.week, got.Start.Weekday())
+			}
+		})
+	}
+}
+
+func TestPresetDateRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		preset    string
+		now       time.Time
+		wantLabel string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "previous quarter from Q1 wraps the year",
+			preset:    PresetPreviousQuarter,
+			now:       time.Date(2024, time.February, 15, 13, 30, 0, 0, time.UTC),
+			wantLabel: "Q4 2023",
+			wantStart: date(2023, time.October, 1),
+			wantEnd:   date(2023, time.December, 31),
+		},
+		{
+			name:      "previous quarter on a quarter's first day",
+			preset:    PresetPreviousQuarter,
+			now:       date(2024, time.April, 1),
+			wantLabel: "Q1 2024",
+			wantStart: date(2024, time.January, 1),
+			wantEnd:   date(2024, time.March, 31),
+		},
+		{
+			name:      "previous quarter on a quarter's last day",
+			preset:    PresetPreviousQuarter,
+			now:       time.Date(2024, time.December, 31, 23, 59, 0, 0, time.UTC),
+			wantLabel: "Q3 2024",
+			wantStart: date(2024, time.July, 1),
+			wantEnd:   date(2024, time.September, 30),
+		},
+		{
+			name:      "previous quarter from the end of Q2",
+			preset:    PresetPreviousQuarter,
+			now:       date(2024, time.June, 30),
+			wantLabel: "Q1 2024",
+			wantStart: date(2024, time.January, 1),
+			wantEnd:   date(2024, time.March, 31),
+		},
+		{
+			name:      "last 7 days ends today",
+			preset:    PresetLast7Days,
+			now:       time.Date(2024, time.March, 3, 8, 0, 0, 0, time.UTC),
+			wantLabel: "Last 7 days",
+			wantStart: date(2024, time.February, 26),
+			wantEnd:   date(2024, time.March, 3),
+		},
+		{
+			name:      "last 30 days",
+			preset:    PresetLast30Days,
+			now:       date(2024, time.March, 30),
+			wantLabel: "Last 30 days",
+			wantStart: date(2024, time.March, 1),
+			wantEnd:   date(2024, time.March, 30),
+		},
+		{
+			name:    "unknown preset",
+			preset:  "next-week",
+			now:     date(2024, time.March, 30),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PresetDateRange(tt.preset, tt.now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("PresetDateRange(%q) succeeded, want an error", tt.preset)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PresetDateRange(%q): %v", tt.preset, err)
+			}
+			if got.Label != tt.wantLabel || !got.Start.Equal(tt.wantStart) || !got.End.Equal(tt.wantEnd) {
+				t.Errorf("PresetDateRange(%q) = %q %s to %s, want %q %s to %s", tt.preset,
+					got.Label, got.StartDate(), got.EndDate(),
+					tt.wantLabel, tt.wantStart.Format("2006-01-02"), tt.wantEnd.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestCheckDateRangeLimits(t *testing.T) {
+	now := date(2025, time.June, 15)
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		end      time.Time
+		earliest time.Time
+		wantErr  bool
+	}{
+		{name: "a year", start: date(2024, time.January, 1), end: date(2024, time.December, 31)},
+		{name: "366 days", start: date(2024, time.June, 1), end: date(2025, time.June, 1)},
+		{name: "367 days", start: date(2024, time.June, 1), end: date(2025, time.June, 2), wantErr: true},
+		{name: "before the previous year", start: date(2023, time.December, 31), end: date(2024, time.January, 6), wantErr: true},
+		{
+			name:     "before the configured earliest date",
+			start:    date(2024, time.June, 1),
+			end:      date(2024, time.June, 7),
+			earliest: date(2025, time.January, 1),
+			wantErr:  true,
+		},
+		{
+			name:     "after an earlier configured date",
+			start:    date(2020, time.March, 2),
+			end:      date(2020, time.March, 8),
+			earliest: date(2020, time.January, 1),
+		},
+	}
+
+	defer func(saved time.Time) { EarliestRangeDate = saved }(EarliestRangeDate)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			EarliestRangeDate = tt.earliest
+			err := CheckDateRangeLimits(DateRange{Label: tt.name, Start: tt.start, End: tt.end}, now)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Errorf("CheckDateRangeLimits(%s to %s) error = %v, want error %v",
+					tt.start.Format("2006-01-02"), tt.end.Format("2006-01-02"), err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,84 +1,302 @@
 package persist
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
 )
 
-var key []byte
+// Encrypted files start with a header naming the format version and the key they were sealed with.
+// The header is authenticated along with the gob-encoded data.
+const (
+	encryptedMagic      = "ZKE"
+	encryptedVersionGCM = 1
+	keyIDSize           = 8
+	encryptedHeaderSize = len(encryptedMagic) + 1 + keyIDSize
+)
 
-// EncryptData encrypts the given data and writes it to the specified file
-func EncryptData(outputFile string, data interface{}) error {
-	outFile, err := os.OpenFile(outputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
+// encryptionKey is an AES key and the ID recorded in files sealed with it.
+type encryptionKey struct {
+	id  [keyIDSize]byte
+	key []byte
+}
+
+var (
+	currentKey encryptionKey
+	// keys holds every key files may be decrypted with, the current one included
+	keys map[[keyIDSize]byte]encryptionKey
+)
+
+// Initialize sets the key new data is encrypted with, and any previous keys still accepted for decryption.
+func Initialize(current []byte, previous ...[]byte) error {
+	ring := make(map[[keyIDSize]byte]encryptionKey, len(previous)+1)
+	for i, raw := range append([][]byte{current}, previous...) {
+		k, err := newEncryptionKey(raw)
+		if err != nil {
+			if i == 0 {
+				return fmt.Errorf("invalid current key: %w", err)
+			}
+			return fmt.Errorf("invalid previous key %d: %w", i, err)
+		}
+		ring[k.id] = k
+		if i == 0 {
+			currentKey = k
+		}
 	}
-	defer outFile.Close()
+	keys = ring
+	return nil
+}
 
-	block, err := aes.NewCipher(key)
+func newEncryptionKey(raw []byte) (encryptionKey, error) {
+	if _, err := aes.NewCipher(raw); err != nil {
+		return encryptionKey{}, err
+	}
+	k := encryptionKey{key: raw}
+	sum := sha256.Sum256(raw)
+	copy(k.id[:], sum[:keyIDSize])
+	return k, nil
+}
+
+// KeyID returns the ID of the current key as recorded in encrypted files.
+func KeyID() string {
+	return hex.EncodeToString(currentKey.id[:])
+}
+
+// EncryptData encrypts the given data with the current key using AES-GCM and writes it to the specified file.
+func EncryptData(outputFile string, data interface{}) error {
+	sealed, err := sealData(data)
 	if err != nil {
 		return err
 	}
+	return WriteFileAtomic(outputFile, sealed, 0600)
+}
 
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return err
+// sealData encodes data and seals it with the current key behind the versioned header.
+func sealData(data interface{}) ([]byte, error) {
+	var plain bytes.Buffer
+	if err := gob.NewEncoder(&plain).Encode(data); err != nil {
+		return nil, err
 	}
 
-	if _, err := outFile.Write(iv); err != nil {
-		return err
+	aead, err := newGCM(currentKey.key)
+	if err != nil {
+		return nil, err
 	}
 
-	stream := cipher.NewCFBEncrypter(block, iv)
-	writer := &cipher.StreamWriter{S: stream, W: outFile}
+	header := make([]byte, 0, encryptedHeaderSize)
+	header = append(header, encryptedMagic...)
+	header = append(header, encryptedVersionGCM)
+	header = append(header, currentKey.id[:]...)
 
-	encoder := gob.NewEncoder(writer)
-	if err := encoder.Encode(data); err != nil {
-		return err
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
 	}
 
-	return nil
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plain.Bytes(), header), nil
 }
 
-// DecryptData reads the encrypted data from the specified file, decrypts it, and populates the given data struct
+// DecryptData reads the encrypted data from the specified file, decrypts it, and populates the given data struct.
+// Files in the legacy AES-CFB format, or sealed with a previous key, are re-encrypted with the current key.
 func DecryptData(inputFile string, data interface{}) error {
-	inFile, err := os.Open(inputFile)
+	current, err := decryptFile(inputFile, data)
 	if err != nil {
 		return err
 	}
-	defer inFile.Close()
+	if !current {
+		if err := EncryptData(inputFile, data); err != nil {
+			return fmt.Errorf("failed to migrate %s to the current key: %w", inputFile, err)
+		}
+	}
+	return nil
+}
 
-	block, err := aes.NewCipher(key)
+// decryptFile decrypts a file into data, reporting whether it is already sealed with the current key.
+func decryptFile(inputFile string, data interface{}) (bool, error) {
+	raw, err := os.ReadFile(inputFile)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(inFile, iv); err != nil {
-		return err
+	if !bytes.HasPrefix(raw, []byte(encryptedMagic)) {
+		return false, decryptLegacy(raw, data)
+	}
+	if len(raw) < encryptedHeaderSize {
+		return false, errors.New("encrypted file is truncated")
 	}
 
-	stream := cipher.NewCFBDecrypter(block, iv)
-	reader := &cipher.StreamReader{S: stream, R: inFile}
+	header := raw[:encryptedHeaderSize]
+	if version := header[len(encryptedMagic)]; version != encryptedVersionGCM {
+		return false, fmt.Errorf("unsupported encrypted file version %d", version)
+	}
+	var id [keyIDSize]byte
+	copy(id[:], header[len(encryptedMagic)+1:])
+	k, ok := keys[id]
+	if !ok {
+		return false, fmt.Errorf("encrypted with unknown key %s", hex.EncodeToString(id[:]))
+	}
 
-	decoder := gob.NewDecoder(reader)
-	if err := decoder.Decode(data); err != nil {
-		return err
+	aead, err := newGCM(k.key)
+	if err != nil {
+		return false, err
+	}
+	body := raw[encryptedHeaderSize:]
+	if len(body) < aead.NonceSize() {
+		return false, errors.New("encrypted file is truncated")
+	}
+	plain, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], header)
+	if err != nil {
+		return false, fmt.Errorf("failed to authenticate encrypted file: %w", err)
+	}
+	if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(data); err != nil {
+		return false, err
+	}
+	return id == currentKey.id, nil
+}
+
+// decryptLegacy decrypts the unauthenticated AES-CFB format: an IV followed by the encrypted gob.
+// Without a key ID each known key is tried, current first, until one decodes.
+func decryptLegacy(raw []byte, data interface{}) error {
+	if len(raw) < aes.BlockSize {
+		return errors.New("legacy encrypted file is truncated")
 	}
 
-	return nil
+	candidates := []encryptionKey{currentKey}
+	for id, k := range keys {
+		if id != currentKey.id {
+			candidates = append(candidates, k)
+		}
+	}
+
+	var lastErr error
+	for _, k := range candidates {
+		block, err := aes.NewCipher(k.key)
+		if err != nil {
+			return err
+		}
+		stream := cipher.NewCFBDecrypter(block, raw[:aes.BlockSize])
+		reader := &cipher.StreamReader{S: stream, R: bytes.NewReader(raw[aes.BlockSize:])}
+		if lastErr = gob.NewDecoder(reader).Decode(data); lastErr == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to decrypt legacy file: %w", lastErr)
 }
 
-func Initialize(bytes []byte) error {
-	key = bytes
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RotationResult counts the identity files visited by RotateIdentityKeys.
+type RotationResult struct {
+	Rotated int
+	Current int
+	// Backups is how many backups of identity files were re-encrypted in place.
+	Backups int
+	Failed  []string
+}
 
-	//if _, err := os.Stat(trustDirectory); os.IsNotExist(err) {
-	//	return os.Mkdir(trustDirectory, 0755)
-	//}
+// RotateIdentityKeys re-encrypts every identity file under root with the current key, then every backup
+// of one, so no copy is left readable only with a previous key. Backups are rewritten in place rather than
+// backed up again. Files that cannot be decrypted with any known key are left untouched and reported.
+func RotateIdentityKeys(root string) (RotationResult, error) {
+	var result RotationResult
+	var backupDirs []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			// Rewriting an identity file backs up the old version, so backups are rotated last
+			if entry.Name() == backupDirectory {
+				backupDirs = append(backupDirs, path)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(entry.Name(), identityFileSuffix) {
+			return nil
+		}
 
+		var identities model.Identities
+		current, err := decryptFile(path, &identities)
+		if err != nil {
+			result.Failed = append(result.Failed, path)
+			return nil
+		}
+		if current {
+			result.Current++
+			return nil
+		}
+		if err := EncryptData(path, &identities); err != nil {
+			return fmt.Errorf("failed to re-encrypt %s: %w", path, err)
+		}
+		result.Rotated++
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for _, dir := range backupDirs {
+		if err := rotateIdentityBackups(dir, &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// rotateIdentityBackups re-encrypts the identity file backups in dir that are not under the current key.
+func rotateIdentityBackups(dir string, result *RotationResult) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.Contains(entry.Name(), identityFileSuffix+".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		var identities model.Identities
+		current, err := decryptFile(path, &identities)
+		if err != nil {
+			result.Failed = append(result.Failed, path)
+			continue
+		}
+		if current {
+			continue
+		}
+		sealed, err := sealData(&identities)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt %s: %w", path, err)
+		}
+		err = replaceFile(path, 0600, func(w io.Writer) error {
+			_, err := w.Write(sealed)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt %s: %w", path, err)
+		}
+		result.Backups++
+	}
 	return nil
 }
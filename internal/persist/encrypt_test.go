@@ -0,0 +1,234 @@
+package persist
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func testIdentities() *model.Identities {
+	return &model.Identities{
+		MainIdentity: "1001",
+		Tokens: map[string]oauth2.Token{
+			"1001": {AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer"},
+		},
+	}
+}
+
+// writeLegacyFile writes data in the old unauthenticated AES-CFB format.
+func writeLegacyFile(t *testing.T, path string, key []byte, data interface{}) {
+	t.Helper()
+	var plain bytes.Buffer
+	if err := gob.NewEncoder(&plain).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, aes.BlockSize+plain.Len())
+	if _, err := rand.Read(out[:aes.BlockSize]); err != nil {
+		t.Fatal(err)
+	}
+	cipher.NewCFBEncrypter(block, out[:aes.BlockSize]).XORKeyStream(out[aes.BlockSize:], plain.Bytes())
+	if err := os.WriteFile(path, out, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecryptData(t *testing.T) {
+	keyA, keyB := testKey(1), testKey(2)
+
+	tests := []struct {
+		name string
+		// write creates the file under test while keyA is the current key
+		write func(t *testing.T, path string)
+		// current and previous are the keys in use when the file is read
+		current  []byte
+		previous [][]byte
+		wantErr  bool
+	}{
+		{
+			name:    "current key",
+			write:   func(t *testing.T, path string) { mustEncrypt(t, path) },
+			current: keyA,
+		},
+		{
+			name:     "previous key",
+			write:    func(t *testing.T, path string) { mustEncrypt(t, path) },
+			current:  keyB,
+			previous: [][]byte{keyA},
+		},
+		{
+			name:    "unknown key",
+			write:   func(t *testing.T, path string) { mustEncrypt(t, path) },
+			current: keyB,
+			wantErr: true,
+		},
+		{
+			name: "tampered ciphertext",
+			write: func(t *testing.T, path string) {
+				mustEncrypt(t, path)
+				flipByte(t, path, -1)
+			},
+			current: keyA,
+			wantErr: true,
+		},
+		{
+			name: "tampered header",
+			write: func(t *testing.T, path string) {
+				mustEncrypt(t, path)
+				flipByte(t, path, len(encryptedMagic)+1)
+			},
+			current: keyA,
+			wantErr: true,
+		},
+		{
+			name: "truncated",
+			write: func(t *testing.T, path string) {
+				mustEncrypt(t, path)
+				if err := os.Truncate(path, int64(encryptedHeaderSize-1)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			current: keyA,
+			wantErr: true,
+		},
+		{
+			name:    "legacy CFB",
+			write:   func(t *testing.T, path string) { writeLegacyFile(t, path, keyA, testIdentities()) },
+			current: keyA,
+		},
+		{
+			name:     "legacy CFB under previous key",
+			write:    func(t *testing.T, path string) { writeLegacyFile(t, path, keyA, testIdentities()) },
+			current:  keyB,
+			previous: [][]byte{keyA},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "1001"+identityFileSuffix)
+			if err := Initialize(keyA); err != nil {
+				t.Fatal(err)
+			}
+			tt.write(t, path)
+
+			if err := Initialize(tt.current, tt.previous...); err != nil {
+				t.Fatal(err)
+			}
+			var got model.Identities
+			err := DecryptData(path, &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("DecryptData succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecryptData: %v", err)
+			}
+			if want := testIdentities(); got.MainIdentity != want.MainIdentity || got.Tokens["1001"].AccessToken != "access" {
+				t.Errorf("DecryptData = %+v, want %+v", got, *want)
+			}
+
+			// Reading migrates the file to the current key and format
+			var again model.Identities
+			current, err := decryptFile(path, &again)
+			if err != nil {
+				t.Fatalf("decryptFile after migration: %v", err)
+			}
+			if !current {
+				t.Error("file was not re-sealed with the current key")
+			}
+		})
+	}
+}
+
+func TestRotateIdentityKeys(t *testing.T) {
+	keyA, keyB := testKey(1), testKey(2)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "1001"+identityFileSuffix)
+
+	if err := Initialize(keyA); err != nil {
+		t.Fatal(err)
+	}
+	// The second write leaves the first behind as a backup
+	mustEncrypt(t, path)
+	mustEncrypt(t, path)
+	if err := os.WriteFile(filepath.Join(dir, "2002"+identityFileSuffix), []byte("not encrypted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Initialize(keyB, keyA); err != nil {
+		t.Fatal(err)
+	}
+	result, err := RotateIdentityKeys(dir)
+	if err != nil {
+		t.Fatalf("RotateIdentityKeys: %v", err)
+	}
+	// Rotating the live file backs up its old version too
+	if result.Rotated != 1 || result.Backups != 2 || len(result.Failed) != 1 {
+		t.Errorf("RotateIdentityKeys = %+v, want 1 rotated, 2 backups and 1 failure", result)
+	}
+
+	// Every copy must now open without the old key
+	if err := Initialize(keyB); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := ListBackups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range append([]string{path}, backupPaths(backups)...) {
+		var got model.Identities
+		if current, err := decryptFile(p, &got); err != nil || !current {
+			t.Errorf("%s: current = %v, err = %v after rotation", filepath.Base(p), current, err)
+		}
+	}
+}
+
+func mustEncrypt(t *testing.T, path string) {
+	t.Helper()
+	if err := EncryptData(path, testIdentities()); err != nil {
+		t.Fatalf("EncryptData: %v", err)
+	}
+}
+
+// flipByte corrupts the byte at offset, counted from the end when negative.
+func flipByte(t *testing.T, path string, offset int) {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset < 0 {
+		offset += len(raw)
+	}
+	raw[offset] ^= 0xff
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func backupPaths(backups []Backup) []string {
+	paths := make([]string, len(backups))
+	for i, backup := range backups {
+		paths[i] = backup.Path
+	}
+	return paths
+}
@@ -22,7 +22,7 @@ func GenerateEsiDataFileName() string {
 // ReadEsiDataFromFile reads ESI data from a JSON file into the provided structure.
 func ReadEsiDataFromFile(fileName string) (*model.ESIData, error) {
 	var esiData model.ESIData
-	if err := ReadJSONFromFile(fileName, &esiData); err != nil {
+	if err := ReadDocument(fileName, SchemaEsiData, &esiData); err != nil {
 		return nil, err
 	}
 	return &esiData, nil
@@ -34,13 +34,27 @@ func SaveEsiDataToFile(fileName string, esiData *model.ESIData) error {
 	if err := os.MkdirAll(GenerateRelativeDirectoryPath(killMailDirectory), os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create directory: %v", err)
 	}
-	return WriteJSONToFile(fileName, esiData)
+	return WriteDocument(fileName, SchemaEsiData, esiData)
+}
+
+// FileTrackedIDRepository stores tracked and failed IDs in JSON files.
+type FileTrackedIDRepository struct {
+	IdsFile    string
+	FailedFile string
+}
+
+// NewFileTrackedIDRepository creates a FileTrackedIDRepository using the default files under data/tps.
+func NewFileTrackedIDRepository() *FileTrackedIDRepository {
+	return &FileTrackedIDRepository{
+		IdsFile:    idsFile,
+		FailedFile: failedCharactersFile,
+	}
 }
 
 // LoadFailedCharacters loads the failed character IDs from file.
-func LoadFailedCharacters() (*model.FailedCharacters, error) {
+func (f *FileTrackedIDRepository) LoadFailedCharacters() (*model.FailedCharacters, error) {
 	var failedChars model.FailedCharacters
-	if err := ReadJSONFromFile(failedCharactersFile, &failedChars); err != nil {
+	if err := ReadDocument(f.FailedFile, SchemaFailedCharacters, &failedChars); err != nil {
 		if os.IsNotExist(err) {
 			// If file does not exist, return an empty structure
 			return &model.FailedCharacters{CharacterIDs: make(map[int]bool)}, nil
@@ -51,40 +65,40 @@ func LoadFailedCharacters() (*model.FailedCharacters, error) {
 }
 
 // SaveFailedCharacters saves the failed character IDs to file.
-func SaveFailedCharacters(failedChars *model.FailedCharacters) error {
+func (f *FileTrackedIDRepository) SaveFailedCharacters(failedChars *model.FailedCharacters) error {
 	// Ensure the directory exists
-	if err := os.MkdirAll(filepath.Dir(failedCharactersFile), os.ModePerm); err != nil {
+	if err := os.MkdirAll(filepath.Dir(f.FailedFile), os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create directory for failed characters file: %v", err)
 	}
-	return WriteJSONToFile(failedCharactersFile, failedChars)
+	return WriteDocument(f.FailedFile, SchemaFailedCharacters, failedChars)
 }
 
-// LoadIdsFromFile loads IDs from the specified file.
-func LoadIdsFromFile() (*model.Ids, error) {
+// LoadIds loads IDs from file.
+func (f *FileTrackedIDRepository) LoadIds() (*model.Ids, error) {
 	var ids *model.Ids
-	if err := ReadJSONFromFile(idsFile, &ids); err != nil {
+	if err := ReadDocument(f.IdsFile, SchemaIds, &ids); err != nil {
 		return ids, err
 	}
 	// fmt.Printf("ids loaded %v", ids)
 	return ids, nil
 }
 
-// SaveIdsToFile saves the given IDs to the specified file.
-func SaveIdsToFile(ids *model.Ids) error {
+// SaveIds saves the given IDs to file.
+func (f *FileTrackedIDRepository) SaveIds(ids *model.Ids) error {
 	// fmt.Printf("writing id files %v", ids)
-	return WriteJSONToFile(idsFile, ids)
+	return WriteDocument(f.IdsFile, SchemaIds, ids)
 }
 
 // CheckIfIdsChanged compares new and old IDs to identify any differences.
-func CheckIfIdsChanged(ids *model.Ids) (bool, *model.Ids, error) {
-	// Load the existing IDs from file
-	oldIds, err := LoadIdsFromFile()
+func CheckIfIdsChanged(ids *model.Ids, trackedIDs TrackedIDRepository, trusted TrustedRepository) (bool, *model.Ids, error) {
+	// Load the existing IDs
+	oldIds, err := trackedIDs.LoadIds()
 	if err != nil {
 		return true, nil, err
 	}
 
 	// Load trusted characters and corporations
-	trustedCharacters, err := LoadTrustedCharacters()
+	trustedCharacters, err := trusted.LoadTrusted()
 	if err != nil {
 		return true, nil, errors.New(fmt.Sprintf("failed to load trusted characters: %v", err))
 	}
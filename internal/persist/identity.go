@@ -1,7 +1,6 @@
 package persist
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,8 +13,8 @@ import (
 )
 
 // GetMainIdentityToken retrieves the token for the main identity.
-func GetMainIdentityToken(mainIdentity int64, host string) (oauth2.Token, error) {
-	identities, err := LoadIdentities(mainIdentity, host)
+func GetMainIdentityToken(repo IdentityRepository, mainIdentity int64, host string) (oauth2.Token, error) {
+	identities, err := repo.LoadIdentities(mainIdentity, host)
 	if err != nil {
 		return oauth2.Token{}, fmt.Errorf("unable to retrieve token for main identity")
 	}
@@ -27,8 +26,8 @@ func GetMainIdentityToken(mainIdentity int64, host string) (oauth2.Token, error)
 }
 
 // LoadIdentityToken retrieves the token for a specified character.
-func LoadIdentityToken(mainIdentity, characterID int64, host string) (oauth2.Token, error) {
-	identities, err := LoadIdentities(mainIdentity, host)
+func LoadIdentityToken(repo IdentityRepository, mainIdentity, characterID int64, host string) (oauth2.Token, error) {
+	identities, err := repo.LoadIdentities(mainIdentity, host)
 	if err != nil {
 		return oauth2.Token{}, fmt.Errorf("unable to retrieve token for character %d", characterID)
 	}
@@ -40,7 +39,18 @@ func LoadIdentityToken(mainIdentity, characterID int64, host string) (oauth2.Tok
 	return token, nil
 }
 
-func LoadIdentities(mainIdentity int64, host string) (*model.Identities, error) {
+const identityFileSuffix = "_identity.json"
+
+// FileIdentityRepository stores each user's identities in an encrypted file under the host's data directory.
+type FileIdentityRepository struct{}
+
+// NewFileIdentityRepository creates a FileIdentityRepository.
+func NewFileIdentityRepository() *FileIdentityRepository {
+	return &FileIdentityRepository{}
+}
+
+// LoadIdentities loads and decrypts a user's identities, starting new users with no tokens.
+func (f *FileIdentityRepository) LoadIdentities(mainIdentity int64, host string) (*model.Identities, error) {
 	if mainIdentity == 0 {
 		return nil, fmt.Errorf("logged in user not provided")
 	}
@@ -59,14 +69,15 @@ func LoadIdentities(mainIdentity int64, host string) (*model.Identities, error)
 
 	// Attempt to load with the new model
 	var identities model.Identities
-	if err := DecryptData(identityFile, &identities); err == nil {
-		xlog.Logf("Loaded identities: %s", SafeLogIdentities(&identities))
-		return &identities, nil
+	if err := DecryptData(identityFile, &identities); err != nil {
+		return nil, fmt.Errorf("unable to decrypt identities: %w", err)
 	}
-	return nil, errors.New("unable to decrypt identities")
+	xlog.Logf("Loaded identities: %s", SafeLogIdentities(&identities))
+	return &identities, nil
 }
 
-func SaveIdentities(mainIdentity int64, ids *model.Identities, host string) error {
+// SaveIdentities encrypts and saves a user's identities.
+func (f *FileIdentityRepository) SaveIdentities(mainIdentity int64, ids *model.Identities, host string) error {
 	if mainIdentity == 0 {
 		return fmt.Errorf("no main identity provided")
 	}
@@ -80,8 +91,18 @@ func SaveIdentities(mainIdentity int64, ids *model.Identities, host string) erro
 	return EncryptData(identityFile, ids)
 }
 
+// DeleteIdentities deletes the identity file for a specified main identity, and its backups with it.
+func (f *FileIdentityRepository) DeleteIdentities(mainIdentity int64, host string) error {
+	return RemoveFileAndBackups(getIdentityFileName(mainIdentity, host))
+}
+
 // getIdentityFileName generates the file path for a given main identity, based on the host.
 func getIdentityFileName(mainIdentity int64, host string) string {
+	return filepath.Join(identityDirectory(host), fmt.Sprintf("%d%s", mainIdentity, identityFileSuffix))
+}
+
+// identityDirectory returns the data directory holding a host's identities.
+func identityDirectory(host string) string {
 	var subAppDirectory string
 
 	switch host {
@@ -96,14 +117,13 @@ func getIdentityFileName(mainIdentity int64, host string) string {
 	default:
 		subAppDirectory = "data/default" // You could add a default case or handle this as an error
 	}
-
-	return filepath.Join(subAppDirectory, fmt.Sprintf("%d_identity.json", mainIdentity))
+	return subAppDirectory
 }
 
 // UpdateIdentities loads, updates, and saves identities.
-func UpdateIdentities(mainIdentity int64, host string, updateFunc func(*model.Identities) error) error {
+func UpdateIdentities(repo IdentityRepository, mainIdentity int64, host string, updateFunc func(*model.Identities) error) error {
 	xlog.Logf("Loading identities for mainIdentity: %d, host: %s", mainIdentity, host)
-	ids, err := LoadIdentities(mainIdentity, host)
+	ids, err := repo.LoadIdentities(mainIdentity, host)
 	if err != nil {
 		xlog.Logf("Error in LoadIdentities: %v", err)
 		return err
@@ -120,7 +140,7 @@ func UpdateIdentities(mainIdentity int64, host string, updateFunc func(*model.Id
 	// Log identities after update without tokens
 	xlog.Logf("Identities after updateFunc: %s", SafeLogIdentities(ids))
 
-	if err = SaveIdentities(mainIdentity, ids, host); err != nil {
+	if err = repo.SaveIdentities(mainIdentity, ids, host); err != nil {
 		xlog.Logf("Error in SaveIdentities: %v", err)
 		return err
 	}
@@ -137,8 +157,3 @@ func SafeLogIdentities(ids *model.Identities) string {
 	}
 	return fmt.Sprintf("MainIdentity: %s, Tokens: [%s]", ids.MainIdentity, strings.Join(tokensInfo, "; "))
 }
-
-// DeleteIdentity deletes the identity file for a specified main identity.
-func DeleteIdentity(mainIdentity int64, host string) error {
-	return os.Remove(getIdentityFileName(mainIdentity, host))
-}
@@ -0,0 +1,334 @@
+package persist
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+const (
+	killMailFileExt       = ".ndjson.gz"
+	legacyKillMailFileExt = ".json"
+
+	// Month files smaller than these hold no killmails
+	minKillMailFileSize       = 64
+	minLegacyKillMailFileSize = 1 * 1024
+)
+
+// ErrKillMailIDMissing is returned for killmails read from a month file written before killmail IDs were stored.
+// It wraps os.ErrNotExist, so callers treat such a month as missing and fetch it in full.
+var ErrKillMailIDMissing = fmt.Errorf("killmail has no ID: %w", os.ErrNotExist)
+
+// KillMailReader streams killmails one at a time from a month file, without holding the month in memory.
+// It reads gzip-compressed newline-delimited JSON, plain newline-delimited JSON, and the legacy
+// single-document files holding {"KillMails": [...]} or a bare array. Newline-delimited files
+// start with an envelope record naming their schema version; records from older versions are migrated.
+type KillMailReader struct {
+	decoder *json.Decoder
+	closers []io.Closer
+
+	legacy  bool
+	started bool
+	done    bool
+
+	version int
+	pending json.RawMessage
+}
+
+// OpenKillMailFile opens a month file for streaming. If a compressed month file does not exist,
+// the legacy .json file for the same month is opened instead.
+func OpenKillMailFile(fileName string) (*KillMailReader, error) {
+	file, err := os.Open(fileName)
+	if os.IsNotExist(err) && strings.HasSuffix(fileName, killMailFileExt) {
+		fileName = legacyKillMailFileName(fileName)
+		file, err = os.Open(fileName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := NewKillMailReader(file, fileName)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader.closers = append(reader.closers, file)
+	return reader, nil
+}
+
+// NewKillMailReader streams killmails from r, choosing the format from name's extension.
+func NewKillMailReader(r io.Reader, name string) (*KillMailReader, error) {
+	reader := &KillMailReader{}
+
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			if err == io.EOF {
+				// An empty file holds no killmails
+				reader.done = true
+				return reader, nil
+			}
+			return nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		reader.closers = append(reader.closers, gz)
+		r = gz
+		lower = strings.TrimSuffix(lower, ".gz")
+	}
+
+	reader.legacy = !strings.HasSuffix(lower, ".ndjson")
+	reader.decoder = json.NewDecoder(bufio.NewReader(r))
+	return reader, nil
+}
+
+// Version returns the schema version the file's records were written at. Legacy files are version 0.
+func (kr *KillMailReader) Version() (int, error) {
+	if err := kr.start(); err != nil && err != io.EOF {
+		return 0, err
+	}
+	return kr.version, nil
+}
+
+// start reads up to the first record: the killmail array of a legacy file, or the envelope of a
+// newline-delimited one. A file without an envelope is version 0 and its first record is kept for Next.
+func (kr *KillMailReader) start() error {
+	if kr.started || kr.done {
+		return nil
+	}
+	kr.started = true
+
+	if kr.legacy {
+		if err := kr.openLegacyArray(); err != nil {
+			kr.done = true
+			return err
+		}
+		return nil
+	}
+
+	var first json.RawMessage
+	if err := kr.decoder.Decode(&first); err != nil {
+		kr.done = true
+		return err
+	}
+	var envelope Envelope
+	if err := json.Unmarshal(first, &envelope); err == nil && envelope.Schema == SchemaKillMails {
+		kr.version = envelope.Version
+		return nil
+	}
+	kr.pending = first
+	return nil
+}
+
+// Next returns the next killmail, or io.EOF once the file is exhausted.
+func (kr *KillMailReader) Next() (model.DetailedKillMail, error) {
+	var km model.DetailedKillMail
+	if err := kr.start(); err != nil {
+		if err == io.EOF {
+			return km, io.EOF
+		}
+		return km, err
+	}
+	if kr.done {
+		return km, io.EOF
+	}
+	if kr.legacy && !kr.decoder.More() {
+		kr.done = true
+		return km, io.EOF
+	}
+
+	record := kr.pending
+	kr.pending = nil
+	if record == nil {
+		if err := kr.decoder.Decode(&record); err != nil {
+			kr.done = true
+			if err == io.EOF {
+				return km, io.EOF
+			}
+			return km, fmt.Errorf("failed to decode killmail: %w", err)
+		}
+	}
+
+	if kr.version < SchemaVersion(SchemaKillMails) {
+		migrated, err := migrateData(SchemaKillMails, kr.version, record)
+		if err != nil {
+			kr.done = true
+			return km, err
+		}
+		record = migrated
+	}
+	if err := json.Unmarshal(record, &km); err != nil {
+		kr.done = true
+		return km, fmt.Errorf("failed to decode killmail: %w", err)
+	}
+	return km, nil
+}
+
+// openLegacyArray positions the decoder inside the killmail array of a legacy file.
+func (kr *KillMailReader) openLegacyArray() error {
+	token, err := kr.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == json.Delim('[') {
+		return nil
+	}
+	if token != json.Delim('{') {
+		return fmt.Errorf("unexpected %v at start of killmail file", token)
+	}
+
+	for kr.decoder.More() {
+		key, err := kr.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key == "KillMails" {
+			token, err := kr.decoder.Token()
+			if err != nil {
+				return err
+			}
+			if token == nil {
+				// "KillMails": null
+				return io.EOF
+			}
+			if token != json.Delim('[') {
+				return fmt.Errorf("unexpected %v for KillMails", token)
+			}
+			return nil
+		}
+		var skip json.RawMessage
+		if err := kr.decoder.Decode(&skip); err != nil {
+			return err
+		}
+	}
+	return io.EOF
+}
+
+// Close releases the file.
+func (kr *KillMailReader) Close() error {
+	var firstErr error
+	for _, closer := range kr.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// EachKillMail calls fn for every killmail in a month file, stopping at the first error.
+func EachKillMail(fileName string, fn func(model.DetailedKillMail) error) error {
+	reader, err := OpenKillMailFile(fileName)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		km, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", fileName, err)
+		}
+		if err := fn(km); err != nil {
+			return err
+		}
+	}
+}
+
+// writeKillMailFile writes killmails as compressed newline-delimited JSON, replacing fileName in one step.
+// Writing a month's compressed file removes its legacy .json file.
+func writeKillMailFile(fileName string, killMails []model.DetailedKillMail) error {
+	err := replaceFile(fileName, 0644, func(w io.Writer) error {
+		return encodeKillMails(w, killMails, true)
+	})
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(fileName, killMailFileExt) {
+		if err := os.Remove(legacyKillMailFileName(fileName)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove legacy file for %s: %w", fileName, err)
+		}
+	}
+	return nil
+}
+
+// appendKillMailFile adds killmails to a compressed month file as a further gzip member,
+// which readers see as one continuous stream. The existing members are copied without
+// decompressing them, and the result replaces the file in one step.
+func appendKillMailFile(fileName string, killMails []model.DetailedKillMail) error {
+	existing, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer existing.Close()
+
+	return replaceFile(fileName, 0644, func(w io.Writer) error {
+		if _, err := io.Copy(w, existing); err != nil {
+			return err
+		}
+		return encodeKillMails(w, killMails, false)
+	})
+}
+
+// encodeKillMails writes one gzip member holding a killmail per line, led by the schema envelope
+// when it starts a file.
+func encodeKillMails(w io.Writer, killMails []model.DetailedKillMail, header bool) error {
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	if header {
+		if err := encoder.Encode(Envelope{Schema: SchemaKillMails, Version: SchemaVersion(SchemaKillMails)}); err != nil {
+			gz.Close()
+			return err
+		}
+	}
+	for _, km := range killMails {
+		if err := encoder.Encode(km); err != nil {
+			gz.Close()
+			return err
+		}
+	}
+	return gz.Close()
+}
+
+// checkKillMailIDs returns ErrKillMailIDMissing if a month file predates stored killmail IDs.
+// Only the first record of a file older than the current version is read.
+func checkKillMailIDs(fileName string) error {
+	reader, err := OpenKillMailFile(fileName)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	version, err := reader.Version()
+	if err != nil || version >= SchemaVersion(SchemaKillMails) {
+		return err
+	}
+	if _, err := reader.Next(); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+	return nil
+}
+
+// killMailFileVersion returns the schema version of a month file's records.
+func killMailFileVersion(fileName string) (int, error) {
+	reader, err := OpenKillMailFile(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	return reader.Version()
+}
+
+// legacyKillMailFileName returns the pretty JSON file a month was stored in before compression.
+func legacyKillMailFileName(fileName string) string {
+	return strings.TrimSuffix(fileName, killMailFileExt) + legacyKillMailFileExt
+}
@@ -0,0 +1,140 @@
+package persist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// groupsDirectory holds a directory of persisted data for each tracked group other than the default.
+const groupsDirectory = "data/tps/groups"
+
+// KillMailStore is a tracked group's persisted killmail data: its month files and their fetch cursors, the IDs
+// fetched into them and its killmail database. The ESI data and failed characters are shared by every group.
+type KillMailStore struct {
+	Group string
+	Root  string // relative to the working directory
+}
+
+// DefaultKillMailStore is the default group's store, which keeps the layout from before groups existed.
+var DefaultKillMailStore = KillMailStore{Group: config.DefaultGroup, Root: "data/tps"}
+
+// GroupKillMailStore returns the store for a tracked group.
+func GroupKillMailStore(group string) KillMailStore {
+	if group == config.DefaultGroup {
+		return DefaultKillMailStore
+	}
+	return KillMailStore{Group: group, Root: filepath.Join(groupsDirectory, group)}
+}
+
+// Dir returns the directory holding the store's month files.
+func (s KillMailStore) Dir() string {
+	return GenerateRelativeDirectoryPath(filepath.Join(s.Root, "store"))
+}
+
+// MonthFileName returns the month file for a year and month.
+func (s KillMailStore) MonthFileName(year, month int) string {
+	return fmt.Sprintf("%s/%04d-%02d-killmails%s", s.Dir(), year, month, killMailFileExt)
+}
+
+// CursorFileName returns the file holding the fetch cursors for a month.
+func (s KillMailStore) CursorFileName(year, month int) string {
+	return fmt.Sprintf("%s/%04d-%02d-cursors.json", s.Dir(), year, month)
+}
+
+// KillMailDBFileName returns the path of the store's killmail database.
+func (s KillMailStore) KillMailDBFileName() string {
+	return GenerateRelativeDirectoryPath(filepath.Join(s.Root, filepath.Base(killMailDBFile)))
+}
+
+// TrackedIDRepository returns a repository for the IDs fetched into the store, sharing the failed characters file.
+func (s KillMailStore) TrackedIDRepository() *FileTrackedIDRepository {
+	return &FileTrackedIDRepository{
+		IdsFile:    filepath.Join(s.Root, filepath.Base(idsFile)),
+		FailedFile: failedCharactersFile,
+	}
+}
+
+// StatMonthFile returns the month's store file in either format, and whether it is large enough to hold killmails.
+// A file written before killmail IDs were stored is reported as ErrKillMailIDMissing.
+func (s KillMailStore) StatMonthFile(year, month int) (os.FileInfo, bool, error) {
+	fileName := s.MonthFileName(year, month)
+	minSize := int64(minKillMailFileSize)
+	info, err := os.Stat(fileName)
+	if os.IsNotExist(err) {
+		minSize = minLegacyKillMailFileSize
+		info, err = os.Stat(legacyKillMailFileName(fileName))
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Size() <= minSize {
+		return info, false, nil
+	}
+	if err := checkKillMailIDs(fileName); err != nil {
+		return info, false, err
+	}
+	return info, true, nil
+}
+
+// MonthFileNames returns the store's month files in either format, sorted by name.
+func (s KillMailStore) MonthFileNames() ([]string, error) {
+	var fileNames []string
+	for _, ext := range []string{killMailFileExt, legacyKillMailFileExt} {
+		matches, err := filepath.Glob(filepath.Join(s.Dir(), "*-killmails"+ext))
+		if err != nil {
+			return nil, err
+		}
+		fileNames = append(fileNames, matches...)
+	}
+	sort.Strings(fileNames)
+	return fileNames, nil
+}
+
+// StoredMonths returns the first day of every month with a store file in either format, oldest first.
+func (s KillMailStore) StoredMonths() ([]time.Time, error) {
+	fileNames, err := s.MonthFileNames()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[time.Time]bool)
+	var months []time.Time
+	for _, fileName := range fileNames {
+		base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(fileName), killMailFileExt), legacyKillMailFileExt)
+		month, err := time.Parse("2006-01", strings.TrimSuffix(base, "-killmails"))
+		if err != nil || seen[month] {
+			continue
+		}
+		seen[month] = true
+		months = append(months, month)
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	return months, nil
+}
+
+// LoadMonthCursors loads the fetch cursors for a month, returning nil if none were recorded.
+func (s KillMailStore) LoadMonthCursors(year, month int) (*model.MonthCursors, error) {
+	var cursors model.MonthCursors
+	if err := ReadDocument(s.CursorFileName(year, month), SchemaMonthCursors, &cursors); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if cursors.Cursors == nil {
+		cursors.Cursors = make(map[string]model.FetchCursor)
+	}
+	return &cursors, nil
+}
+
+// SaveMonthCursors saves the fetch cursors for a month.
+func (s KillMailStore) SaveMonthCursors(year, month int, cursors *model.MonthCursors) error {
+	return WriteDocument(s.CursorFileName(year, month), SchemaMonthCursors, cursors)
+}
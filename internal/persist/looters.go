@@ -1,9 +1,7 @@
 package persist
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"sync"
@@ -24,17 +22,11 @@ func LoadAddedPilots() (map[string]bool, error) {
 	trustMu.Lock()
 	defer trustMu.Unlock()
 
-	file, err := os.Open(addedPilotsFile)
-	if os.IsNotExist(err) {
-		return make(map[string]bool), nil // No pilots added yet
-	}
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var pilots map[string]bool
-	if err := json.NewDecoder(file).Decode(&pilots); err != nil {
+	pilots := make(map[string]bool)
+	if err := ReadDocument(addedPilotsFile, SchemaPilots, &pilots); err != nil {
+		if os.IsNotExist(err) {
+			return pilots, nil // No pilots added yet
+		}
 		return nil, err
 	}
 	return pilots, nil
@@ -47,7 +39,7 @@ func AddPilotName(name string) error {
 
 	// Load existing pilots or create an empty map if the file does not exist.
 	pilots := make(map[string]bool)
-	if err := ReadJSONFromFile(addedPilotsFile, &pilots); err != nil {
+	if err := ReadDocument(addedPilotsFile, SchemaPilots, &pilots); err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("File %s does not exist, initializing with an empty map.", addedPilotsFile)
 		} else {
@@ -59,7 +51,7 @@ func AddPilotName(name string) error {
 	pilots[name] = true // Mark the pilot as added
 
 	// Save updated pilots
-	if err := WriteJSONToFile(addedPilotsFile, pilots); err != nil {
+	if err := WriteDocument(addedPilotsFile, SchemaPilots, pilots); err != nil {
 		log.Printf("Error saving added pilots to file %s: %v", addedPilotsFile, err)
 		return err
 	}
@@ -73,17 +65,11 @@ func LoadRemovedPilots() (map[string]bool, error) {
 	trustMu.Lock()
 	defer trustMu.Unlock()
 
-	file, err := os.Open(removedPilotsFile)
-	if os.IsNotExist(err) {
-		return make(map[string]bool), nil // No pilots removed yet
-	}
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var pilots map[string]bool
-	if err := json.NewDecoder(file).Decode(&pilots); err != nil {
+	pilots := make(map[string]bool)
+	if err := ReadDocument(removedPilotsFile, SchemaPilots, &pilots); err != nil {
+		if os.IsNotExist(err) {
+			return pilots, nil // No pilots removed yet
+		}
 		return nil, err
 	}
 	return pilots, nil
@@ -96,7 +82,7 @@ func RemovePilot(name string) error {
 
 	// Load existing removed pilots or create an empty map if the file does not exist.
 	pilots := make(map[string]bool)
-	if err := ReadJSONFromFile(removedPilotsFile, &pilots); err != nil {
+	if err := ReadDocument(removedPilotsFile, SchemaPilots, &pilots); err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("File %s does not exist, initializing with an empty map.", removedPilotsFile)
 		} else {
@@ -108,7 +94,7 @@ func RemovePilot(name string) error {
 	pilots[name] = true
 
 	// Save updated removed pilots
-	if err := WriteJSONToFile(removedPilotsFile, pilots); err != nil {
+	if err := WriteDocument(removedPilotsFile, SchemaPilots, pilots); err != nil {
 		log.Printf("Error saving removed pilots to file %s: %v", removedPilotsFile, err)
 		return err
 	}
@@ -117,60 +103,43 @@ func RemovePilot(name string) error {
 	return nil
 }
 
-// LoadLootSplits reads the loot splits from the specified JSON file.
+// FileLootSplitRepository stores loot splits in a JSON file.
+type FileLootSplitRepository struct {
+	FileName string
+}
+
+// NewFileLootSplitRepository creates a FileLootSplitRepository for fileName.
+func NewFileLootSplitRepository(fileName string) *FileLootSplitRepository {
+	return &FileLootSplitRepository{FileName: fileName}
+}
+
+// LoadLootSplits reads the loot splits from the JSON file, upgrading older versions.
 // It returns an empty slice if the file does not exist or is empty.
-func LoadLootSplits(filename string) ([]model.LootSplit, error) {
+func (f *FileLootSplitRepository) LoadLootSplits() ([]model.LootSplit, error) {
 	var lootSplits []model.LootSplit
+	filename := f.FileName
 
-	file, err := os.Open(filename)
-	if err != nil {
+	if err := ReadDocument(filename, SchemaLootSplits, &lootSplits); err != nil {
 		if os.IsNotExist(err) {
 			// File does not exist; return empty slice
 			return lootSplits, nil
 		}
-		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
-	}
-	defer file.Close()
-
-	// Check if file is empty
-	fi, err := file.Stat()
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat file %s: %w", filename, err)
-	}
-
-	if fi.Size() == 0 {
-		// Empty file; return empty slice
-		return lootSplits, nil
-	}
-
-	// Reset file pointer to the beginning
-	if _, err := file.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek file %s: %w", filename, err)
-	}
-
-	// Attempt to decode JSON
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&lootSplits); err != nil {
-		if err == io.EOF {
-			// Empty JSON; return empty slice
-			return lootSplits, nil
-		}
-		return nil, fmt.Errorf("failed to decode JSON from file %s: %w", filename, err)
+		return nil, fmt.Errorf("failed to read loot splits from %s: %w", filename, err)
 	}
 
 	return lootSplits, nil
 }
 
-// SaveLootSplits writes the provided loot splits to the specified JSON file.
-func SaveLootSplits(filename string, lootSplits []model.LootSplit) error {
-	return WriteJSONToFile(filename, lootSplits)
+// SaveLootSplits writes the provided loot splits to the JSON file.
+func (f *FileLootSplitRepository) SaveLootSplits(lootSplits []model.LootSplit) error {
+	return WriteDocument(f.FileName, SchemaLootSplits, lootSplits)
 }
 
 // AddLootSplit adds a new loot split to the existing splits and saves them.
 // It sets the Date field of the new split to the current UTC time.
-func AddLootSplit(filename string, newSplit model.LootSplit) error {
+func AddLootSplit(repo LootSplitRepository, newSplit model.LootSplit) error {
 	// Load existing splits
-	lootSplits, err := LoadLootSplits(filename)
+	lootSplits, err := repo.LoadLootSplits()
 	if err != nil {
 		return fmt.Errorf("failed to load existing loot splits: %w", err)
 	}
@@ -182,7 +151,7 @@ func AddLootSplit(filename string, newSplit model.LootSplit) error {
 	lootSplits = append(lootSplits, newSplit)
 
 	// Save all splits
-	if err := SaveLootSplits(filename, lootSplits); err != nil {
+	if err := repo.SaveLootSplits(lootSplits); err != nil {
 		return fmt.Errorf("failed to save loot splits: %w", err)
 	}
 
@@ -190,9 +159,9 @@ func AddLootSplit(filename string, newSplit model.LootSplit) error {
 }
 
 // DeleteLootSplit deletes a loot split by its ID and saves the updated splits.
-func DeleteLootSplit(filename string, id int) ([]model.LootSplit, error) {
+func DeleteLootSplit(repo LootSplitRepository, id int) ([]model.LootSplit, error) {
 	// Load existing splits
-	lootSplits, err := LoadLootSplits(filename)
+	lootSplits, err := repo.LoadLootSplits()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load existing loot splits: %w", err)
 	}
@@ -206,7 +175,7 @@ func DeleteLootSplit(filename string, id int) ([]model.LootSplit, error) {
 	lootSplits = append(lootSplits[:id], lootSplits[id+1:]...)
 
 	// Save the updated splits
-	if err := SaveLootSplits(filename, lootSplits); err != nil {
+	if err := repo.SaveLootSplits(lootSplits); err != nil {
 		return nil, fmt.Errorf("failed to save updated loot splits: %w", err)
 	}
 
@@ -214,7 +183,7 @@ func DeleteLootSplit(filename string, id int) ([]model.LootSplit, error) {
 }
 
 // CreateLootSplitBackup creates a backup of the loot splits file with a timestamp
-func CreateLootSplitBackup() error {
+func CreateLootSplitBackup(repo LootSplitRepository) error {
 	// Ensure backup directory exists
 	if err := os.MkdirAll(config.LootDir, 0755); err != nil {
 		return fmt.Errorf("failed to create backup directory: %v", err)
@@ -225,11 +194,10 @@ func CreateLootSplitBackup() error {
 	backupFilename := fmt.Sprintf("%s/loot_splits_backup_%s.json", config.LootDir, timestamp)
 
 	// Load the current loot splits to create a backup
-	lootSplits, err := LoadLootSplits(config.LootFile)
+	lootSplits, err := repo.LoadLootSplits()
 	if err != nil {
 		return fmt.Errorf("failed to load current loot splits for backup: %v", err)
 	}
 
-	// Use writeJSONToFile helper to create the backup file
-	return WriteJSONToFile(backupFilename, lootSplits)
+	return WriteDocument(backupFilename, SchemaLootSplits, lootSplits)
 }
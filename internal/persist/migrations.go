@@ -0,0 +1,75 @@
+package persist
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+func init() {
+	// Version 1 wrapped each document in an envelope without changing its data
+	for _, schema := range []string{
+		SchemaTrustedCharacter,
+		SchemaIds,
+		SchemaFailedCharacters,
+		SchemaMonthCursors,
+		SchemaEsiData,
+		SchemaPilots,
+	} {
+		RegisterMigration(schema, 0, unchanged)
+	}
+	RegisterMigration(SchemaLootSplits, 0, migrateLootSplitAmounts)
+	RegisterMigration(SchemaKillMails, 0, requireKillMailID)
+}
+
+func unchanged(data json.RawMessage) (json.RawMessage, error) {
+	return data, nil
+}
+
+// requireKillMailID keeps killmails that carry their ID. Month files written before IDs were stored dropped
+// them, and without one a killmail can be neither keyed in the repository nor merged, so the month is refetched.
+func requireKillMailID(data json.RawMessage) (json.RawMessage, error) {
+	var record struct {
+		KillMailID int64 `json:"killmail_id"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	if record.KillMailID == 0 {
+		return nil, ErrKillMailIDMissing
+	}
+	return data, nil
+}
+
+// migrateLootSplitAmounts converts split amounts saved as strings to numbers.
+func migrateLootSplitAmounts(data json.RawMessage) (json.RawMessage, error) {
+	var splits []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &splits); err != nil {
+		return nil, err
+	}
+
+	for _, split := range splits {
+		raw, ok := split["splitDetails"]
+		if !ok {
+			continue
+		}
+		var details map[string]interface{}
+		if err := json.Unmarshal(raw, &details); err != nil {
+			return nil, err
+		}
+		for name, amount := range details {
+			if text, ok := amount.(string); ok {
+				value, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return nil, err
+				}
+				details[name] = value
+			}
+		}
+		converted, err := json.Marshal(details)
+		if err != nil {
+			return nil, err
+		}
+		split["splitDetails"] = converted
+	}
+	return json.Marshal(splits)
+}
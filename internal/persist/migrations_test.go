@@ -0,0 +1,150 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+func TestMigrateLootSplitAmounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "string amounts",
+			input: `[{"id":1,"splitDetails":{"Alice":"1500.5","Bob":"20"}}]`,
+			want:  `[{"id":1,"splitDetails":{"Alice":1500.5,"Bob":20}}]`,
+		},
+		{
+			name:  "numeric amounts kept",
+			input: `[{"id":1,"splitDetails":{"Alice":1500.5}}]`,
+			want:  `[{"id":1,"splitDetails":{"Alice":1500.5}}]`,
+		},
+		{
+			name:  "mixed amounts",
+			input: `[{"id":1,"splitDetails":{"Alice":"3","Bob":4}},{"id":2,"splitDetails":{"Carol":"0.25"}}]`,
+			want:  `[{"id":1,"splitDetails":{"Alice":3,"Bob":4}},{"id":2,"splitDetails":{"Carol":0.25}}]`,
+		},
+		{
+			name:  "no split details",
+			input: `[{"id":1,"totalBuyPrice":"100"}]`,
+			want:  `[{"id":1,"totalBuyPrice":"100"}]`,
+		},
+		{
+			name:  "no splits",
+			input: `[]`,
+			want:  `[]`,
+		},
+		{
+			name:    "amount that is not a number",
+			input:   `[{"id":1,"splitDetails":{"Alice":"lots"}}]`,
+			wantErr: true,
+		},
+		{
+			name:    "not a list",
+			input:   `{"id":1}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := migrateLootSplitAmounts(json.RawMessage(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("migrateLootSplitAmounts(%s) = %s, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("migrateLootSplitAmounts(%s): %v", tt.input, err)
+			}
+			assertSameJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestReadDocumentMigratesLootSplits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loot_splits.json")
+	legacy := `[{"id":7,"date":"2024-05-01","totalBuyPrice":"100","splitDetails":{"Alice":"60","Bob":"40"}}]`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var splits []model.LootSplit
+	if err := ReadDocument(path, SchemaLootSplits, &splits); err != nil {
+		t.Fatalf("ReadDocument: %v", err)
+	}
+	want := map[string]model.Amount{"Alice": 60, "Bob": 40}
+	if len(splits) != 1 || splits[0].ID != 7 || !reflect.DeepEqual(splits[0].SplitDetails, want) {
+		t.Fatalf("ReadDocument = %+v, want one split with details %v", splits, want)
+	}
+
+	// A document written back is current and reads the same
+	if err := WriteDocument(path, SchemaLootSplits, splits); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, version := unwrapDocument(SchemaLootSplits, raw); version != SchemaVersion(SchemaLootSplits) {
+		t.Errorf("written version = %d, want %d", version, SchemaVersion(SchemaLootSplits))
+	}
+	var reread []model.LootSplit
+	if err := ReadDocument(path, SchemaLootSplits, &reread); err != nil {
+		t.Fatalf("ReadDocument after write: %v", err)
+	}
+	if !reflect.DeepEqual(reread, splits) {
+		t.Errorf("reread = %+v, want %+v", reread, splits)
+	}
+}
+
+func TestRequireKillMailID(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{name: "with ID", input: `{"killmail_id":123,"solar_system_id":1}`},
+		{name: "zero ID", input: `{"killmail_id":0}`, wantErr: ErrKillMailIDMissing},
+		{name: "missing ID", input: `{"solar_system_id":1}`, wantErr: ErrKillMailIDMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := requireKillMailID(json.RawMessage(tt.input))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("requireKillMailID(%s) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr == nil && string(got) != tt.input {
+				t.Errorf("requireKillMailID(%s) = %s, want it unchanged", tt.input, got)
+			}
+			if tt.wantErr != nil && !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("requireKillMailID(%s) error = %v, want it to read as a missing month", tt.input, err)
+			}
+		})
+	}
+}
+
+func assertSameJSON(t *testing.T, got json.RawMessage, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,388 @@
+package persist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// TrustedRepository stores the trusted and untrusted characters and corporations.
+type TrustedRepository interface {
+	LoadTrusted() (*model.TrustedCharacters, error)
+	SaveTrusted(trustedData *model.TrustedCharacters) error
+}
+
+// LootSplitRepository stores the saved loot splits.
+type LootSplitRepository interface {
+	LoadLootSplits() ([]model.LootSplit, error)
+	SaveLootSplits(lootSplits []model.LootSplit) error
+}
+
+// IdentityRepository stores the tokens of the characters each user has authenticated, per host.
+type IdentityRepository interface {
+	LoadIdentities(mainIdentity int64, host string) (*model.Identities, error)
+	SaveIdentities(mainIdentity int64, ids *model.Identities, host string) error
+	DeleteIdentities(mainIdentity int64, host string) error
+}
+
+// TrackedIDRepository stores the entity IDs fetched so far and the characters ESI could not resolve.
+type TrackedIDRepository interface {
+	LoadIds() (*model.Ids, error)
+	SaveIds(ids *model.Ids) error
+	LoadFailedCharacters() (*model.FailedCharacters, error)
+	SaveFailedCharacters(failedChars *model.FailedCharacters) error
+}
+
+// KillMailRepository stores hydrated killmails and the ESI entities they reference.
+type KillMailRepository interface {
+	SaveKillMails(ctx context.Context, killMails []model.DetailedKillMail) (int, error)
+	ReplaceMonth(ctx context.Context, year, month int, killMails []model.DetailedKillMail) (int, error)
+	KillMailsBetween(ctx context.Context, start, end time.Time) ([]model.DetailedKillMail, error)
+	CountKillMails(ctx context.Context) (int, error)
+	SaveEsiData(ctx context.Context, esiData *model.ESIData) error
+	LoadEsiData(ctx context.Context) (*model.ESIData, error)
+}
+
+var (
+	_ TrustedRepository   = (*FileTrustedRepository)(nil)
+	_ TrustedRepository   = (*MemoryTrustedRepository)(nil)
+	_ LootSplitRepository = (*FileLootSplitRepository)(nil)
+	_ LootSplitRepository = (*MemoryLootSplitRepository)(nil)
+	_ IdentityRepository  = (*FileIdentityRepository)(nil)
+	_ IdentityRepository  = (*MemoryIdentityRepository)(nil)
+	_ TrackedIDRepository = (*FileTrackedIDRepository)(nil)
+	_ TrackedIDRepository = (*MemoryTrackedIDRepository)(nil)
+	_ KillMailRepository  = (*SQLiteKillMailRepository)(nil)
+	_ KillMailRepository  = (*MemoryKillMailRepository)(nil)
+)
+
+// copyJSON deep copies src into dst through JSON, so callers of the in-memory repositories can modify
+// what they load without changing what is stored, just as with the files.
+func copyJSON(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// MemoryTrustedRepository keeps the trust lists in memory.
+type MemoryTrustedRepository struct {
+	mu   sync.Mutex
+	data *model.TrustedCharacters
+}
+
+// NewMemoryTrustedRepository creates an empty MemoryTrustedRepository.
+func NewMemoryTrustedRepository() *MemoryTrustedRepository {
+	return &MemoryTrustedRepository{data: newTrustedCharacters()}
+}
+
+// LoadTrusted implements TrustedRepository.
+func (m *MemoryTrustedRepository) LoadTrusted() (*model.TrustedCharacters, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var trustedData model.TrustedCharacters
+	err := copyJSON(m.data, &trustedData)
+	return &trustedData, err
+}
+
+// SaveTrusted implements TrustedRepository.
+func (m *MemoryTrustedRepository) SaveTrusted(trustedData *model.TrustedCharacters) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var stored model.TrustedCharacters
+	if err := copyJSON(trustedData, &stored); err != nil {
+		return err
+	}
+	m.data = &stored
+	return nil
+}
+
+// MemoryLootSplitRepository keeps loot splits in memory.
+type MemoryLootSplitRepository struct {
+	mu     sync.Mutex
+	splits []model.LootSplit
+}
+
+// NewMemoryLootSplitRepository creates an empty MemoryLootSplitRepository.
+func NewMemoryLootSplitRepository() *MemoryLootSplitRepository {
+	return &MemoryLootSplitRepository{}
+}
+
+// LoadLootSplits implements LootSplitRepository.
+func (m *MemoryLootSplitRepository) LoadLootSplits() ([]model.LootSplit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var lootSplits []model.LootSplit
+	if len(m.splits) == 0 {
+		return lootSplits, nil
+	}
+	err := copyJSON(m.splits, &lootSplits)
+	return lootSplits, err
+}
+
+// SaveLootSplits implements LootSplitRepository.
+func (m *MemoryLootSplitRepository) SaveLootSplits(lootSplits []model.LootSplit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var stored []model.LootSplit
+	if err := copyJSON(lootSplits, &stored); err != nil {
+		return err
+	}
+	m.splits = stored
+	return nil
+}
+
+// MemoryIdentityRepository keeps identities in memory.
+type MemoryIdentityRepository struct {
+	mu         sync.Mutex
+	identities map[string]*model.Identities
+}
+
+// NewMemoryIdentityRepository creates an empty MemoryIdentityRepository.
+func NewMemoryIdentityRepository() *MemoryIdentityRepository {
+	return &MemoryIdentityRepository{identities: make(map[string]*model.Identities)}
+}
+
+func memoryIdentityKey(mainIdentity int64, host string) string {
+	return fmt.Sprintf("%s/%d", identityDirectory(host), mainIdentity)
+}
+
+// LoadIdentities implements IdentityRepository. Unknown users start with no tokens.
+func (m *MemoryIdentityRepository) LoadIdentities(mainIdentity int64, host string) (*model.Identities, error) {
+	if mainIdentity == 0 {
+		return nil, fmt.Errorf("logged in user not provided")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.identities[memoryIdentityKey(mainIdentity, host)]
+	if !ok {
+		return &model.Identities{
+			MainIdentity: fmt.Sprintf("%d", mainIdentity),
+			Tokens:       make(map[string]oauth2.Token),
+		}, nil
+	}
+	var identities model.Identities
+	err := copyJSON(stored, &identities)
+	return &identities, err
+}
+
+// SaveIdentities implements IdentityRepository.
+func (m *MemoryIdentityRepository) SaveIdentities(mainIdentity int64, ids *model.Identities, host string) error {
+	if mainIdentity == 0 {
+		return fmt.Errorf("no main identity provided")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stored model.Identities
+	if err := copyJSON(ids, &stored); err != nil {
+		return err
+	}
+	m.identities[memoryIdentityKey(mainIdentity, host)] = &stored
+	return nil
+}
+
+// DeleteIdentities implements IdentityRepository.
+func (m *MemoryIdentityRepository) DeleteIdentities(mainIdentity int64, host string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryIdentityKey(mainIdentity, host)
+	if _, ok := m.identities[key]; !ok {
+		return os.ErrNotExist
+	}
+	delete(m.identities, key)
+	return nil
+}
+
+// MemoryTrackedIDRepository keeps tracked and failed IDs in memory.
+type MemoryTrackedIDRepository struct {
+	mu     sync.Mutex
+	ids    *model.Ids
+	failed *model.FailedCharacters
+}
+
+// NewMemoryTrackedIDRepository creates an empty MemoryTrackedIDRepository.
+func NewMemoryTrackedIDRepository() *MemoryTrackedIDRepository {
+	return &MemoryTrackedIDRepository{}
+}
+
+// LoadIds implements TrackedIDRepository. Like the file, nothing saved yet is reported as not existing.
+func (m *MemoryTrackedIDRepository) LoadIds() (*model.Ids, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ids == nil {
+		return nil, os.ErrNotExist
+	}
+	var ids model.Ids
+	err := copyJSON(m.ids, &ids)
+	return &ids, err
+}
+
+// SaveIds implements TrackedIDRepository.
+func (m *MemoryTrackedIDRepository) SaveIds(ids *model.Ids) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var stored model.Ids
+	if err := copyJSON(ids, &stored); err != nil {
+		return err
+	}
+	m.ids = &stored
+	return nil
+}
+
+// LoadFailedCharacters implements TrackedIDRepository.
+func (m *MemoryTrackedIDRepository) LoadFailedCharacters() (*model.FailedCharacters, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	failedChars := model.FailedCharacters{CharacterIDs: make(map[int]bool)}
+	if m.failed != nil {
+		for id, failed := range m.failed.CharacterIDs {
+			failedChars.CharacterIDs[id] = failed
+		}
+	}
+	return &failedChars, nil
+}
+
+// SaveFailedCharacters implements TrackedIDRepository.
+func (m *MemoryTrackedIDRepository) SaveFailedCharacters(failedChars *model.FailedCharacters) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := model.FailedCharacters{CharacterIDs: make(map[int]bool, len(failedChars.CharacterIDs))}
+	for id, failed := range failedChars.CharacterIDs {
+		stored.CharacterIDs[id] = failed
+	}
+	m.failed = &stored
+	return nil
+}
+
+// MemoryKillMailRepository keeps killmails and ESI entities in memory.
+type MemoryKillMailRepository struct {
+	mu        sync.RWMutex
+	killMails map[int64]model.DetailedKillMail
+	esiData   *model.ESIData
+}
+
+// NewMemoryKillMailRepository creates an empty MemoryKillMailRepository.
+func NewMemoryKillMailRepository() *MemoryKillMailRepository {
+	return &MemoryKillMailRepository{
+		killMails: make(map[int64]model.DetailedKillMail),
+		esiData: &model.ESIData{
+			AllianceInfos:    make(map[int]model.Alliance),
+			CharacterInfos:   make(map[int]model.Character),
+			CorporationInfos: make(map[int]model.Corporation),
+		},
+	}
+}
+
+// SaveKillMails implements KillMailRepository.
+func (m *MemoryKillMailRepository) SaveKillMails(ctx context.Context, killMails []model.DetailedKillMail) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.saveLocked(killMails), nil
+}
+
+func (m *MemoryKillMailRepository) saveLocked(killMails []model.DetailedKillMail) int {
+	added := 0
+	for _, km := range killMails {
+		id := km.KillMail.KillMailID
+		if id == 0 {
+			id = int64(km.EsiKillMail.KillMailID)
+		}
+		if _, ok := m.killMails[id]; ok || id == 0 {
+			continue
+		}
+		m.killMails[id] = km
+		added++
+	}
+	return added
+}
+
+// ReplaceMonth implements KillMailRepository.
+func (m *MemoryKillMailRepository) ReplaceMonth(ctx context.Context, year, month int, killMails []model.DetailedKillMail) (int, error) {
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, km := range m.killMails {
+		if !km.KillMailTime.Before(start) && km.KillMailTime.Before(end) {
+			delete(m.killMails, id)
+		}
+	}
+	return m.saveLocked(killMails), nil
+}
+
+// KillMailsBetween implements KillMailRepository.
+func (m *MemoryKillMailRepository) KillMailsBetween(ctx context.Context, start, end time.Time) ([]model.DetailedKillMail, error) {
+	m.mu.RLock()
+	killMails := []model.DetailedKillMail{}
+	for _, km := range m.killMails {
+		if !km.KillMailTime.Before(start) && km.KillMailTime.Before(end) {
+			killMails = append(killMails, km)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(killMails, func(i, j int) bool {
+		if killMails[i].KillMailTime.Equal(killMails[j].KillMailTime) {
+			return killMails[i].KillMail.KillMailID < killMails[j].KillMail.KillMailID
+		}
+		return killMails[i].KillMailTime.Before(killMails[j].KillMailTime)
+	})
+	return killMails, nil
+}
+
+// CountKillMails implements KillMailRepository.
+func (m *MemoryKillMailRepository) CountKillMails(ctx context.Context) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.killMails), nil
+}
+
+// SaveEsiData implements KillMailRepository.
+func (m *MemoryKillMailRepository) SaveEsiData(ctx context.Context, esiData *model.ESIData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, character := range esiData.CharacterInfos {
+		m.esiData.CharacterInfos[id] = character
+	}
+	for id, corporation := range esiData.CorporationInfos {
+		m.esiData.CorporationInfos[id] = corporation
+	}
+	for id, alliance := range esiData.AllianceInfos {
+		m.esiData.AllianceInfos[id] = alliance
+	}
+	return nil
+}
+
+// LoadEsiData implements KillMailRepository.
+func (m *MemoryKillMailRepository) LoadEsiData(ctx context.Context) (*model.ESIData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	esiData := &model.ESIData{
+		AllianceInfos:    make(map[int]model.Alliance, len(m.esiData.AllianceInfos)),
+		CharacterInfos:   make(map[int]model.Character, len(m.esiData.CharacterInfos)),
+		CorporationInfos: make(map[int]model.Corporation, len(m.esiData.CorporationInfos)),
+	}
+	for id, character := range m.esiData.CharacterInfos {
+		esiData.CharacterInfos[id] = character
+	}
+	for id, corporation := range m.esiData.CorporationInfos {
+		esiData.CorporationInfos[id] = corporation
+	}
+	for id, alliance := range m.esiData.AllianceInfos {
+		esiData.AllianceInfos[id] = alliance
+	}
+	return esiData, nil
+}
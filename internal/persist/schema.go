@@ -0,0 +1,257 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Schemas of the persisted documents.
+const (
+	SchemaLootSplits       = "loot_splits"
+	SchemaTrustedCharacter = "trusted_characters"
+	SchemaIds              = "ids"
+	SchemaFailedCharacters = "failed_characters"
+	SchemaMonthCursors     = "month_cursors"
+	SchemaEsiData          = "esi_data"
+	SchemaPilots           = "pilots"
+	SchemaKillMails        = "killmails"
+)
+
+// Envelope wraps a persisted document with its schema and version.
+// Documents written before envelopes were introduced are version 0.
+type Envelope struct {
+	Schema  string          `json:"schema"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Migration upgrades a document's data by one version.
+type Migration func(data json.RawMessage) (json.RawMessage, error)
+
+// schemaVersions tracks a schema's current version and the migrations leading to it,
+// keyed by the version each migration upgrades from.
+type schemaVersions struct {
+	current    int
+	migrations map[int]Migration
+}
+
+var schemas = make(map[string]*schemaVersions)
+
+// RegisterMigration registers the migration upgrading a schema's documents from version from to from+1.
+// The schema's current version becomes the highest version reachable by its migrations.
+func RegisterMigration(schema string, from int, migration Migration) {
+	versions, ok := schemas[schema]
+	if !ok {
+		versions = &schemaVersions{migrations: make(map[int]Migration)}
+		schemas[schema] = versions
+	}
+	if _, exists := versions.migrations[from]; exists {
+		panic(fmt.Sprintf("duplicate migration for %s from version %d", schema, from))
+	}
+	versions.migrations[from] = migration
+	if from+1 > versions.current {
+		versions.current = from + 1
+	}
+}
+
+// SchemaVersion returns the current version of a schema.
+func SchemaVersion(schema string) int {
+	if versions, ok := schemas[schema]; ok {
+		return versions.current
+	}
+	return 0
+}
+
+// migrateData upgrades data from version from to the schema's current version.
+func migrateData(schema string, from int, data json.RawMessage) (json.RawMessage, error) {
+	current := SchemaVersion(schema)
+	if from > current {
+		return nil, fmt.Errorf("%s version %d is newer than supported version %d", schema, from, current)
+	}
+	for version := from; version < current; version++ {
+		migration, ok := schemas[schema].migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration for %s from version %d", schema, version)
+		}
+		migrated, err := migration(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate %s from version %d: %w", schema, version, err)
+		}
+		data = migrated
+	}
+	return data, nil
+}
+
+// unwrapDocument returns a document's data and version, treating anything without an envelope
+// for schema as a version 0 document.
+func unwrapDocument(schema string, raw []byte) (json.RawMessage, int) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var envelope Envelope
+		if err := json.Unmarshal(trimmed, &envelope); err == nil && envelope.Schema == schema && envelope.Data != nil {
+			return envelope.Data, envelope.Version
+		}
+	}
+	return trimmed, 0
+}
+
+// ReadDocument reads a persisted document into v, upgrading it to the schema's current version.
+// The file itself is rewritten only by WriteDocument or MigrateData.
+func ReadDocument(fileName, schema string, v interface{}) error {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	data, version := unwrapDocument(schema, raw)
+	if len(data) == 0 {
+		return nil
+	}
+	data, err = migrateData(schema, version, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal JSON data: %v", err)
+	}
+	return nil
+}
+
+// WriteDocument writes v to a file in an envelope recording the schema's current version.
+func WriteDocument(fileName, schema string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON data: %v", err)
+	}
+	return WriteJSONToFile(fileName, Envelope{Schema: schema, Version: SchemaVersion(schema), Data: data})
+}
+
+// MigrationReport records a file upgraded, or due to be upgraded, by MigrateData.
+type MigrationReport struct {
+	File   string
+	Schema string
+	From   int
+	To     int
+
+	// Refetch marks a month file written before killmail IDs were stored. It cannot be migrated
+	// and is left in place until its month is fetched again.
+	Refetch bool
+}
+
+// documentSchemas maps persisted JSON documents, by file name, to their schema.
+var documentSchemas = map[string]string{
+	"loot_split.json":         SchemaLootSplits,
+	"trusted_characters.json": SchemaTrustedCharacter,
+	"ids.json":                SchemaIds,
+	"failed_characters.json":  SchemaFailedCharacters,
+	"esi-data.json":           SchemaEsiData,
+	"added_pilots.json":       SchemaPilots,
+	"removed_pilots.json":     SchemaPilots,
+}
+
+// schemaForFile returns the schema of a persisted file, if it has one.
+func schemaForFile(name string) (string, bool) {
+	if schema, ok := documentSchemas[name]; ok {
+		return schema, true
+	}
+	switch {
+	case strings.HasSuffix(name, "-cursors.json"):
+		return SchemaMonthCursors, true
+	case strings.HasSuffix(name, "-killmails"+killMailFileExt), strings.HasSuffix(name, "-killmails"+legacyKillMailFileExt):
+		return SchemaKillMails, true
+	}
+	return "", false
+}
+
+// MigrateData upgrades every persisted document and month file under root to its schema's current version,
+// reporting each file changed. With dryRun, files are only inspected.
+func MigrateData(root string, dryRun bool) ([]MigrationReport, error) {
+	var reports []MigrationReport
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == backupDirectory {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		schema, ok := schemaForFile(entry.Name())
+		if !ok {
+			return nil
+		}
+
+		var from int
+		if schema == SchemaKillMails {
+			from, err = migrateKillMailFile(path, dryRun)
+		} else {
+			from, err = migrateDocument(path, schema, dryRun)
+		}
+		if errors.Is(err, ErrKillMailIDMissing) {
+			reports = append(reports, MigrationReport{File: path, Schema: schema, From: from, To: from, Refetch: true})
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", path, err)
+		}
+		if to := SchemaVersion(schema); from < to {
+			reports = append(reports, MigrationReport{File: path, Schema: schema, From: from, To: to})
+		}
+		return nil
+	})
+	sort.Slice(reports, func(i, j int) bool { return reports[i].File < reports[j].File })
+	return reports, err
+}
+
+// migrateDocument rewrites a JSON document at the current version, returning the version it was at.
+func migrateDocument(path, schema string, dryRun bool) (int, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	data, version := unwrapDocument(schema, raw)
+	if version >= SchemaVersion(schema) || dryRun {
+		return version, nil
+	}
+	data, err = migrateData(schema, version, data)
+	if err != nil {
+		return version, err
+	}
+	return version, WriteJSONToFile(path, Envelope{Schema: schema, Version: SchemaVersion(schema), Data: data})
+}
+
+// migrateKillMailFile rewrites a month file at the current version, returning the version it was at.
+func migrateKillMailFile(path string, dryRun bool) (int, error) {
+	reader, err := OpenKillMailFile(path)
+	if err != nil {
+		return 0, err
+	}
+	version, err := reader.Version()
+	reader.Close()
+	if err != nil || version >= SchemaVersion(SchemaKillMails) {
+		return version, err
+	}
+	if dryRun {
+		return version, checkKillMailIDs(path)
+	}
+
+	killMailFileMu.Lock()
+	defer killMailFileMu.Unlock()
+
+	killMails, err := ReadKillMailsFromFile(path)
+	if err != nil {
+		return version, err
+	}
+	fileName := path
+	if strings.HasSuffix(path, legacyKillMailFileExt) {
+		fileName = strings.TrimSuffix(path, legacyKillMailFileExt) + killMailFileExt
+	}
+	return version, writeKillMailFile(fileName, killMails.KillMails)
+}
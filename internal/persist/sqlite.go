@@ -0,0 +1,468 @@
+package persist
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+const killMailDBFile = "data/tps/killmails.db"
+
+// schemaMigrations are applied in order; the database's user_version records how many have run.
+var schemaMigrations = []string{
+	`CREATE TABLE killmails (
+		killmail_id     INTEGER PRIMARY KEY,
+		hash            TEXT NOT NULL,
+		killmail_time   INTEGER NOT NULL,
+		solar_system_id INTEGER NOT NULL,
+		location_id     INTEGER NOT NULL,
+		total_value     REAL NOT NULL,
+		fitted_value    REAL NOT NULL,
+		dropped_value   REAL NOT NULL,
+		destroyed_value REAL NOT NULL,
+		points          INTEGER NOT NULL,
+		npc             INTEGER NOT NULL,
+		solo            INTEGER NOT NULL,
+		awox            INTEGER NOT NULL,
+		data            BLOB NOT NULL
+	);
+	CREATE INDEX idx_killmails_time ON killmails (killmail_time);
+
+	CREATE TABLE victims (
+		killmail_id    INTEGER PRIMARY KEY REFERENCES killmails (killmail_id) ON DELETE CASCADE,
+		character_id   INTEGER NOT NULL,
+		corporation_id INTEGER NOT NULL,
+		alliance_id    INTEGER NOT NULL,
+		ship_type_id   INTEGER NOT NULL,
+		damage_taken   INTEGER NOT NULL
+	);
+	CREATE INDEX idx_victims_character ON victims (character_id);
+	CREATE INDEX idx_victims_corporation ON victims (corporation_id);
+	CREATE INDEX idx_victims_alliance ON victims (alliance_id);
+	CREATE INDEX idx_victims_ship ON victims (ship_type_id);
+
+	CREATE TABLE attackers (
+		killmail_id     INTEGER NOT NULL REFERENCES killmails (killmail_id) ON DELETE CASCADE,
+		attacker_index  INTEGER NOT NULL,
+		character_id    INTEGER NOT NULL,
+		corporation_id  INTEGER NOT NULL,
+		alliance_id     INTEGER NOT NULL,
+		ship_type_id    INTEGER NOT NULL,
+		weapon_type_id  INTEGER NOT NULL,
+		damage_done     INTEGER NOT NULL,
+		final_blow      INTEGER NOT NULL,
+		security_status REAL NOT NULL,
+		PRIMARY KEY (killmail_id, attacker_index)
+	);
+	CREATE INDEX idx_attackers_character ON attackers (character_id);
+	CREATE INDEX idx_attackers_corporation ON attackers (corporation_id);
+	CREATE INDEX idx_attackers_alliance ON attackers (alliance_id);
+	CREATE INDEX idx_attackers_ship ON attackers (ship_type_id);
+
+	CREATE TABLE items (
+		killmail_id        INTEGER NOT NULL REFERENCES killmails (killmail_id) ON DELETE CASCADE,
+		item_index         INTEGER NOT NULL,
+		parent_index       INTEGER,
+		item_type_id       INTEGER NOT NULL,
+		flag               INTEGER NOT NULL,
+		singleton          INTEGER NOT NULL,
+		quantity_dropped   INTEGER NOT NULL,
+		quantity_destroyed INTEGER NOT NULL,
+		PRIMARY KEY (killmail_id, item_index)
+	);
+	CREATE INDEX idx_items_type ON items (item_type_id);
+
+	CREATE TABLE characters (
+		character_id   INTEGER PRIMARY KEY,
+		name           TEXT NOT NULL,
+		corporation_id INTEGER NOT NULL,
+		data           BLOB NOT NULL
+	);
+	CREATE TABLE corporations (
+		corporation_id INTEGER PRIMARY KEY,
+		name           TEXT NOT NULL,
+		ticker         TEXT NOT NULL,
+		alliance_id    INTEGER NOT NULL,
+		data           BLOB NOT NULL
+	);
+	CREATE TABLE alliances (
+		alliance_id INTEGER PRIMARY KEY,
+		name        TEXT NOT NULL,
+		ticker      TEXT NOT NULL,
+		data        BLOB NOT NULL
+	);
+
+	CREATE TABLE imports (
+		name        TEXT PRIMARY KEY,
+		imported_at INTEGER NOT NULL
+	);`,
+}
+
+// SQLiteKillMailRepository stores hydrated killmails and ESI entities in an embedded SQLite database.
+// Each killmail is kept whole for reads, with its victim, attackers and items broken out for indexed queries.
+type SQLiteKillMailRepository struct {
+	db *sql.DB
+}
+
+// GenerateKillMailDBFileName returns the path of the default group's killmail database.
+func GenerateKillMailDBFileName() string {
+	return DefaultKillMailStore.KillMailDBFileName()
+}
+
+// OpenKillMailRepository opens or creates the database at fileName and brings its schema up to date.
+func OpenKillMailRepository(fileName string) (*SQLiteKillMailRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", fileName)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", fileName, err)
+	}
+	// SQLite allows a single writer; one connection keeps writes from failing as busy
+	db.SetMaxOpenConns(1)
+
+	repo := &SQLiteKillMailRepository{db: db}
+	if err := repo.migrateSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// Close closes the database.
+func (r *SQLiteKillMailRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SQLiteKillMailRepository) migrateSchema() error {
+	var version int
+	if err := r.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(schemaMigrations); i++ {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(schemaMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply schema migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record schema migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveKillMails stores killmails not already present and returns how many were added.
+func (r *SQLiteKillMailRepository) SaveKillMails(ctx context.Context, killMails []model.DetailedKillMail) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, km := range killMails {
+		inserted, err := insertKillMail(ctx, tx, km)
+		if err != nil {
+			return 0, err
+		}
+		if inserted {
+			added++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit killmails: %w", err)
+	}
+	return added, nil
+}
+
+// ReplaceMonth replaces the killmails stored for a calendar month with killMails in one transaction, so the
+// repository matches a month file that was written afresh. It returns how many killmails were stored.
+func (r *SQLiteKillMailRepository) ReplaceMonth(ctx context.Context, year, month int, killMails []model.DetailedKillMail) (int, error) {
+	return r.replaceMonth(ctx, year, month, func(save func(model.DetailedKillMail) error) error {
+		for _, km := range killMails {
+			if err := save(km); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// replaceMonth deletes a month's killmails with their victims, attackers and items, then stores every
+// killmail each passes to save. Nothing changes unless each returns nil.
+func (r *SQLiteKillMailRepository) replaceMonth(ctx context.Context, year, month int, each func(save func(model.DetailedKillMail) error) error) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	for _, table := range []string{"items", "attackers", "victims"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE killmail_id IN
+			(SELECT killmail_id FROM killmails WHERE killmail_time >= ? AND killmail_time < ?)`, start.Unix(), end.Unix()); err != nil {
+			return 0, fmt.Errorf("failed to clear %s for %04d-%02d: %w", table, year, month, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM killmails WHERE killmail_time >= ? AND killmail_time < ?",
+		start.Unix(), end.Unix()); err != nil {
+		return 0, fmt.Errorf("failed to clear killmails for %04d-%02d: %w", year, month, err)
+	}
+
+	stored := 0
+	err = each(func(km model.DetailedKillMail) error {
+		inserted, err := insertKillMail(ctx, tx, km)
+		if inserted {
+			stored++
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit killmails for %04d-%02d: %w", year, month, err)
+	}
+	return stored, nil
+}
+
+func insertKillMail(ctx context.Context, tx *sql.Tx, km model.DetailedKillMail) (bool, error) {
+	id := km.KillMail.KillMailID
+	if id == 0 {
+		id = int64(km.EsiKillMail.KillMailID)
+	}
+	if id == 0 {
+		// Nothing to key the killmail on
+		return false, nil
+	}
+
+	data, err := json.Marshal(km)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode killmail %d: %w", id, err)
+	}
+
+	zkb := km.ZKB
+	result, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO killmails
+		(killmail_id, hash, killmail_time, solar_system_id, location_id, total_value, fitted_value,
+		 dropped_value, destroyed_value, points, npc, solo, awox, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, zkb.Hash, km.KillMailTime.Unix(), km.SolarSystemID, zkb.LocationID, zkb.TotalValue, zkb.FittedValue,
+		zkb.DroppedValue, zkb.DestroyedValue, zkb.Points, zkb.NPC, zkb.Solo, zkb.Awox, data)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert killmail %d: %w", id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return false, nil
+	}
+
+	victim := km.Victim
+	if _, err := tx.ExecContext(ctx, `INSERT INTO victims
+		(killmail_id, character_id, corporation_id, alliance_id, ship_type_id, damage_taken)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		id, victim.CharacterID, victim.CorporationID, victim.AllianceID, victim.ShipTypeID, victim.DamageTaken); err != nil {
+		return false, fmt.Errorf("failed to insert victim of killmail %d: %w", id, err)
+	}
+
+	for i, attacker := range km.Attackers {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO attackers
+			(killmail_id, attacker_index, character_id, corporation_id, alliance_id, ship_type_id,
+			 weapon_type_id, damage_done, final_blow, security_status)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, i, attacker.CharacterID, attacker.CorporationID, attacker.AllianceID, attacker.ShipTypeID,
+			attacker.WeaponTypeID, attacker.DamageDone, attacker.FinalBlow, attacker.SecurityStatus); err != nil {
+			return false, fmt.Errorf("failed to insert attacker of killmail %d: %w", id, err)
+		}
+	}
+
+	next := 0
+	if err := insertItems(ctx, tx, id, victim.Items, nil, &next); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// insertItems numbers items depth first, pointing container contents at their container's index.
+func insertItems(ctx context.Context, tx *sql.Tx, killMailID int64, items []model.Item, parent *int, next *int) error {
+	for _, item := range items {
+		index := *next
+		*next++
+		if _, err := tx.ExecContext(ctx, `INSERT INTO items
+			(killmail_id, item_index, parent_index, item_type_id, flag, singleton, quantity_dropped, quantity_destroyed)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			killMailID, index, parent, item.ItemTypeID, item.Flag, item.Singleton, item.QuantityDropped, item.QuantityDestroyed); err != nil {
+			return fmt.Errorf("failed to insert item of killmail %d: %w", killMailID, err)
+		}
+		if err := insertItems(ctx, tx, killMailID, item.Items, &index, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KillMailsBetween returns the killmails from start up to but not including end, oldest first.
+func (r *SQLiteKillMailRepository) KillMailsBetween(ctx context.Context, start, end time.Time) ([]model.DetailedKillMail, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT data FROM killmails
+		WHERE killmail_time >= ? AND killmail_time < ?
+		ORDER BY killmail_time, killmail_id`, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query killmails: %w", err)
+	}
+	defer rows.Close()
+
+	killMails := []model.DetailedKillMail{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var km model.DetailedKillMail
+		if err := json.Unmarshal(data, &km); err != nil {
+			return nil, fmt.Errorf("failed to decode stored killmail: %w", err)
+		}
+		killMails = append(killMails, km)
+	}
+	return killMails, rows.Err()
+}
+
+// CountKillMails returns the number of killmails stored.
+func (r *SQLiteKillMailRepository) CountKillMails(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM killmails").Scan(&count)
+	return count, err
+}
+
+// SaveEsiData stores the characters, corporations and alliances in esiData, replacing older copies.
+func (r *SQLiteKillMailRepository) SaveEsiData(ctx context.Context, esiData *model.ESIData) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, character := range esiData.CharacterInfos {
+		data, err := json.Marshal(character)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO characters (character_id, name, corporation_id, data)
+			VALUES (?, ?, ?, ?)`, id, character.Name, character.CorporationID, data); err != nil {
+			return fmt.Errorf("failed to save character %d: %w", id, err)
+		}
+	}
+	for id, corporation := range esiData.CorporationInfos {
+		data, err := json.Marshal(corporation)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO corporations (corporation_id, name, ticker, alliance_id, data)
+			VALUES (?, ?, ?, ?, ?)`, id, corporation.Name, corporation.Ticker, corporation.AllianceID, data); err != nil {
+			return fmt.Errorf("failed to save corporation %d: %w", id, err)
+		}
+	}
+	for id, alliance := range esiData.AllianceInfos {
+		data, err := json.Marshal(alliance)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO alliances (alliance_id, name, ticker, data)
+			VALUES (?, ?, ?, ?)`, id, alliance.Name, alliance.Ticker, data); err != nil {
+			return fmt.Errorf("failed to save alliance %d: %w", id, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadEsiData returns every stored character, corporation and alliance.
+func (r *SQLiteKillMailRepository) LoadEsiData(ctx context.Context) (*model.ESIData, error) {
+	esiData := &model.ESIData{
+		AllianceInfos:    make(map[int]model.Alliance),
+		CharacterInfos:   make(map[int]model.Character),
+		CorporationInfos: make(map[int]model.Corporation),
+	}
+
+	load := func(query string, decode func(id int, data []byte) error) error {
+		rows, err := r.db.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int
+			var data []byte
+			if err := rows.Scan(&id, &data); err != nil {
+				return err
+			}
+			if err := decode(id, data); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}
+
+	err := load("SELECT character_id, data FROM characters", func(id int, data []byte) error {
+		var character model.Character
+		err := json.Unmarshal(data, &character)
+		esiData.CharacterInfos[id] = character
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load characters: %w", err)
+	}
+	err = load("SELECT corporation_id, data FROM corporations", func(id int, data []byte) error {
+		var corporation model.Corporation
+		err := json.Unmarshal(data, &corporation)
+		esiData.CorporationInfos[id] = corporation
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load corporations: %w", err)
+	}
+	err = load("SELECT alliance_id, data FROM alliances", func(id int, data []byte) error {
+		var alliance model.Alliance
+		err := json.Unmarshal(data, &alliance)
+		esiData.AllianceInfos[id] = alliance
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alliances: %w", err)
+	}
+	return esiData, nil
+}
+
+// hasImported reports whether a one-shot import has already run.
+func (r *SQLiteKillMailRepository) hasImported(ctx context.Context, name string) (bool, error) {
+	var importedAt int64
+	err := r.db.QueryRowContext(ctx, "SELECT imported_at FROM imports WHERE name = ?", name).Scan(&importedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *SQLiteKillMailRepository) markImported(ctx context.Context, name string) error {
+	_, err := r.db.ExecContext(ctx, "INSERT OR REPLACE INTO imports (name, imported_at) VALUES (?, ?)", name, time.Now().Unix())
+	return err
+}
@@ -0,0 +1,87 @@
+package persist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+const monthFilesImport = "month_files"
+
+// ImportResult counts what an import of the monthly store files read and stored.
+type ImportResult struct {
+	Files     int
+	KillMails int
+	Added     int
+	Skipped   int // files written before killmail IDs were stored
+}
+
+// ImportMonthFiles copies every month file of store and the ESI data file into the repository.
+// Each month's killmails in the repository are replaced by those in its file. Once every file has been imported later calls return an
+// empty result; files written before killmail IDs were stored are skipped, and the import runs again
+// at the next start until their months have been fetched again.
+func ImportMonthFiles(ctx context.Context, repo *SQLiteKillMailRepository, store KillMailStore) (ImportResult, error) {
+	var result ImportResult
+
+	done, err := repo.hasImported(ctx, monthFilesImport)
+	if err != nil || done {
+		return result, err
+	}
+
+	months, err := store.StoredMonths()
+	if err != nil {
+		return result, err
+	}
+
+	for _, month := range months {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		err := importMonthFile(ctx, repo, store, month.Year(), int(month.Month()), &result)
+		if errors.Is(err, ErrKillMailIDMissing) {
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Files++
+	}
+
+	esiData, err := ReadEsiDataFromFile(GenerateEsiDataFileName())
+	if err != nil && !os.IsNotExist(err) {
+		return result, fmt.Errorf("failed to read ESI data: %w", err)
+	}
+	if esiData != nil {
+		if err := repo.SaveEsiData(ctx, esiData); err != nil {
+			return result, fmt.Errorf("failed to import ESI data: %w", err)
+		}
+	}
+
+	if result.Skipped > 0 {
+		return result, nil
+	}
+	return result, repo.markImported(ctx, monthFilesImport)
+}
+
+// importMonthFile streams one month file into the repository, replacing the month's killmails in a
+// single transaction.
+func importMonthFile(ctx context.Context, repo *SQLiteKillMailRepository, store KillMailStore, year, month int, result *ImportResult) error {
+	fileName := store.MonthFileName(year, month)
+	read := 0
+	added, err := repo.replaceMonth(ctx, year, month, func(save func(model.DetailedKillMail) error) error {
+		return EachKillMail(fileName, func(km model.DetailedKillMail) error {
+			read++
+			return save(km)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", fileName, err)
+	}
+	result.KillMails += read
+	result.Added += added
+	return nil
+}
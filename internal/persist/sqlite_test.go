@@ -0,0 +1,94 @@
+package persist
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+func TestImportMonthFileReplacesMonth(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	repo, err := OpenKillMailRepository(filepath.Join(dir, "killmails.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	store := KillMailStore{Group: "test", Root: "store"}
+	ctx := context.Background()
+
+	// A killmail from the month before is not part of the month's import
+	if _, err := repo.SaveKillMails(ctx, []model.DetailedKillMail{testKillMail(50, time.Date(2024, time.April, 30, 23, 59, 0, 0, time.UTC))}); err != nil {
+		t.Fatal(err)
+	}
+
+	may := func(day int) time.Time { return time.Date(2024, time.May, day, 12, 0, 0, 0, time.UTC) }
+	imports := []struct {
+		name      string
+		killMails []model.DetailedKillMail
+		// want counts the rows of each table afterwards, the April killmail included
+		want map[string]int
+	}{
+		{
+			name:      "first import",
+			killMails: []model.DetailedKillMail{testKillMail(100, may(1)), testKillMail(101, may(15)), testKillMail(102, may(31))},
+			want:      map[string]int{"killmails": 4, "victims": 4, "attackers": 8, "items": 8},
+		},
+		{
+			name:      "smaller month file",
+			killMails: []model.DetailedKillMail{testKillMail(101, may(15))},
+			want:      map[string]int{"killmails": 2, "victims": 2, "attackers": 4, "items": 4},
+		},
+	}
+
+	for _, step := range imports {
+		if err := SaveKillMailsToFile(store.MonthFileName(2024, 5), &model.KillMailData{KillMails: step.killMails}); err != nil {
+			t.Fatal(err)
+		}
+		var result ImportResult
+		if err := importMonthFile(ctx, repo, store, 2024, 5, &result); err != nil {
+			t.Fatalf("%s: importMonthFile: %v", step.name, err)
+		}
+		if result.Added != len(step.killMails) {
+			t.Errorf("%s: stored %d killmails, want %d", step.name, result.Added, len(step.killMails))
+		}
+		for table, want := range step.want {
+			var got int
+			if err := repo.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%s: %s holds %d rows, want %d", step.name, table, got, want)
+			}
+		}
+	}
+}
+
+// testKillMail returns a killmail with two attackers and a container holding one item.
+func testKillMail(id int, at time.Time) model.DetailedKillMail {
+	return model.DetailedKillMail{
+		KillMail: model.KillMail{KillMailID: int64(id), ZKB: model.ZKB{Hash: fmt.Sprintf("hash%d", id)}},
+		EsiKillMail: model.EsiKillMail{
+			KillMailID:   id,
+			KillMailTime: at,
+			Victim: model.Victim{
+				CharacterID: 1,
+				Items:       []model.Item{{ItemTypeID: 10, Items: []model.Item{{ItemTypeID: 11}}}},
+			},
+			Attackers: []model.Attacker{{CharacterID: 2}, {CharacterID: 3}},
+		},
+	}
+}
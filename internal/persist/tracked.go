@@ -0,0 +1,80 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+)
+
+// TrackedEntitiesFile holds the tracked corporations, alliances and characters of the default group and
+// any named groups. It is meant to be edited by hand as well as through the admin API, so it is YAML;
+// a path ending in .json is read and written as JSON.
+const TrackedEntitiesFile = "data/tps/tracked.yaml"
+
+// LoadTrackedEntities reads and normalizes a tracked entities file.
+func LoadTrackedEntities(fileName string) (*config.TrackedConfig, error) {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty file is most likely caught mid-save, and would otherwise stop all tracking
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, fmt.Errorf("%s is empty", fileName)
+	}
+
+	var tracked config.TrackedConfig
+	if isJSONFile(fileName) {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&tracked)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&tracked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", fileName, err)
+	}
+	if err := tracked.Normalize(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return &tracked, nil
+}
+
+// SaveTrackedEntities writes a tracked entities file, keeping a backup of the previous version.
+func SaveTrackedEntities(fileName string, tracked *config.TrackedConfig) error {
+	var data []byte
+	var err error
+	if isJSONFile(fileName) {
+		data, err = json.MarshalIndent(tracked, "", "  ")
+	} else {
+		var buf bytes.Buffer
+		buf.WriteString("# Tracked entities, reloaded automatically when this file changes.\n")
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err = encoder.Encode(tracked); err == nil {
+			err = encoder.Close()
+		}
+		data = buf.Bytes()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode tracked entities: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", fileName, err)
+	}
+	return WriteFileAtomic(fileName, data, 0644)
+}
+
+func isJSONFile(fileName string) bool {
+	return strings.EqualFold(filepath.Ext(fileName), ".json")
+}
@@ -9,28 +9,42 @@ import (
 	"github.com/guarzo/zkillanalytics/internal/model"
 )
 
-const trustedCharactersFile = "data/trust/trusted_characters.json"
+const TrustedCharactersFile = "data/trust/trusted_characters.json"
 
-// Mutex for safe concurrent access
-var mu sync.Mutex
+// FileTrustedRepository stores the trust lists in a JSON file.
+type FileTrustedRepository struct {
+	FileName string
 
-// LoadTrustedCharacters loads trusted characters and corporations from a file.
-func LoadTrustedCharacters() (*model.TrustedCharacters, error) {
-	xlog.Logf("Loading trusted characters from file: %s", trustedCharactersFile)
+	// Mutex for safe concurrent access
+	mu sync.Mutex
+}
+
+// NewFileTrustedRepository creates a FileTrustedRepository for fileName.
+func NewFileTrustedRepository(fileName string) *FileTrustedRepository {
+	return &FileTrustedRepository{FileName: fileName}
+}
+
+func newTrustedCharacters() *model.TrustedCharacters {
+	return &model.TrustedCharacters{
+		TrustedCharacters:     []model.TrustedCharacter{},
+		TrustedCorporations:   []model.TrustedCorporation{},
+		UntrustedCharacters:   []model.TrustedCharacter{},
+		UntrustedCorporations: []model.TrustedCorporation{},
+	}
+}
+
+// LoadTrusted loads trusted characters and corporations from the file.
+func (f *FileTrustedRepository) LoadTrusted() (*model.TrustedCharacters, error) {
+	xlog.Logf("Loading trusted characters from file: %s", f.FileName)
 
-	mu.Lock()
-	defer mu.Unlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
 	var trustedData model.TrustedCharacters
-	if err := ReadJSONFromFile(trustedCharactersFile, &trustedData); err != nil {
+	if err := ReadDocument(f.FileName, SchemaTrustedCharacter, &trustedData); err != nil {
 		if os.IsNotExist(err) {
 			xlog.Logf("Trusted characters file not found. Initializing empty trusted data.")
-			return &model.TrustedCharacters{
-				TrustedCharacters:     []model.TrustedCharacter{},
-				TrustedCorporations:   []model.TrustedCorporation{},
-				UntrustedCharacters:   []model.TrustedCharacter{},
-				UntrustedCorporations: []model.TrustedCorporation{},
-			}, nil
+			return newTrustedCharacters(), nil
 		}
 		xlog.Logf("Error reading trusted characters file: %v", err)
 		return nil, fmt.Errorf("failed to open trusted characters file: %v", err)
@@ -45,12 +59,12 @@ func LoadTrustedCharacters() (*model.TrustedCharacters, error) {
 	return &trustedData, nil
 }
 
-// SaveTrustedCharacters saves trusted characters and corporations to a file.
-func SaveTrustedCharacters(trustedData *model.TrustedCharacters) error {
-	mu.Lock()
-	defer mu.Unlock()
+// SaveTrusted saves trusted characters and corporations to the file.
+func (f *FileTrustedRepository) SaveTrusted(trustedData *model.TrustedCharacters) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	xlog.Logf("Saving trusted characters to file: %s", trustedCharactersFile)
+	xlog.Logf("Saving trusted characters to file: %s", f.FileName)
 	xlog.Logf("Counts: TrustedCharacters=%d, TrustedCorporations=%d, UntrustedCharacters=%d, UntrustedCorporations=%d",
 		len(trustedData.TrustedCharacters),
 		len(trustedData.TrustedCorporations),
@@ -67,5 +81,5 @@ func SaveTrustedCharacters(trustedData *model.TrustedCharacters) error {
 		xlog.Logf("CharacterID: %d, CharacterName: %s", char.CharacterID, char.CharacterName)
 	}
 
-	return WriteJSONToFile(trustedCharactersFile, trustedData)
+	return WriteDocument(f.FileName, SchemaTrustedCharacter, trustedData)
 }
@@ -71,15 +71,23 @@ func DeleteCurrentMonthFile() error {
 	return DeleteKillMailFile(currentYear, currentMonth)
 }
 
-// DeleteKillMailFile deletes a killmail file for a specific year and month.
+// DeleteKillMailFile deletes the killmail file for a specific year and month, in either format.
 func DeleteKillMailFile(year, month int) error {
 	fileName := GenerateZkillFileName(year, month)
-	return os.Remove(fileName)
+	err := os.Remove(fileName)
+	legacyErr := os.Remove(legacyKillMailFileName(fileName))
+	if err != nil && os.IsNotExist(err) {
+		return legacyErr
+	}
+	if legacyErr != nil && !os.IsNotExist(legacyErr) {
+		return legacyErr
+	}
+	return err
 }
 
-// GenerateZkillFileName creates a filename based on year and month.
+// GenerateZkillFileName creates the default store's filename for a year and month.
 func GenerateZkillFileName(year, month int) string {
-	return fmt.Sprintf("%s/%04d-%02d-killmails.json", GenerateRelativeDirectoryPath(killMailDirectory), year, month)
+	return DefaultKillMailStore.MonthFileName(year, month)
 }
 
 // Contains checks if a slice contains a specific element.
@@ -122,6 +130,25 @@ func GetDateRange(mode config.DataMode) (startDate, endDate string) {
 	return
 }
 
+// GetTimeRange returns the start and end days of the data mode as times.
+func GetTimeRange(mode config.DataMode) (time.Time, time.Time, error) {
+	startDate, endDate := GetDateRange(mode)
+	return ParseDateRange(startDate, endDate)
+}
+
+// ParseDateRange parses a start and end date in YYYY-MM-DD form.
+func ParseDateRange(startDate, endDate string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date format: %w", err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date format: %w", err)
+	}
+	return start, end, nil
+}
+
 // DaysInMonth returns the number of days in a given month and year
 func DaysInMonth(month time.Month, year string) int {
 	y, _ := strconv.Atoi(year)
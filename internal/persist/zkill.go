@@ -1,28 +1,90 @@
 package persist
 
 import (
-	"fmt"
 	"os"
+	"sync"
 
 	"github.com/guarzo/zkillanalytics/internal/model"
 )
 
 const killMailDirectory = "data/tps/store"
 
-// ReadKillMailsFromFile loads a KillMailData from a JSON file.
+// killMailFileMu serializes writers of the monthly store files.
+var killMailFileMu sync.Mutex
+
+// ReadKillMailsFromFile loads a whole month file. Use OpenKillMailFile or EachKillMail to stream it instead.
 func ReadKillMailsFromFile(fileName string) (*model.KillMailData, error) {
 	var killMailData model.KillMailData
-	if err := ReadJSONFromFile(fileName, &killMailData); err != nil {
+	err := EachKillMail(fileName, func(km model.DetailedKillMail) error {
+		killMailData.KillMails = append(killMailData.KillMails, km)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &killMailData, nil
 }
 
-// SaveKillMailsToFile saves detailed killmails to a JSON file.
+// SaveKillMailsToFile saves detailed killmails to a compressed month file.
 func SaveKillMailsToFile(fileName string, kmData *model.KillMailData) error {
-	// Ensure the directory exists
-	if err := os.MkdirAll(GenerateRelativeDirectoryPath(killMailDirectory), os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
+	killMailFileMu.Lock()
+	defer killMailFileMu.Unlock()
+
+	return writeKillMailFile(fileName, kmData.KillMails)
+}
+
+// MergeKillMailsIntoFile adds killmails to an existing month file, skipping any already present.
+// It returns the number of killmails added. A missing file is reported as os.ErrNotExist so
+// that partial months are never created; those are fetched in full by the orchestrator.
+// A month at the current schema version is appended to; older months, legacy .json ones included,
+// are rewritten compressed at the current version.
+func MergeKillMailsIntoFile(fileName string, killMails []model.DetailedKillMail) (int, error) {
+	killMailFileMu.Lock()
+	defer killMailFileMu.Unlock()
+
+	_, statErr := os.Stat(fileName)
+	version, err := killMailFileVersion(fileName)
+	if err != nil {
+		return 0, err
+	}
+	legacy := os.IsNotExist(statErr) || version < SchemaVersion(SchemaKillMails)
+
+	// Files written before killmail IDs were stored only identify killmails by hash
+	known := make(map[int64]bool)
+	knownHashes := make(map[string]bool)
+	var existing []model.DetailedKillMail
+	err = EachKillMail(fileName, func(km model.DetailedKillMail) error {
+		known[km.KillMail.KillMailID] = true
+		knownHashes[km.ZKB.Hash] = true
+		if legacy {
+			existing = append(existing, km)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var added []model.DetailedKillMail
+	for _, km := range killMails {
+		if known[km.KillMail.KillMailID] || (km.ZKB.Hash != "" && knownHashes[km.ZKB.Hash]) {
+			continue
+		}
+		known[km.KillMail.KillMailID] = true
+		knownHashes[km.ZKB.Hash] = true
+		added = append(added, km)
+	}
+
+	if len(added) == 0 {
+		return 0, nil
+	}
+	if legacy {
+		err = writeKillMailFile(fileName, append(existing, added...))
+	} else {
+		err = appendKillMailFile(fileName, added)
+	}
+	if err != nil {
+		return 0, err
 	}
-	return WriteJSONToFile(fileName, kmData)
+	return len(added), nil
 }
@@ -0,0 +1,172 @@
+// internal/service/backfill.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guarzo/zkillanalytics/internal/api/zkill"
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+// HistoryLoader returns the killmail IDs and hashes zKillboard recorded for a day.
+// Days without a history file yield no killmails rather than an error.
+type HistoryLoader func(ctx context.Context, day time.Time) ([]model.KillMail, error)
+
+// HistoryDirLoader reads history files named like zKillboard's, such as 20240131.json, from dir.
+func HistoryDirLoader(dir string) HistoryLoader {
+	return func(ctx context.Context, day time.Time) ([]model.KillMail, error) {
+		file, err := os.Open(filepath.Join(dir, zkill.HistoryFileName(day)))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		defer file.Close()
+		return zkill.ParseHistory(file)
+	}
+}
+
+// BackfillResult summarizes a Backfill run.
+type BackfillResult struct {
+	Days    int // days with a history file
+	Listed  int // killmails listed in those files
+	Tracked int // hydrated killmails involving a tracked entity
+	Added   int // tracked killmails not already in the store
+	Failed  int // killmails that could not be hydrated
+}
+
+// BackfillService imports past months from zKillboard's daily history files instead of paging
+// each tracked entity's feed.
+type BackfillService struct {
+	LoadHistory HistoryLoader
+	Hydrator    *KillMailHydrator
+	Repository  persist.KillMailRepository
+	Logger      *logrus.Logger
+
+	// Group is the tracked group whose killmails are kept, and Store holds that group's month files
+	Group string
+	Store persist.KillMailStore
+}
+
+// NewBackfillService creates a BackfillService reading history through loadHistory and storing the default
+// group's killmails into repository.
+func NewBackfillService(loadHistory HistoryLoader, hydrator *KillMailHydrator, repository persist.KillMailRepository, logger *logrus.Logger) *BackfillService {
+	return &BackfillService{
+		LoadHistory: loadHistory,
+		Hydrator:    hydrator,
+		Repository:  repository,
+		Logger:      logger,
+		Group:       config.DefaultGroup,
+		Store:       persist.DefaultKillMailStore,
+	}
+}
+
+// Backfill imports every whole month from startDate through endDate. Each day's killmails are hydrated,
+// filtered down to those involving a tracked entity and merged into the month's store file, whose fetch
+// cursors are then recorded for params so later refreshes continue from there.
+func (bs *BackfillService) Backfill(ctx context.Context, startDate, endDate time.Time, params *model.Params) (BackfillResult, error) {
+	var total BackfillResult
+	for _, ym := range generateYearMonthPairs(startDate, endDate) {
+		result, err := bs.backfillMonth(ctx, ym.Year, ym.Month, params)
+		total.Days += result.Days
+		total.Listed += result.Listed
+		total.Tracked += result.Tracked
+		total.Added += result.Added
+		total.Failed += result.Failed
+		if err != nil {
+			return total, fmt.Errorf("failed to backfill %04d-%02d: %w", ym.Year, ym.Month, err)
+		}
+	}
+	return total, nil
+}
+
+func (bs *BackfillService) backfillMonth(ctx context.Context, year, month int, params *model.Params) (BackfillResult, error) {
+	var result BackfillResult
+	var tracked []model.DetailedKillMail
+	seen := make(map[int]bool)
+	group, _ := config.Group(bs.Group)
+
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	today := time.Now().UTC()
+	for day := first; day.Month() == first.Month() && !day.After(today); day = day.AddDate(0, 0, 1) {
+		killMails, err := bs.LoadHistory(ctx, day)
+		if err != nil {
+			return result, fmt.Errorf("failed to load history for %s: %w", day.Format("2006-01-02"), err)
+		}
+		if len(killMails) == 0 {
+			bs.Logger.Debugf("No history for %s", day.Format("2006-01-02"))
+			continue
+		}
+		result.Days++
+		result.Listed += len(killMails)
+
+		hydrated, hydration, err := bs.Hydrator.Hydrate(ctx, killMails, seen)
+		if err != nil {
+			return result, err
+		}
+		result.Failed += hydration.Failed
+
+		dayTracked := 0
+		for _, km := range hydrated {
+			if IsTrackedKillMail(group, &km.EsiKillMail) {
+				tracked = append(tracked, km)
+				dayTracked++
+			}
+		}
+		result.Tracked += dayTracked
+		bs.Logger.Infof("Backfilled %s: %d listed, %d hydrated, %d tracked, %d failed",
+			day.Format("2006-01-02"), len(killMails), hydration.Hydrated, dayTracked, hydration.Failed)
+	}
+
+	if result.Days == 0 {
+		bs.Logger.Warnf("No history found for %04d-%02d, leaving the store untouched", year, month)
+		return result, nil
+	}
+	if result.Failed > 0 {
+		bs.Logger.Warnf("%d killmails in %04d-%02d could not be hydrated and are missing from the store", result.Failed, year, month)
+	}
+
+	added, err := bs.storeMonth(ctx, year, month, tracked, params)
+	result.Added = added
+	return result, err
+}
+
+// storeMonth merges killmails into the month's store file, creating it if needed, and the repository,
+// then records the month's cursors.
+func (bs *BackfillService) storeMonth(ctx context.Context, year, month int, killMails []model.DetailedKillMail, params *model.Params) (int, error) {
+	fileName := bs.Store.MonthFileName(year, month)
+
+	added, err := persist.MergeKillMailsIntoFile(fileName, killMails)
+	if errors.Is(err, os.ErrNotExist) {
+		added = len(killMails)
+		err = persist.SaveKillMailsToFile(fileName, &model.KillMailData{KillMails: killMails})
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to store killmails in %s: %w", fileName, err)
+	}
+	if _, err := bs.Repository.SaveKillMails(ctx, killMails); err != nil {
+		return added, fmt.Errorf("failed to store killmails for %04d-%02d: %w", year, month, err)
+	}
+
+	cursors, err := DeriveMonthCursorsFromFile(fileName, params)
+	if err != nil {
+		return added, fmt.Errorf("failed to read back %s: %w", fileName, err)
+	}
+	if err := bs.Store.SaveMonthCursors(year, month, cursors); err != nil {
+		bs.Logger.Errorf("Failed to save fetch cursors for %04d-%02d: %v", year, month, err)
+	}
+
+	bs.Logger.Infof("Stored %d new killmails for %04d-%02d in %s", added, year, month, fileName)
+	return added, nil
+}
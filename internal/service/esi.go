@@ -3,7 +3,6 @@ package service
 import (
 	"context"
 	"errors"
-	"net/http"
 
 	"github.com/sirupsen/logrus"
 
@@ -87,89 +86,40 @@ func (es *EsiService) GetAllianceInfo(ctx context.Context, allianceID int) (*mod
 	return alliance, nil
 }
 
-// LoadTrackedCharacters loads all tracked characters from the killmails into ESIData.
-func (es *EsiService) LoadTrackedCharacters(ctx context.Context, killMails []model.DetailedKillMail, esiData *model.ESIData) error {
-	es.Logger.Infof("Loading tracked %d characters into ESIData", len(killMails))
-
-	for i, km := range killMails {
-		if i%100 == 0 {
-			es.Logger.Infof("Loading characters from killmail %d", i)
-		}
-
-		// Add victim to ESI data if not already present
-		if km.Victim.CharacterID != 0 {
-			if _, exists := esiData.CharacterInfos[km.Victim.CharacterID]; !exists {
-				victimData, err := es.GetCharacterInfo(ctx, km.Victim.CharacterID)
-				if err != nil {
-					es.Logger.Errorf("Failed to fetch victim character data for ID %d: %v", km.Victim.CharacterID, err)
-					continue
-				}
-				if victimData == nil {
-					continue
-				}
-				esiData.CharacterInfos[km.Victim.CharacterID] = *victimData
-			}
-		}
+// LookupIDs resolves exact character, corporation and alliance names to their IDs.
+func (es *EsiService) LookupIDs(ctx context.Context, names []string) (*model.UniverseIDs, error) {
+	return es.EsiClient.PostUniverseIDs(ctx, names)
+}
 
-		// Add attackers to ESI data if not already present
-		for _, attacker := range km.Attackers {
-			if attacker.CharacterID != 0 {
-				if _, exists := esiData.CharacterInfos[attacker.CharacterID]; !exists {
-					attackerData, err := es.GetCharacterInfo(ctx, attacker.CharacterID)
-					if err != nil {
-						es.Logger.Errorf("Failed to fetch attacker character data for ID %d: %v", attacker.CharacterID, err)
-						continue
-					}
-					if attackerData == nil {
-						continue
-					}
-					esiData.CharacterInfos[attacker.CharacterID] = *attackerData
-				}
-			}
-
-			// Add corporation and alliance info for attacker
-			if attacker.CorporationID != 0 {
-				if _, exists := esiData.CorporationInfos[attacker.CorporationID]; !exists {
-					corpData, err := es.GetCorporationInfo(ctx, attacker.CorporationID)
-					if err != nil {
-						es.Logger.Errorf("Failed to fetch corporation data for ID %d: %v", attacker.CorporationID, err)
-						continue
-					}
-					esiData.CorporationInfos[attacker.CorporationID] = *corpData
-				}
-			}
-			if attacker.AllianceID != 0 {
-				if _, exists := esiData.AllianceInfos[attacker.AllianceID]; !exists {
-					allianceData, err := es.GetAllianceInfo(ctx, attacker.AllianceID)
-					if err != nil {
-						es.Logger.Errorf("Failed to fetch alliance data for ID %d: %v", attacker.AllianceID, err)
-						continue
-					}
-					esiData.AllianceInfos[attacker.AllianceID] = *allianceData
-				}
-			}
-		}
+// ResolveKillMailEntities collects every entity on killMails that esiData does not yet hold
+// and resolves them in bulk.
+func (es *EsiService) ResolveKillMailEntities(ctx context.Context, killMails []model.DetailedKillMail, esiData *model.ESIData) error {
+	ids := esi.NewEntityIDs()
+	for i := range killMails {
+		ids.AddKillMail(&killMails[i].EsiKillMail, esiData)
+	}
+	if ids.Empty() {
+		return nil
 	}
+	return es.EsiClient.ResolveEntities(ctx, ids, esiData)
+}
 
-	es.Logger.Info("Finished loading tracked characters into ESIData")
-	return nil
+// CorporationTicker implements EntityResolver with a corporation lookup, which ESI responses are cached for.
+func (es *EsiService) CorporationTicker(ctx context.Context, corporationID int) (string, error) {
+	corporation, err := es.EsiClient.GetCorporationInfo(ctx, corporationID)
+	if err != nil {
+		return "", err
+	}
+	return corporation.Ticker, nil
 }
 
-// RefreshEsiData refreshes character information in ChartData.
-func (es *EsiService) RefreshEsiData(ctx context.Context, chartData *model.ChartData, client *http.Client) error {
-	es.Logger.Info("Refreshing character information in ChartData.")
+// RefreshEntities refreshes character affiliations in esiData.
+func (es *EsiService) RefreshEntities(ctx context.Context, esiData *model.ESIData) error {
+	es.Logger.Infof("Refreshing %d characters in ESIData.", len(esiData.CharacterInfos))
 
-	for characterID := range chartData.ESIData.CharacterInfos {
-		es.Logger.Infof("Refreshing character ID: %d", characterID)
-		charData, err := es.GetCharacterInfo(ctx, characterID)
-		if err != nil {
-			es.Logger.Errorf("Failed to refresh character ID %d: %v", characterID, err)
-			continue
-		}
-		if charData == nil {
-			continue
-		}
-		chartData.ESIData.CharacterInfos[characterID] = *charData
+	if err := es.EsiClient.RefreshAffiliations(ctx, esiData); err != nil {
+		es.Logger.Errorf("Failed to refresh character affiliations: %v", err)
+		return err
 	}
 
 	es.Logger.Info("Character information refresh complete.")
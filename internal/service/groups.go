@@ -0,0 +1,123 @@
+// internal/service/groups.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+// ErrUnknownGroup is returned for a group that is not in the tracked entities file.
+var ErrUnknownGroup = errors.New("unknown tracked group")
+
+// GroupRegistry holds an OrchestrateService for each tracked group. Named groups are opened on first use,
+// each with its own store, killmail database and prefetcher, sharing the default group's fetching and caches.
+type GroupRegistry struct {
+	Default *OrchestrateService
+	Logger  *logrus.Logger
+
+	ctx    context.Context
+	mu     sync.Mutex
+	groups map[string]*trackedGroup
+}
+
+type trackedGroup struct {
+	service    *OrchestrateService
+	repository *persist.SQLiteKillMailRepository
+	prefetch   *PrefetchService
+}
+
+// NewGroupRegistry creates a GroupRegistry around the default group's service. Prefetchers of the
+// groups it opens run until ctx is cancelled.
+func NewGroupRegistry(ctx context.Context, defaultService *OrchestrateService, logger *logrus.Logger) *GroupRegistry {
+	return &GroupRegistry{
+		Default: defaultService,
+		Logger:  logger,
+		ctx:     ctx,
+		groups:  make(map[string]*trackedGroup),
+	}
+}
+
+// Get returns the service for a group, opening the group if this is its first use.
+func (g *GroupRegistry) Get(name string) (*OrchestrateService, error) {
+	if name == config.DefaultGroup {
+		return g.Default, nil
+	}
+	if _, ok := config.Group(name); !ok {
+		return nil, ErrUnknownGroup
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if group, ok := g.groups[name]; ok {
+		return group.service, nil
+	}
+
+	store := persist.GroupKillMailStore(name)
+	repository, err := persist.OpenKillMailRepository(store.KillMailDBFileName())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open killmail database for group %s: %w", name, err)
+	}
+	imported, err := persist.ImportMonthFiles(g.ctx, repository, store)
+	if err != nil {
+		repository.Close()
+		return nil, fmt.Errorf("failed to import store files for group %s: %w", name, err)
+	}
+	if imported.Files > 0 {
+		g.Logger.Infof("Imported %d of %d killmails from %d store files for group %s", imported.Added, imported.KillMails, imported.Files, name)
+	}
+	if imported.Skipped > 0 {
+		g.Logger.Warnf("Skipped %d store files without killmail IDs for group %s; their months will be fetched again", imported.Skipped, name)
+	}
+
+	svc := g.Default.ForGroup(name, store, repository, store.TrackedIDRepository())
+	prefetch := NewPrefetchService(svc, g.Logger)
+	prefetch.startPrefetching(g.ctx)
+	g.groups[name] = &trackedGroup{service: svc, repository: repository, prefetch: prefetch}
+	g.Logger.Infof("Opened tracked group %s in %s", name, store.Root)
+	return svc, nil
+}
+
+// OpenAll opens every group in the tracked entities file, logging any that fail.
+func (g *GroupRegistry) OpenAll() {
+	for _, name := range config.GroupNames() {
+		if _, err := g.Get(name); err != nil {
+			g.Logger.Errorf("Failed to open tracked group %s: %v", name, err)
+		}
+	}
+}
+
+// Active returns the services of the default group and of every opened group still in the tracked entities file.
+func (g *GroupRegistry) Active() []*OrchestrateService {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	services := []*OrchestrateService{g.Default}
+	for _, name := range config.GroupNames() {
+		if group, ok := g.groups[name]; ok {
+			services = append(services, group.service)
+		}
+	}
+	return services
+}
+
+// Close waits for the named groups' prefetchers to stop and closes their databases.
+// The context passed to NewGroupRegistry must be cancelled first.
+func (g *GroupRegistry) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for name, group := range g.groups {
+		group.prefetch.wg.Wait()
+		if err := group.repository.Close(); err != nil {
+			g.Logger.Errorf("Failed to close killmail database for group %s: %v", name, err)
+		}
+	}
+}
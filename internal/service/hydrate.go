@@ -0,0 +1,136 @@
+// internal/service/hydrate.go
+
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// DefaultHydrationConcurrency is used when no positive concurrency is configured.
+const DefaultHydrationConcurrency = 8
+
+// HydrationResult summarizes a single Hydrate call.
+type HydrationResult struct {
+	Requested int // killmails passed in
+	Skipped   int // already seen or duplicated within the batch
+	Hydrated  int // successfully fetched from ESI
+	Failed    int // fetch errors, logged and dropped
+
+	// FailedIDs are the killmails that could not be fetched, so callers can retry them later
+	FailedIDs []int64
+}
+
+// KillMailHydrator fetches full ESI killmails with a bounded pool of workers.
+type KillMailHydrator struct {
+	Concurrency int
+	Fetch       func(ctx context.Context, killMailID int, hash string) (*model.EsiKillMail, error)
+	Logger      *logrus.Logger
+}
+
+// NewKillMailHydrator creates a hydrator that runs at most concurrency fetches at once.
+func NewKillMailHydrator(concurrency int, fetch func(ctx context.Context, killMailID int, hash string) (*model.EsiKillMail, error), logger *logrus.Logger) *KillMailHydrator {
+	if concurrency <= 0 {
+		concurrency = DefaultHydrationConcurrency
+	}
+	return &KillMailHydrator{
+		Concurrency: concurrency,
+		Fetch:       fetch,
+		Logger:      logger,
+	}
+}
+
+type hydrationJob struct {
+	index int
+	mail  model.KillMail
+}
+
+// Hydrate fetches ESI details for killMails and returns them in input order, once per killmail ID.
+// IDs already present in seen are skipped, and every hydrated ID is added to it.
+// Jobs are handed to the workers through a channel no larger than the pool, so dispatch blocks
+// while all workers are busy. If ctx is cancelled, dispatch stops and ctx.Err() is returned.
+func (h *KillMailHydrator) Hydrate(ctx context.Context, killMails []model.KillMail, seen map[int]bool) ([]model.DetailedKillMail, HydrationResult, error) {
+	result := HydrationResult{Requested: len(killMails)}
+
+	// Deduplicate against earlier batches and within this one before dispatching
+	var jobs []hydrationJob
+	queued := make(map[int64]bool, len(killMails))
+	for _, mail := range killMails {
+		if seen[int(mail.KillMailID)] || queued[mail.KillMailID] {
+			result.Skipped++
+			continue
+		}
+		queued[mail.KillMailID] = true
+		jobs = append(jobs, hydrationJob{index: len(jobs), mail: mail})
+	}
+	if len(jobs) == 0 {
+		return nil, result, nil
+	}
+
+	hydrated := make([]*model.DetailedKillMail, len(jobs))
+	jobCh := make(chan hydrationJob, h.Concurrency)
+
+	var (
+		wg     sync.WaitGroup
+		failMu sync.Mutex
+	)
+
+	workers := h.Concurrency
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				if ctx.Err() != nil {
+					continue
+				}
+				esiKillMail, err := h.Fetch(ctx, int(job.mail.KillMailID), job.mail.ZKB.Hash)
+				if err != nil {
+					if ctx.Err() == nil {
+						h.Logger.Errorf("Error processing kill mail ID %d: %v", job.mail.KillMailID, err)
+					}
+					failMu.Lock()
+					result.Failed++
+					result.FailedIDs = append(result.FailedIDs, job.mail.KillMailID)
+					failMu.Unlock()
+					continue
+				}
+				// Each job owns its slot, so no lock is needed here
+				hydrated[job.index] = &model.DetailedKillMail{KillMail: job.mail, EsiKillMail: *esiKillMail}
+			}
+		}()
+	}
+
+dispatch:
+	for _, job := range jobs {
+		select {
+		case jobCh <- job:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobCh)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, result, err
+	}
+
+	output := make([]model.DetailedKillMail, 0, len(jobs))
+	for _, dkm := range hydrated {
+		if dkm == nil {
+			continue
+		}
+		seen[int(dkm.KillMail.KillMailID)] = true
+		output = append(output, *dkm)
+	}
+	result.Hydrated = len(output)
+	return output, result, nil
+}
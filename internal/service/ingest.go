@@ -0,0 +1,164 @@
+// internal/service/ingest.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guarzo/zkillanalytics/internal/api/zkill"
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+const (
+	ingestRetryDelay    = 5 * time.Second
+	ingestMaxRetryDelay = 2 * time.Minute
+)
+
+// IngestService consumes a live RedisQ feed and merges relevant killmails into the monthly store
+// of every tracked group they involve.
+type IngestService struct {
+	RedisQ *zkill.RedisQClient
+	Source KillmailSource
+	Groups *GroupRegistry
+	Logger *logrus.Logger
+
+	// WaitGroup to track the listener goroutine
+	wg sync.WaitGroup
+}
+
+// NewIngestService initializes and returns a new IngestService instance.
+// Packages without an embedded killmail are hydrated through source.
+func NewIngestService(redisQ *zkill.RedisQClient, source KillmailSource, groups *GroupRegistry, logger *logrus.Logger) *IngestService {
+	return &IngestService{
+		RedisQ: redisQ,
+		Source: source,
+		Groups: groups,
+		Logger: logger,
+	}
+}
+
+// Start begins listening to the feed until the context is cancelled.
+func (is *IngestService) Start(ctx context.Context) {
+	is.wg.Add(1)
+	go is.run(ctx)
+	is.Logger.Infof("IngestService started, listening on %s as %s", is.RedisQ.URL, is.RedisQ.QueueID)
+}
+
+// Stop waits for the listener to exit. The context passed to Start must be cancelled first.
+func (is *IngestService) Stop() {
+	is.Logger.Info("Waiting for IngestService to stop...")
+	is.wg.Wait()
+	is.Logger.Info("IngestService stopped.")
+}
+
+// run contains the main listen loop, backing off when the feed is unavailable.
+func (is *IngestService) run(ctx context.Context) {
+	defer is.wg.Done()
+	delay := ingestRetryDelay
+
+	for {
+		if ctx.Err() != nil {
+			is.Logger.Info("IngestService received context cancellation.")
+			return
+		}
+
+		pkg, err := is.RedisQ.Listen(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			is.Logger.Warnf("Error listening for killmails, retrying in %v: %v", delay, err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+			delay *= 2
+			if delay > ingestMaxRetryDelay {
+				delay = ingestMaxRetryDelay
+			}
+			continue
+		}
+		delay = ingestRetryDelay
+
+		if pkg == nil {
+			continue
+		}
+
+		if err := is.handlePackage(ctx, pkg); err != nil {
+			is.Logger.Errorf("Error ingesting killmail %d: %v", pkg.KillID, err)
+		}
+	}
+}
+
+// handlePackage hydrates a package if needed and stores it for each group tracking an entity it involves.
+func (is *IngestService) handlePackage(ctx context.Context, pkg *zkill.RedisQPackage) error {
+	esiKillMail := pkg.KillMail
+	if esiKillMail == nil {
+		fetched, err := is.Source.GetEsiKillMail(ctx, int(pkg.KillID), pkg.ZKB.Hash)
+		if err != nil {
+			return err
+		}
+		esiKillMail = fetched
+	}
+
+	detailed := model.DetailedKillMail{
+		KillMail:    model.KillMail{KillMailID: pkg.KillID, ZKB: pkg.ZKB},
+		EsiKillMail: *esiKillMail,
+	}
+
+	var errs []error
+	for _, svc := range is.Groups.Active() {
+		tracked, ok := config.Group(svc.Group)
+		if !ok || !IsTrackedKillMail(tracked, esiKillMail) {
+			continue
+		}
+		if err := is.storeKillMail(ctx, svc, detailed); err != nil {
+			errs = append(errs, fmt.Errorf("group %s: %w", svc.Group, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// storeKillMail merges a killmail into a group's store file and repository.
+func (is *IngestService) storeKillMail(ctx context.Context, svc *OrchestrateService, detailed model.DetailedKillMail) error {
+	killTime := detailed.KillMailTime.UTC()
+	fileName := svc.Store.MonthFileName(killTime.Year(), int(killTime.Month()))
+	added, err := persist.MergeKillMailsIntoFile(fileName, []model.DetailedKillMail{detailed})
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			is.Logger.Debugf("No %s store file for %04d-%02d yet; killmail %d will arrive with the full fetch", svc.Group, killTime.Year(), killTime.Month(), detailed.KillMail.KillMailID)
+			return nil
+		}
+		return err
+	}
+	if _, err := svc.Repository.SaveKillMails(ctx, []model.DetailedKillMail{detailed}); err != nil {
+		return err
+	}
+
+	if added > 0 {
+		is.Logger.Infof("Ingested killmail %d into %s", detailed.KillMail.KillMailID, fileName)
+	}
+	return nil
+}
+
+// IsTrackedKillMail reports whether the victim or any attacker is one of the tracked entities.
+func IsTrackedKillMail(tracked config.TrackedEntities, km *model.EsiKillMail) bool {
+	if tracked.DisplayCharacter(km.Victim.CharacterID, km.Victim.CorporationID, km.Victim.AllianceID) {
+		return true
+	}
+	for _, attacker := range km.Attackers {
+		if tracked.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,442 @@
+// internal/service/jobs.go
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JobState is the lifecycle stage of a job.
+type JobState string
+
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobFailed  JobState = "failed"
+)
+
+// jobRetention is how long finished jobs can still be polled, and their unclaimed results collected.
+const jobRetention = 30 * time.Minute
+
+// errJobResultReleased is returned by Wait once every caller given the job has collected its result.
+var errJobResultReleased = errors.New("job result already released")
+
+// JobFunc is the work a job performs.
+type JobFunc func(ctx context.Context) (interface{}, error)
+
+// Job is a handle on queued or running work, shared by every caller that asked for the same key.
+type Job struct {
+	ID  string
+	Key string
+
+	fn      JobFunc
+	timeout time.Duration
+	done    chan struct{}
+
+	mu         sync.Mutex
+	state      JobState
+	createdAt  time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+	result     interface{}
+	err        error
+
+	// holders counts the callers given the job by Submit or Claim that have yet to return from Wait, and
+	// unclaimed the holds handed over by Detach that nobody has claimed yet. The result is dropped once the
+	// job has finished and neither is left, so finished jobs keep only their status.
+	holders   int
+	unclaimed int
+	released  bool
+
+	progress    *ProgressEvent
+	subscribers map[chan ProgressEvent]struct{}
+}
+
+// JobStatus is a snapshot of a job for polling.
+type JobStatus struct {
+	ID         string         `json:"id"`
+	State      JobState       `json:"state"`
+	CreatedAt  time.Time      `json:"created_at"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Progress   *ProgressEvent `json:"progress,omitempty"`
+}
+
+// Done is closed when the job finishes.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait blocks until the job finishes or ctx ends. Ending ctx stops only the wait, not the job.
+// Each caller given the job by Submit or Claim should Wait on it once or Detach from it; the result is not
+// kept for later calls.
+func (j *Job) Wait(ctx context.Context) (interface{}, error) {
+	defer j.release()
+
+	select {
+	case <-j.done:
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if j.released && j.err == nil {
+			return nil, errJobResultReleased
+		}
+		return j.result, j.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WaitDone blocks until the job finishes or ctx ends and reports whether the job has finished. Unlike Wait
+// it neither collects the result nor gives up the caller's hold, so callers can first wait on several jobs.
+func (j *Job) WaitDone(ctx context.Context) bool {
+	select {
+	case <-j.done:
+		return true
+	case <-ctx.Done():
+	}
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Detach gives up a caller's hold on the job without collecting the result, which is kept for a caller
+// that later claims the job by ID through JobRunner.Claim, such as a page polling the job, until the job
+// is pruned. Use it instead of Wait when handing the job's ID to someone else.
+func (j *Job) Detach() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.holders > 0 {
+		j.holders--
+		j.unclaimed++
+	}
+}
+
+// release gives up a caller's hold on the job's result, dropping the result if it was the last one.
+func (j *Job) release() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.holders > 0 {
+		j.holders--
+	}
+	j.dropResultLocked()
+}
+
+// dropResultLocked drops the result of a finished job nobody is waiting on or has yet to claim.
+func (j *Job) dropResultLocked() {
+	if j.holders == 0 && j.unclaimed == 0 && !j.finishedAt.IsZero() {
+		j.result = nil
+		j.released = true
+	}
+}
+
+// Status returns a snapshot of the job.
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := JobStatus{ID: j.ID, State: j.state, CreatedAt: j.createdAt}
+	if !j.startedAt.IsZero() {
+		startedAt := j.startedAt
+		status.StartedAt = &startedAt
+	}
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		status.FinishedAt = &finishedAt
+	}
+	if j.err != nil {
+		status.Error = j.err.Error()
+	}
+	if j.progress != nil {
+		progress := *j.progress
+		status.Progress = &progress
+	}
+	return status
+}
+
+// Subscribe returns a channel receiving the job's progress as it changes, starting with the latest
+// snapshot if there is one, and a function to stop receiving. The channel is never closed; watch Done
+// for the end of the job. Snapshots are dropped rather than block the job when a subscriber falls behind.
+func (j *Job) Subscribe() (<-chan ProgressEvent, func()) {
+	ch := make(chan ProgressEvent, 16)
+
+	j.mu.Lock()
+	if j.subscribers == nil {
+		j.subscribers = make(map[chan ProgressEvent]struct{})
+	}
+	j.subscribers[ch] = struct{}{}
+	if j.progress != nil {
+		ch <- *j.progress
+	}
+	j.mu.Unlock()
+
+	return ch, func() {
+		j.mu.Lock()
+		delete(j.subscribers, ch)
+		j.mu.Unlock()
+	}
+}
+
+func (j *Job) publish(event ProgressEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.progress = &event
+	for ch := range j.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// JobRunner runs jobs one at a time in submission order. Submitting a key that is already queued
+// or running joins the existing job instead of adding another.
+type JobRunner struct {
+	Logger *logrus.Logger
+
+	// ctx is the root of every job's context, cancelled by Stop
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	mu       sync.Mutex
+	inFlight map[string]*Job
+	jobs     map[string]*Job
+	pending  []*Job
+	wake     chan struct{}
+}
+
+// NewJobRunner creates a JobRunner and starts its worker, which runs until Stop is called.
+func NewJobRunner(logger *logrus.Logger) *JobRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	jr := &JobRunner{
+		Logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+		stopped:  make(chan struct{}),
+		inFlight: make(map[string]*Job),
+		jobs:     make(map[string]*Job),
+		wake:     make(chan struct{}, 1),
+	}
+	go jr.work()
+	return jr
+}
+
+// Stop cancels the running job's context, fails the jobs still queued and waits for the worker to exit.
+// Jobs submitted afterwards fail straight away.
+func (jr *JobRunner) Stop() {
+	jr.cancel()
+	<-jr.stopped
+}
+
+// Submit queues fn under key, or returns the job already queued or running for key.
+// The job runs detached from any caller with the given timeout.
+func (jr *JobRunner) Submit(key string, timeout time.Duration, fn JobFunc) *Job {
+	jr.mu.Lock()
+	if job, ok := jr.inFlight[key]; ok {
+		job.mu.Lock()
+		job.holders++
+		job.mu.Unlock()
+		jr.mu.Unlock()
+		jr.Logger.Infof("Joining in-flight job %s for %s", job.ID, key)
+		return job
+	}
+
+	job := &Job{
+		ID:        newJobID(),
+		Key:       key,
+		fn:        fn,
+		timeout:   timeout,
+		done:      make(chan struct{}),
+		state:     JobQueued,
+		createdAt: time.Now(),
+		holders:   1,
+	}
+	if err := jr.ctx.Err(); err != nil {
+		job.err = fmt.Errorf("job runner stopped: %w", err)
+		job.state = JobFailed
+		job.finishedAt = job.createdAt
+		close(job.done)
+		jr.jobs[job.ID] = job
+		jr.mu.Unlock()
+		return job
+	}
+	jr.inFlight[key] = job
+	jr.jobs[job.ID] = job
+	jr.pending = append(jr.pending, job)
+	jr.pruneLocked()
+	jr.mu.Unlock()
+
+	select {
+	case jr.wake <- struct{}{}:
+	default:
+	}
+	jr.Logger.Infof("Queued job %s for %s", job.ID, key)
+	return job
+}
+
+// Job returns a queued, running or recently finished job by ID.
+func (jr *JobRunner) Job(id string) (*Job, bool) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	job, ok := jr.jobs[id]
+	return job, ok
+}
+
+// Claim takes over a hold handed over by Detach on the job with the given ID, provided it was submitted
+// under key. The caller should then Wait on the job as if Submit had returned it. It reports false if there
+// is no such job or no hold left to claim, in which case the caller should Submit instead.
+func (jr *JobRunner) Claim(id, key string) (*Job, bool) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	job, ok := jr.jobs[id]
+	if !ok || job.Key != key {
+		return nil, false
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.unclaimed == 0 {
+		return nil, false
+	}
+	job.unclaimed--
+	job.holders++
+	return job, true
+}
+
+func (jr *JobRunner) work() {
+	defer close(jr.stopped)
+	for {
+		jr.mu.Lock()
+		if err := jr.ctx.Err(); err != nil {
+			pending := jr.pending
+			jr.pending = nil
+			jr.mu.Unlock()
+			for _, job := range pending {
+				jr.finish(job, nil, fmt.Errorf("job runner stopped: %w", err))
+			}
+			return
+		}
+		if len(jr.pending) == 0 {
+			jr.mu.Unlock()
+			select {
+			case <-jr.wake:
+			case <-jr.ctx.Done():
+			}
+			continue
+		}
+		job := jr.pending[0]
+		jr.pending = jr.pending[1:]
+		jr.mu.Unlock()
+
+		jr.run(job)
+	}
+}
+
+func (jr *JobRunner) run(job *Job) {
+	job.mu.Lock()
+	job.state = JobRunning
+	job.startedAt = time.Now()
+	job.mu.Unlock()
+	jr.Logger.Infof("Running job %s for %s", job.ID, job.Key)
+
+	ctx, cancel := context.WithTimeout(jr.ctx, job.timeout)
+	ctx = WithProgress(ctx, NewProgressTracker(job.publish))
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				jr.Logger.Errorf("Recovered from panic in job %s: %v", job.ID, r)
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return job.fn(ctx)
+	}()
+	cancel()
+	duration := jr.finish(job, result, err)
+
+	if err != nil {
+		jr.Logger.Errorf("Job %s for %s failed after %v: %v", job.ID, job.Key, duration, err)
+		return
+	}
+	jr.Logger.Infof("Job %s for %s finished in %v", job.ID, job.Key, duration)
+}
+
+// finish records a job's outcome, wakes its waiters and returns how long it ran.
+func (jr *JobRunner) finish(job *Job, result interface{}, err error) time.Duration {
+	job.mu.Lock()
+	job.result, job.err = result, err
+	job.finishedAt = time.Now()
+	job.state = JobDone
+	if err != nil {
+		job.state = JobFailed
+	}
+	job.dropResultLocked()
+	var duration time.Duration
+	if !job.startedAt.IsZero() {
+		duration = job.finishedAt.Sub(job.startedAt)
+	}
+	job.mu.Unlock()
+
+	// Later submissions of the key start fresh work
+	jr.mu.Lock()
+	delete(jr.inFlight, job.Key)
+	jr.pruneLocked()
+	jr.mu.Unlock()
+	close(job.done)
+	return duration
+}
+
+// pruneLocked forgets jobs that finished more than jobRetention ago, along with any results never claimed.
+func (jr *JobRunner) pruneLocked() {
+	cutoff := time.Now().Add(-jobRetention)
+	for id, job := range jr.jobs {
+		job.mu.Lock()
+		expired := !job.finishedAt.IsZero() && job.finishedAt.Before(cutoff)
+		if expired {
+			job.unclaimed = 0
+			job.dropResultLocked()
+		}
+		job.mu.Unlock()
+		if expired {
+			delete(jr.jobs, id)
+		}
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// idSetKey renders an ID set in a canonical order for use in job keys.
+func idSetKey(ids []int) string {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+
+	parts := make([]string, 0, len(sorted))
+	for i, id := range sorted {
+		if i > 0 && id == sorted[i-1] {
+			continue
+		}
+		parts = append(parts, fmt.Sprint(id))
+	}
+	return strings.Join(parts, ",")
+}
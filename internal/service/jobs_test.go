@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJobRunnerSubmit(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []string
+		wantJobs int
+		// wantRuns is the order keys run in, each once however often it was submitted
+		wantRuns []string
+	}{
+		{name: "single", keys: []string{"a"}, wantJobs: 1, wantRuns: []string{"a"}},
+		{name: "same key joins", keys: []string{"a", "a", "a"}, wantJobs: 1, wantRuns: []string{"a"}},
+		{name: "distinct keys queue in order", keys: []string{"a", "b", "c"}, wantJobs: 3, wantRuns: []string{"a", "b", "c"}},
+		{name: "queued key joins", keys: []string{"a", "b", "a", "b", "c"}, wantJobs: 3, wantRuns: []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jr := NewJobRunner(testLogger())
+
+			// Hold the first job until every key has been submitted
+			release := make(chan struct{})
+			var mu sync.Mutex
+			var runs []string
+			jobFor := func(key string) JobFunc {
+				return func(ctx context.Context) (interface{}, error) {
+					if key == tt.keys[0] {
+						<-release
+					}
+					mu.Lock()
+					runs = append(runs, key)
+					mu.Unlock()
+					return key, nil
+				}
+			}
+
+			jobs := make(map[string]*Job)
+			var submitted []*Job
+			for _, key := range tt.keys {
+				job := jr.Submit(key, time.Minute, jobFor(key))
+				if existing, ok := jobs[key]; ok && existing != job {
+					t.Fatalf("second Submit of %q returned job %s, want %s", key, job.ID, existing.ID)
+				}
+				jobs[key] = job
+				submitted = append(submitted, job)
+			}
+			if len(jobs) != tt.wantJobs {
+				t.Errorf("got %d jobs, want %d", len(jobs), tt.wantJobs)
+			}
+			for key, job := range jobs {
+				if key != tt.keys[0] {
+					if state := job.Status().State; state != JobQueued {
+						t.Errorf("job for %q is %s while another runs, want %s", key, state, JobQueued)
+					}
+				}
+			}
+			close(release)
+
+			// Every caller gets the result of its key's job
+			for i, job := range submitted {
+				result, err := job.Wait(context.Background())
+				if err != nil || result != tt.keys[i] {
+					t.Errorf("Wait for %q = %v, %v; want %q", tt.keys[i], result, err, tt.keys[i])
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(runs, tt.wantRuns) {
+				t.Errorf("ran %v, want %v", runs, tt.wantRuns)
+			}
+		})
+	}
+}
+
+func TestJobRunnerStartsFreshAfterFinish(t *testing.T) {
+	jr := NewJobRunner(testLogger())
+	calls := 0
+	fn := func(ctx context.Context) (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	first := jr.Submit("a", time.Minute, fn)
+	if _, err := first.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	second := jr.Submit("a", time.Minute, fn)
+	if second == first {
+		t.Fatal("a finished job was joined instead of starting a new one")
+	}
+	if result, err := second.Wait(context.Background()); err != nil || result != 2 {
+		t.Errorf("second Wait = %v, %v; want 2", result, err)
+	}
+	if _, ok := jr.Job(first.ID); !ok {
+		t.Error("finished job can no longer be looked up")
+	}
+}
+
+func TestJobWaitReleasesResult(t *testing.T) {
+	jobErr := errors.New("boom")
+
+	tests := []struct {
+		name    string
+		err     error
+		waiters int
+		// cancelled waiters give up before the job finishes
+		cancelled int
+	}{
+		{name: "single waiter", waiters: 1},
+		{name: "joined waiters", waiters: 3},
+		{name: "waiter gives up", waiters: 2, cancelled: 1},
+		{name: "failed job keeps its error", err: jobErr, waiters: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jr := NewJobRunner(testLogger())
+			release := make(chan struct{})
+			fn := func(ctx context.Context) (interface{}, error) {
+				<-release
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return "result", nil
+			}
+
+			var job *Job
+			for i := 0; i < tt.waiters; i++ {
+				job = jr.Submit("key", time.Minute, fn)
+			}
+			for i := 0; i < tt.cancelled; i++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				if _, err := job.Wait(ctx); !errors.Is(err, context.Canceled) {
+					t.Fatalf("cancelled Wait error = %v, want %v", err, context.Canceled)
+				}
+			}
+			close(release)
+
+			for i := tt.cancelled; i < tt.waiters; i++ {
+				result, err := job.Wait(context.Background())
+				if tt.err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Errorf("Wait error = %v, want %v", err, tt.err)
+					}
+					continue
+				}
+				if err != nil || result != "result" {
+					t.Errorf("waiter %d got %v, %v; want the result", i, result, err)
+				}
+			}
+
+			job.mu.Lock()
+			result := job.result
+			job.mu.Unlock()
+			if result != nil {
+				t.Errorf("result still held after every waiter collected it: %v", result)
+			}
+
+			// A caller who was never given the job cannot collect a released result
+			_, err := job.Wait(context.Background())
+			switch {
+			case tt.err != nil && !errors.Is(err, tt.err):
+				t.Errorf("late Wait error = %v, want %v", err, tt.err)
+			case tt.err == nil && !errors.Is(err, errJobResultReleased):
+				t.Errorf("late Wait error = %v, want %v", err, errJobResultReleased)
+			}
+		})
+	}
+}
+
+func TestJobDetachAndClaim(t *testing.T) {
+	tests := []struct {
+		name string
+		// detached callers hand their hold over before the job finishes
+		detached int
+		// claims are made after the job finishes, each with its key
+		claims    []string
+		wantClaim []bool
+		// wantKept is whether the result is still held once every claimer has collected it
+		wantKept bool
+	}{
+		{name: "claimed after finishing", detached: 1, claims: []string{"key"}, wantClaim: []bool{true}},
+		{name: "unclaimed result kept", detached: 1, wantKept: true},
+		{name: "claimed once", detached: 1, claims: []string{"key", "key"}, wantClaim: []bool{true, false}},
+		{name: "claim for another key", detached: 1, claims: []string{"other"}, wantClaim: []bool{false}, wantKept: true},
+		{name: "nothing detached", claims: []string{"key"}, wantClaim: []bool{false}},
+		{name: "one of two claimed", detached: 2, claims: []string{"key"}, wantClaim: []bool{true}, wantKept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jr := NewJobRunner(testLogger())
+			release := make(chan struct{})
+			fn := func(ctx context.Context) (interface{}, error) {
+				<-release
+				return "result", nil
+			}
+
+			var job *Job
+			for i := 0; i < max(tt.detached, 1); i++ {
+				job = jr.Submit("key", time.Minute, fn)
+			}
+			for i := 0; i < tt.detached; i++ {
+				job.Detach()
+			}
+			if tt.detached == 0 {
+				defer job.Wait(context.Background())
+			}
+			close(release)
+			if !job.WaitDone(context.Background()) {
+				t.Fatal("WaitDone returned before the job finished")
+			}
+
+			for i, key := range tt.claims {
+				claimed, ok := jr.Claim(job.ID, key)
+				if ok != tt.wantClaim[i] {
+					t.Fatalf("claim %d for %q = %v, want %v", i+1, key, ok, tt.wantClaim[i])
+				}
+				if !ok {
+					continue
+				}
+				if result, err := claimed.Wait(context.Background()); err != nil || result != "result" {
+					t.Errorf("claimed Wait = %v, %v; want the result", result, err)
+				}
+			}
+
+			job.mu.Lock()
+			kept := job.result != nil
+			job.mu.Unlock()
+			if tt.detached > 0 && kept != tt.wantKept {
+				t.Errorf("result kept = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestJobRunnerPrunesUnclaimedResults(t *testing.T) {
+	jr := NewJobRunner(testLogger())
+	job := jr.Submit("key", time.Minute, func(ctx context.Context) (interface{}, error) {
+		return "result", nil
+	})
+	job.WaitDone(context.Background())
+	job.Detach()
+
+	// Age the job past retention and prune
+	job.mu.Lock()
+	job.finishedAt = time.Now().Add(-jobRetention - time.Minute)
+	job.mu.Unlock()
+	jr.mu.Lock()
+	jr.pruneLocked()
+	jr.mu.Unlock()
+
+	if _, ok := jr.Claim(job.ID, "key"); ok {
+		t.Error("a pruned job could still be claimed")
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.result != nil {
+		t.Errorf("pruned job still holds its result: %v", job.result)
+	}
+}
+
+func TestJobRunnerStop(t *testing.T) {
+	jr := NewJobRunner(testLogger())
+	started := make(chan struct{})
+	running := jr.Submit("running", time.Hour, func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	queued := jr.Submit("queued", time.Hour, func(ctx context.Context) (interface{}, error) {
+		t.Error("a queued job ran after Stop")
+		return nil, nil
+	})
+	<-started
+
+	jr.Stop()
+
+	for name, job := range map[string]*Job{"running": running, "queued": queued} {
+		if _, err := job.Wait(context.Background()); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s job error = %v, want %v", name, err, context.Canceled)
+		}
+	}
+	late := jr.Submit("late", time.Hour, func(ctx context.Context) (interface{}, error) {
+		t.Error("a job submitted after Stop ran")
+		return nil, nil
+	})
+	if _, err := late.Wait(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("late job error = %v, want %v", err, context.Canceled)
+	}
+	// Stopping again returns straight away
+	jr.Stop()
+}
@@ -5,38 +5,54 @@ package service
 import (
 	"context"
 	"fmt"
-	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
-	"github.com/guarzo/zkillanalytics/internal/api/zkill"
 	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
 	"github.com/guarzo/zkillanalytics/internal/persist"
 )
 
+// maxZkillPages bounds how many pages are read for a single entity feed.
+const maxZkillPages = 100
+
+// PageProgress describes a zKillboard page once its killmails have been hydrated.
+type PageProgress struct {
+	Year       int
+	Month      int
+	APIType    string // "kills" or "losses"
+	EntityType string
+	EntityID   int
+	Page       int
+	HydrationResult
+}
+
 // KillMailService handles killmail-related operations.
 type KillMailService struct {
-	ZKillClient *zkill.ZkillClient
-	EsiService  *EsiService
-	Cache       *persist.Cache
-	Logger      *logrus.Logger
+	Source   KillmailSource
+	Hydrator *KillMailHydrator
+	Cache    *persist.Cache
+	Logger   *logrus.Logger
 
-	// Mutex to protect killMailIDs map if accessed concurrently
-	killMailMu sync.Mutex
+	// OnPageProgress, if set, is called after each page has been hydrated.
+	OnPageProgress func(PageProgress)
 }
 
-// NewKillMailService creates a new instance of KillMailService.
-func NewKillMailService(zkillClient *zkill.ZkillClient, esiService *EsiService, cache *persist.Cache, logger *logrus.Logger) *KillMailService {
+// NewKillMailService creates a new instance of KillMailService reading from source.
+// Up to concurrency killmails are hydrated at once.
+func NewKillMailService(source KillmailSource, cache *persist.Cache, logger *logrus.Logger, concurrency int) *KillMailService {
 	return &KillMailService{
-		ZKillClient: zkillClient,
-		EsiService:  esiService,
-		Cache:       cache,
-		Logger:      logger,
+		Source:   source,
+		Hydrator: NewKillMailHydrator(concurrency, source.GetEsiKillMail, logger),
+		Cache:    cache,
+		Logger:   logger,
 	}
 }
 
-func (km *KillMailService) GetKillMailDataForMonth(ctx context.Context, params *model.Params, month int) (*model.KillMailData, error) {
+// GetKillMailDataForMonth fetches and hydrates every killmail for the tracked entities in a single month.
+// A page that fails to load is returned as an error rather than leaving the month incomplete.
+func (km *KillMailService) GetKillMailDataForMonth(ctx context.Context, params *model.Params, year, month int) (*model.KillMailData, error) {
 	aggregatedMonthData := &model.KillMailData{
 		KillMails: []model.DetailedKillMail{},
 	}
@@ -47,29 +63,30 @@ func (km *KillMailService) GetKillMailDataForMonth(ctx context.Context, params *
 		config.EntityTypeCharacter:   params.Characters,
 	}
 
-	km.Logger.Infof("Starting data fetch for %04d-%02d", params.Year, month)
-	const maxPages = 100
+	km.Logger.Infof("Starting data fetch for %04d-%02d", year, month)
 	processedKillMails := 0
 
 	for entityType, entityIDs := range entityGroups {
 		for _, entityID := range entityIDs {
 			page := 1
-			for page <= maxPages {
-				killMails, err := km.ZKillClient.GetKillsPageData(ctx, entityType, entityID, page, params.Year, month)
+			for page <= maxZkillPages {
+				killMails, err := km.Source.GetKillMailsPage(ctx, "kills", entityType, entityID, page, year, month, false)
 				if err != nil {
 					km.Logger.Errorf("Error fetching kills for %s ID %d page %d: %v", entityType, entityID, page, err)
-					break
+					return nil, fmt.Errorf("failed to fetch kills for %s %d page %d of %04d-%02d: %w", entityType, entityID, page, year, month, err)
 				}
 				if len(killMails) == 0 {
-					km.Logger.Infof("No more kills found for %s ID %d in %04d-%02d after page %d", entityType, entityID, params.Year, month, page)
+					km.Logger.Infof("No more kills found for %s ID %d in %04d-%02d after page %d", entityType, entityID, year, month, page)
 					break
 				}
-				km.Logger.Infof("Fetched %d killmails for %s ID %d on page %d in %04d-%02d", len(killMails), entityType, entityID, page, params.Year, month)
+				km.Logger.Infof("Fetched %d killmails for %s ID %d on page %d in %04d-%02d", len(killMails), entityType, entityID, page, year, month)
 
-				err = km.processKillMails(ctx, killMails, killMailIDs, aggregatedMonthData)
+				err = km.processKillMails(ctx, killMails, killMailIDs, aggregatedMonthData, PageProgress{
+					Year: year, Month: month, APIType: "kills", EntityType: entityType, EntityID: entityID, Page: page,
+				})
 				if err != nil {
 					km.Logger.Errorf("Error processing kills for %s ID %d page %d: %v", entityType, entityID, page, err)
-					break
+					return nil, err
 				}
 				page++
 				processedKillMails += len(killMails)
@@ -77,22 +94,24 @@ func (km *KillMailService) GetKillMailDataForMonth(ctx context.Context, params *
 
 			// Fetch losses
 			page = 1
-			for page <= maxPages {
-				lossKillMails, err := km.ZKillClient.GetLossPageData(ctx, entityType, entityID, page, params.Year, month)
+			for page <= maxZkillPages {
+				lossKillMails, err := km.Source.GetKillMailsPage(ctx, "losses", entityType, entityID, page, year, month, false)
 				if err != nil {
 					km.Logger.Errorf("Error fetching losses for %s ID %d page %d: %v", entityType, entityID, page, err)
-					break
+					return nil, fmt.Errorf("failed to fetch losses for %s %d page %d of %04d-%02d: %w", entityType, entityID, page, year, month, err)
 				}
 				if len(lossKillMails) == 0 {
-					km.Logger.Infof("No more losses found for %s ID %d in %04d-%02d after page %d", entityType, entityID, params.Year, month, page)
+					km.Logger.Infof("No more losses found for %s ID %d in %04d-%02d after page %d", entityType, entityID, year, month, page)
 					break
 				}
-				km.Logger.Infof("Fetched %d losses for %s ID %d on page %d in %04d-%02d", len(lossKillMails), entityType, entityID, page, params.Year, month)
+				km.Logger.Infof("Fetched %d losses for %s ID %d on page %d in %04d-%02d", len(lossKillMails), entityType, entityID, page, year, month)
 
-				err = km.processKillMails(ctx, lossKillMails, killMailIDs, aggregatedMonthData)
+				err = km.processKillMails(ctx, lossKillMails, killMailIDs, aggregatedMonthData, PageProgress{
+					Year: year, Month: month, APIType: "losses", EntityType: entityType, EntityID: entityID, Page: page,
+				})
 				if err != nil {
 					km.Logger.Errorf("Error processing losses for %s ID %d page %d: %v", entityType, entityID, page, err)
-					break
+					return nil, err
 				}
 				page++
 				processedKillMails += len(lossKillMails)
@@ -100,35 +119,209 @@ func (km *KillMailService) GetKillMailDataForMonth(ctx context.Context, params *
 		}
 	}
 
-	km.Logger.Infof("Completed data aggregation for %04d-%02d with %d total killmails", params.Year, month, processedKillMails)
+	km.Logger.Infof("Completed data aggregation for %04d-%02d with %d total killmails", year, month, processedKillMails)
 	return aggregatedMonthData, nil
 }
 
-// processKillMails processes a slice of KillMail and updates aggregated data.
-func (km *KillMailService) processKillMails(ctx context.Context, killMails []model.KillMail, killMailIDs map[int]bool, aggregatedData *model.KillMailData) error {
-	for index, mail := range killMails {
-		// Check if the killmail ID is already processed.
-		if _, exists := killMailIDs[int(mail.KillMailID)]; exists {
-			km.Logger.Debugf("Killmail ID %d already processed. Skipping.", mail.KillMailID)
-			continue
+// GetNewKillMailsForMonth fetches only killmails newer than the recorded cursors for each tracked entity.
+// zKillboard pages are ordered newest first, so paging stops at the first killmail at or below the cursor.
+// Killmail IDs in known are skipped and the map is updated with every killmail added. A page that fails to
+// load is returned as an error, since recording cursors past it would skip its killmails for good.
+func (km *KillMailService) GetNewKillMailsForMonth(ctx context.Context, params *model.Params, year, month int, known map[int]bool, cursors *model.MonthCursors) (*model.KillMailData, error) {
+	newMonthData := &model.KillMailData{
+		KillMails: []model.DetailedKillMail{},
+	}
+	entityGroups := map[string][]int{
+		config.EntityTypeCorporation: params.Corporations,
+		config.EntityTypeAlliance:    params.Alliances,
+		config.EntityTypeCharacter:   params.Characters,
+	}
+	apiTypes := []string{"kills", "losses"}
+
+	km.Logger.Infof("Starting incremental fetch for %04d-%02d", year, month)
+
+	for entityType, entityIDs := range entityGroups {
+		for _, entityID := range entityIDs {
+			for _, apiType := range apiTypes {
+				cursor := cursors.Cursors[CursorKey(apiType, entityType, entityID)]
+				for page := 1; page <= maxZkillPages; page++ {
+					if err := ctx.Err(); err != nil {
+						return nil, err
+					}
+
+					killMails, err := km.Source.GetKillMailsPage(ctx, apiType, entityType, entityID, page, year, month, true)
+					if err != nil {
+						km.Logger.Errorf("Error fetching %s for %s ID %d page %d: %v", apiType, entityType, entityID, page, err)
+						return nil, fmt.Errorf("failed to fetch %s for %s %d page %d of %04d-%02d: %w", apiType, entityType, entityID, page, year, month, err)
+					}
+					if len(killMails) == 0 {
+						break
+					}
+
+					newer, reachedCursor := newerThanCursor(killMails, cursor.LastKillMailID)
+					if len(newer) > 0 {
+						km.Logger.Infof("Found %d new %s for %s ID %d on page %d in %04d-%02d", len(newer), apiType, entityType, entityID, page, year, month)
+						err = km.processKillMails(ctx, newer, known, newMonthData, PageProgress{
+							Year: year, Month: month, APIType: apiType, EntityType: entityType, EntityID: entityID, Page: page,
+						})
+						if err != nil {
+							km.Logger.Errorf("Error processing %s for %s ID %d page %d: %v", apiType, entityType, entityID, page, err)
+							return nil, err
+						}
+					}
+					if reachedCursor {
+						break
+					}
+				}
+			}
+		}
+	}
+
+	km.Logger.Infof("Completed incremental fetch for %04d-%02d with %d new killmails", year, month, len(newMonthData.KillMails))
+	return newMonthData, nil
+}
+
+// newerThanCursor returns the killmails above the cursor and whether the cursor was reached on this page.
+// A zero cursor means nothing has been recorded yet, so every killmail is new.
+func newerThanCursor(killMails []model.KillMail, cursorID int64) ([]model.KillMail, bool) {
+	if cursorID == 0 {
+		return killMails, false
+	}
+	var newer []model.KillMail
+	reached := false
+	for _, mail := range killMails {
+		if mail.KillMailID > cursorID {
+			newer = append(newer, mail)
+		} else {
+			reached = true
+		}
+	}
+	return newer, reached
+}
+
+// CursorKey builds the MonthCursors key for an entity feed.
+func CursorKey(apiType, entityType string, entityID int) string {
+	return fmt.Sprintf("%s:%s:%d", apiType, entityType, entityID)
+}
+
+// MonthCursorBuilder accumulates the newest killmail per tracked entity feed one killmail at a time,
+// so cursors can be derived while streaming a month file.
+// Kills are matched on attackers and losses on the victim, mirroring zKillboard's entity feeds.
+type MonthCursorBuilder struct {
+	params  *model.Params
+	cursors *model.MonthCursors
+}
+
+// NewMonthCursorBuilder creates a MonthCursorBuilder for the tracked entities in params.
+func NewMonthCursorBuilder(params *model.Params) *MonthCursorBuilder {
+	return &MonthCursorBuilder{
+		params: params,
+		cursors: &model.MonthCursors{
+			RefreshedAt: time.Now(),
+			Cursors:     make(map[string]model.FetchCursor),
+		},
+	}
+}
+
+// Add records a killmail against every tracked feed it belongs to.
+func (b *MonthCursorBuilder) Add(mail model.DetailedKillMail) {
+	for _, id := range b.params.Corporations {
+		if mail.Victim.CorporationID == id {
+			b.update(CursorKey("losses", config.EntityTypeCorporation, id), mail)
+		}
+		for _, attacker := range mail.Attackers {
+			if attacker.CorporationID == id {
+				b.update(CursorKey("kills", config.EntityTypeCorporation, id), mail)
+				break
+			}
 		}
+	}
+	for _, id := range b.params.Alliances {
+		if mail.Victim.AllianceID == id {
+			b.update(CursorKey("losses", config.EntityTypeAlliance, id), mail)
+		}
+		for _, attacker := range mail.Attackers {
+			if attacker.AllianceID == id {
+				b.update(CursorKey("kills", config.EntityTypeAlliance, id), mail)
+				break
+			}
+		}
+	}
+	for _, id := range b.params.Characters {
+		if mail.Victim.CharacterID == id {
+			b.update(CursorKey("losses", config.EntityTypeCharacter, id), mail)
+		}
+		for _, attacker := range mail.Attackers {
+			if attacker.CharacterID == id {
+				b.update(CursorKey("kills", config.EntityTypeCharacter, id), mail)
+				break
+			}
+		}
+	}
+}
 
-		// Process the full killmail and update aggregated data.
-		err := km.AddEsiKillMail(ctx, mail, aggregatedData)
-		if err != nil {
-			km.Logger.Errorf("Error processing kill mail ID %d: %v", mail.KillMailID, err)
-			continue
+func (b *MonthCursorBuilder) update(key string, mail model.DetailedKillMail) {
+	if current, ok := b.cursors.Cursors[key]; !ok || mail.KillMail.KillMailID > current.LastKillMailID {
+		b.cursors.Cursors[key] = model.FetchCursor{
+			LastKillMailID:   mail.KillMail.KillMailID,
+			LastKillMailTime: mail.KillMailTime,
 		}
+	}
+}
+
+// Cursors returns the cursors accumulated so far.
+func (b *MonthCursorBuilder) Cursors() *model.MonthCursors {
+	return b.cursors
+}
+
+// DeriveMonthCursors computes the newest killmail per tracked entity feed from a month of killmails.
+func DeriveMonthCursors(killMails []model.DetailedKillMail, params *model.Params) *model.MonthCursors {
+	builder := NewMonthCursorBuilder(params)
+	for _, mail := range killMails {
+		builder.Add(mail)
+	}
+	return builder.Cursors()
+}
 
-		// Mark the killmail ID as processed.
-		killMailIDs[int(mail.KillMailID)] = true
+// DeriveMonthCursorsFromFile computes a month's cursors by streaming its store file.
+func DeriveMonthCursorsFromFile(fileName string, params *model.Params) (*model.MonthCursors, error) {
+	builder := NewMonthCursorBuilder(params)
+	err := persist.EachKillMail(fileName, func(mail model.DetailedKillMail) error {
+		builder.Add(mail)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return builder.Cursors(), nil
+}
 
-		// Optional: Log progress at intervals
-		if (index+1)%100 == 0 {
-			km.Logger.Infof("Processed %d killmails out of %d", index+1, len(killMails))
+// processKillMails hydrates a page of killmails through the worker pool and appends them to the aggregated data
+// in page order. Killmails already in killMailIDs are skipped; only cancellation of ctx is returned as an error.
+// Killmails that fail to hydrate are left out and the lowest of them is recorded against the page's feed.
+func (km *KillMailService) processKillMails(ctx context.Context, killMails []model.KillMail, killMailIDs map[int]bool, aggregatedData *model.KillMailData, progress PageProgress) error {
+	hydrated, result, err := km.Hydrator.Hydrate(ctx, killMails, killMailIDs)
+	if err != nil {
+		return err
+	}
+	aggregatedData.KillMails = append(aggregatedData.KillMails, hydrated...)
+	for _, id := range result.FailedIDs {
+		key := CursorKey(progress.APIType, progress.EntityType, progress.EntityID)
+		if aggregatedData.Unhydrated == nil {
+			aggregatedData.Unhydrated = make(map[string]int64)
+		}
+		if lowest, ok := aggregatedData.Unhydrated[key]; !ok || id < lowest {
+			aggregatedData.Unhydrated[key] = id
 		}
 	}
-	km.Logger.Debugf("Processed %d killmails", len(killMails))
+
+	progress.HydrationResult = result
+	km.Logger.Debugf("Processed page %d for %s ID %d: %d hydrated, %d skipped, %d failed",
+		progress.Page, progress.EntityType, progress.EntityID, result.Hydrated, result.Skipped, result.Failed)
+	if km.OnPageProgress != nil {
+		km.OnPageProgress(progress)
+	}
+	ProgressFrom(ctx).Page(progress)
 
 	return nil
 }
@@ -146,7 +339,7 @@ func (km *KillMailService) AggregateKillMailDumps(base, addition []model.Detaile
 }
 
 func (km *KillMailService) AddEsiKillMail(ctx context.Context, mail model.KillMail, aggregatedData *model.KillMailData) error {
-	fullKillMail, err := km.EsiService.EsiClient.GetEsiKillMail(ctx, int(mail.KillMailID), mail.ZKB.Hash)
+	fullKillMail, err := km.Source.GetEsiKillMail(ctx, int(mail.KillMailID), mail.ZKB.Hash)
 	if err != nil {
 		return fmt.Errorf("failed to fetch full killmail for ID %d: %s", mail.KillMailID, err)
 	}
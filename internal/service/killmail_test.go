@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// fakeSource serves fixed zKillboard pages, newest first, and records which pages were asked for.
+type fakeSource struct {
+	mu sync.Mutex
+	// pages maps a feed's CursorKey to its pages of killmail IDs
+	pages map[string][][]int64
+	// failPage maps a feed's CursorKey to a page that fails to load
+	failPage map[string]int
+	// attackerCorp, if set, is the corporation of the attacker on every hydrated killmail
+	attackerCorp int
+	// failHydration holds killmails whose next ESI fetch fails; each fails once
+	failHydration map[int]bool
+	// requested maps each feed to the pages asked for, in order
+	requested map[string][]int
+}
+
+func (fs *fakeSource) GetKillMailsPage(ctx context.Context, apiType, entityType string, entityID, page, year, month int, fresh bool) ([]model.KillMail, error) {
+	key := CursorKey(apiType, entityType, entityID)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.requested == nil {
+		fs.requested = make(map[string][]int)
+	}
+	fs.requested[key] = append(fs.requested[key], page)
+
+	if fs.failPage[key] == page {
+		return nil, errors.New("zKillboard is down")
+	}
+	pages := fs.pages[key]
+	if page > len(pages) {
+		return nil, nil
+	}
+	killMails := make([]model.KillMail, len(pages[page-1]))
+	for i, id := range pages[page-1] {
+		killMails[i] = model.KillMail{KillMailID: id, ZKB: model.ZKB{Hash: fmt.Sprintf("hash%d", id)}}
+	}
+	return killMails, nil
+}
+
+func (fs *fakeSource) GetEsiKillMail(ctx context.Context, killMailID int, hash string) (*model.EsiKillMail, error) {
+	fs.mu.Lock()
+	fail := fs.failHydration[killMailID]
+	delete(fs.failHydration, killMailID)
+	fs.mu.Unlock()
+	if fail {
+		return nil, errors.New("ESI is down")
+	}
+
+	esiKillMail := &model.EsiKillMail{KillMailID: killMailID}
+	if fs.attackerCorp != 0 {
+		esiKillMail.Attackers = []model.Attacker{{CorporationID: fs.attackerCorp}}
+	}
+	return esiKillMail, nil
+}
+
+func TestNewerThanCursor(t *testing.T) {
+	page := []int64{50, 40, 30, 20}
+
+	tests := []struct {
+		name        string
+		cursor      int64
+		wantIDs     []int64
+		wantReached bool
+	}{
+		{name: "no cursor", cursor: 0, wantIDs: []int64{50, 40, 30, 20}},
+		{name: "cursor below the page", cursor: 10, wantIDs: []int64{50, 40, 30, 20}},
+		{name: "cursor on a killmail", cursor: 30, wantIDs: []int64{50, 40}, wantReached: true},
+		{name: "cursor between killmails", cursor: 35, wantIDs: []int64{50, 40}, wantReached: true},
+		{name: "cursor at the newest", cursor: 50, wantReached: true},
+		{name: "cursor above the page", cursor: 60, wantReached: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			killMails := make([]model.KillMail, len(page))
+			for i, id := range page {
+				killMails[i] = model.KillMail{KillMailID: id}
+			}
+			newer, reached := newerThanCursor(killMails, tt.cursor)
+			var gotIDs []int64
+			for _, mail := range newer {
+				gotIDs = append(gotIDs, mail.KillMailID)
+			}
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) || reached != tt.wantReached {
+				t.Errorf("newerThanCursor(%d) = %v, %v; want %v, %v", tt.cursor, gotIDs, reached, tt.wantIDs, tt.wantReached)
+			}
+		})
+	}
+}
+
+func TestGetNewKillMailsForMonth(t *testing.T) {
+	const corpID = 98000001
+	kills := CursorKey("kills", config.EntityTypeCorporation, corpID)
+	losses := CursorKey("losses", config.EntityTypeCorporation, corpID)
+
+	tests := []struct {
+		name     string
+		pages    map[string][][]int64
+		failPage map[string]int
+		cursors  map[string]int64
+		known    []int
+		wantIDs  []int
+		// wantPages lists the pages read per feed, so paging is seen to stop at the cursor
+		wantPages map[string][]int
+		wantErr   bool
+	}{
+		{
+			name:      "no cursor reads every page",
+			pages:     map[string][][]int64{kills: {{90, 80}, {70, 60}}, losses: {{85}}},
+			wantIDs:   []int{60, 70, 80, 85, 90},
+			wantPages: map[string][]int{kills: {1, 2, 3}, losses: {1, 2}},
+		},
+		{
+			name:      "stops on the page holding the cursor",
+			pages:     map[string][][]int64{kills: {{90, 80}, {70, 60}, {50, 40}}, losses: {{85, 75}}},
+			cursors:   map[string]int64{kills: 70, losses: 85},
+			wantIDs:   []int{80, 90},
+			wantPages: map[string][]int{kills: {1, 2}, losses: {1}},
+		},
+		{
+			name:      "nothing newer than the cursor",
+			pages:     map[string][][]int64{kills: {{90, 80}}},
+			cursors:   map[string]int64{kills: 90},
+			wantPages: map[string][]int{kills: {1}, losses: {1}},
+		},
+		{
+			name:      "known killmails are skipped",
+			pages:     map[string][][]int64{kills: {{90, 80}}, losses: {{80, 70}}},
+			known:     []int{90},
+			wantIDs:   []int{70, 80},
+			wantPages: map[string][]int{kills: {1, 2}, losses: {1, 2}},
+		},
+		{
+			name:     "a failed page fails the refresh",
+			pages:    map[string][][]int64{kills: {{90, 80}, {70}}},
+			failPage: map[string]int{kills: 2},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSource{pages: tt.pages, failPage: tt.failPage}
+			km := NewKillMailService(source, nil, testLogger(), 2)
+
+			cursors := &model.MonthCursors{Cursors: make(map[string]model.FetchCursor)}
+			for key, id := range tt.cursors {
+				cursors.Cursors[key] = model.FetchCursor{LastKillMailID: id}
+			}
+			known := make(map[int]bool)
+			for _, id := range tt.known {
+				known[id] = true
+			}
+
+			params := &model.Params{Corporations: []int{corpID}}
+			data, err := km.GetNewKillMailsForMonth(context.Background(), params, 2024, 5, known, cursors)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GetNewKillMailsForMonth succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetNewKillMailsForMonth: %v", err)
+			}
+
+			var gotIDs []int
+			for _, mail := range data.KillMails {
+				gotIDs = append(gotIDs, int(mail.KillMail.KillMailID))
+				if !known[int(mail.KillMail.KillMailID)] {
+					t.Errorf("killmail %d was not added to known", mail.KillMail.KillMailID)
+				}
+			}
+			sort.Ints(gotIDs)
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("new killmails = %v, want %v", gotIDs, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(source.requested, tt.wantPages) {
+				t.Errorf("pages read = %v, want %v", source.requested, tt.wantPages)
+			}
+		})
+	}
+}
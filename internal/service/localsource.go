@@ -0,0 +1,263 @@
+// internal/service/localsource.go
+
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/guarzo/zkillanalytics/internal/api"
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+// zkillPageSize matches the number of killmails zKillboard returns per page.
+const zkillPageSize = 200
+
+// killMailIndex serves hydrated killmails held in memory the way zKillboard serves entity feeds.
+type killMailIndex struct {
+	mu   sync.RWMutex
+	byID map[int64]model.DetailedKillMail
+}
+
+func newKillMailIndex() *killMailIndex {
+	return &killMailIndex{byID: make(map[int64]model.DetailedKillMail)}
+}
+
+func (ix *killMailIndex) add(killMails ...model.DetailedKillMail) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for _, km := range killMails {
+		if km.KillMail.KillMailID == 0 {
+			km.KillMail.KillMailID = int64(km.EsiKillMail.KillMailID)
+		}
+		ix.byID[km.KillMail.KillMailID] = km
+	}
+}
+
+// Len returns the number of killmails held.
+func (ix *killMailIndex) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.byID)
+}
+
+// GetKillMailsPage implements KillmailSource. Kills match on attackers and losses on the victim.
+func (ix *killMailIndex) GetKillMailsPage(ctx context.Context, apiType, entityType string, entityID, page, year, month int, fresh bool) ([]model.KillMail, error) {
+	if page < 1 {
+		return nil, fmt.Errorf("invalid page %d", page)
+	}
+
+	ix.mu.RLock()
+	var matches []model.KillMail
+	for _, km := range ix.byID {
+		killTime := km.KillMailTime.UTC()
+		if killTime.Year() != year || int(killTime.Month()) != month {
+			continue
+		}
+		if involvesEntity(km.EsiKillMail, apiType, entityType, entityID) {
+			matches = append(matches, km.KillMail)
+		}
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].KillMailID > matches[j].KillMailID
+	})
+
+	start := (page - 1) * zkillPageSize
+	if start >= len(matches) {
+		return []model.KillMail{}, nil
+	}
+	end := start + zkillPageSize
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return matches[start:end], nil
+}
+
+// GetEsiKillMail implements KillmailSource.
+func (ix *killMailIndex) GetEsiKillMail(ctx context.Context, killMailID int, hash string) (*model.EsiKillMail, error) {
+	ix.mu.RLock()
+	km, ok := ix.byID[int64(killMailID)]
+	ix.mu.RUnlock()
+	if !ok || (hash != "" && km.ZKB.Hash != "" && km.ZKB.Hash != hash) {
+		return nil, api.NewCustomError(http.StatusNotFound, fmt.Sprintf("killmail %d not found in source", killMailID))
+	}
+	esiKillMail := km.EsiKillMail
+	return &esiKillMail, nil
+}
+
+// involvesEntity reports whether the entity attacked ("kills") or was the victim ("losses") on killMail.
+func involvesEntity(killMail model.EsiKillMail, apiType, entityType string, entityID int) bool {
+	matches := func(characterID, corporationID, allianceID int) bool {
+		switch entityType {
+		case config.EntityTypeCharacter:
+			return characterID == entityID
+		case config.EntityTypeCorporation:
+			return corporationID == entityID
+		case config.EntityTypeAlliance:
+			return allianceID == entityID
+		}
+		return false
+	}
+
+	if apiType == "losses" {
+		return matches(killMail.Victim.CharacterID, killMail.Victim.CorporationID, killMail.Victim.AllianceID)
+	}
+	for _, attacker := range killMail.Attackers {
+		if matches(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
+			return true
+		}
+	}
+	return false
+}
+
+// LocalSource serves killmails read from killmail files, such as copies of the monthly store files,
+// so whole pipelines can run against fixtures without zKillboard or ESI.
+type LocalSource struct {
+	*killMailIndex
+}
+
+// OpenLocalSource reads every killmail file under a directory, or inside a .zip archive.
+func OpenLocalSource(location string) (*LocalSource, error) {
+	if strings.EqualFold(path.Ext(location), ".zip") {
+		archive, err := zip.OpenReader(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive %s: %w", location, err)
+		}
+		defer archive.Close()
+		return NewLocalSource(archive)
+	}
+	return NewLocalSource(os.DirFS(location))
+}
+
+// NewLocalSource reads every .json, .ndjson and .ndjson.gz file in fsys. A .json file holds
+// an object with a KillMails array or a bare array of detailed killmails; the others hold one killmail per line.
+func NewLocalSource(fsys fs.FS) (*LocalSource, error) {
+	source := &LocalSource{killMailIndex: newKillMailIndex()}
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isKillMailFile(name) {
+			return nil
+		}
+
+		killMails, err := readKillMails(fsys, name)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s: %w", name, err)
+		}
+		source.add(killMails...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+func isKillMailFile(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".json", ".ndjson", ".ndjson.gz"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func readKillMails(fsys fs.FS, name string) ([]model.DetailedKillMail, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := persist.NewKillMailReader(file, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var killMails []model.DetailedKillMail
+	for {
+		km, err := reader.Next()
+		if err == io.EOF {
+			return killMails, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		killMails = append(killMails, km)
+	}
+}
+
+// ManualSource serves killmails submitted directly, for one-off additions and tests.
+type ManualSource struct {
+	*killMailIndex
+}
+
+// NewManualSource creates an empty ManualSource.
+func NewManualSource() *ManualSource {
+	return &ManualSource{killMailIndex: newKillMailIndex()}
+}
+
+// Submit adds killmails to the source, replacing any with the same ID.
+func (ms *ManualSource) Submit(killMails ...model.DetailedKillMail) {
+	ms.add(killMails...)
+}
+
+// StaticResolver resolves entities from a fixed ESIData, such as a saved ESI data file, without calling ESI.
+type StaticResolver struct {
+	Data *model.ESIData
+}
+
+// NewStaticResolver creates a resolver answering from data.
+func NewStaticResolver(data *model.ESIData) *StaticResolver {
+	return &StaticResolver{Data: data}
+}
+
+// ResolveKillMailEntities implements EntityResolver. Entities missing from the fixed data stay unresolved.
+func (sr *StaticResolver) ResolveKillMailEntities(ctx context.Context, killMails []model.DetailedKillMail, esiData *model.ESIData) error {
+	copyEntity := func(characterID, corporationID, allianceID int) {
+		if character, ok := sr.Data.CharacterInfos[characterID]; ok {
+			esiData.CharacterInfos[characterID] = character
+		}
+		if corporation, ok := sr.Data.CorporationInfos[corporationID]; ok {
+			esiData.CorporationInfos[corporationID] = corporation
+		}
+		if alliance, ok := sr.Data.AllianceInfos[allianceID]; ok {
+			esiData.AllianceInfos[allianceID] = alliance
+		}
+	}
+
+	for _, km := range killMails {
+		copyEntity(km.Victim.CharacterID, km.Victim.CorporationID, km.Victim.AllianceID)
+		for _, attacker := range km.Attackers {
+			copyEntity(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID)
+		}
+	}
+	return nil
+}
+
+// RefreshEntities implements EntityResolver; fixed data never changes.
+func (sr *StaticResolver) RefreshEntities(ctx context.Context, esiData *model.ESIData) error {
+	return nil
+}
+
+// CorporationTicker implements EntityResolver. Corporations missing from the fixed data have no ticker.
+func (sr *StaticResolver) CorporationTicker(ctx context.Context, corporationID int) (string, error) {
+	return sr.Data.CorporationInfos[corporationID].Ticker, nil
+}
@@ -4,10 +4,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"sync"
+	"sort"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -26,77 +27,128 @@ const (
 // OrchestrateService coordinates data fetching, aggregation, and persistence.
 type OrchestrateService struct {
 	KillMailService *KillMailService
-	ESIService      *EsiService
+	Repository      persist.KillMailRepository
+	TrackedIDs      persist.TrackedIDRepository
+	Trusted         persist.TrustedRepository
+	Resolver        EntityResolver
 	InvTypeService  *data.InvTypeService
+	StaticData      *data.StaticDataService
 	Failed          *model.FailedCharacters
 	Cache           *persist.Cache
 	Logger          *logrus.Logger
 	Client          *http.Client
 
-	// Mutex to ensure only one GetAllData runs at a time
-	mu              sync.Mutex
-	mutexAcquiredAt time.Time // Tracks when the mutex was acquired
+	// Jobs runs fetches one at a time, coalescing identical requests
+	Jobs *JobRunner
+	// Backfills runs tracked entity backfills, which can take hours, apart from Jobs so they never hold up dashboards
+	Backfills *JobRunner
+
+	// Group is the tracked group the service fetches for, and Store holds that group's month files
+	Group string
+	Store persist.KillMailStore
 }
 
 // NewOrchestrateService initializes and returns a new OrchestrateService instance.
 func NewOrchestrateService(
-	esiService *EsiService,
+	resolver EntityResolver,
 	killMailService *KillMailService,
+	repository persist.KillMailRepository,
+	trackedIDs persist.TrackedIDRepository,
+	trusted persist.TrustedRepository,
 	invTypeService *data.InvTypeService,
+	staticData *data.StaticDataService,
 	failed *model.FailedCharacters,
 	cache *persist.Cache,
 	logger *logrus.Logger,
 	client *http.Client,
 ) *OrchestrateService {
 	return &OrchestrateService{
-		ESIService:      esiService,
+		Resolver:        resolver,
 		KillMailService: killMailService,
+		Repository:      repository,
+		TrackedIDs:      trackedIDs,
+		Trusted:         trusted,
 		InvTypeService:  invTypeService,
+		StaticData:      staticData,
 		Failed:          failed,
 		Cache:           cache,
 		Logger:          logger,
 		Client:          client,
+		Jobs:            NewJobRunner(logger),
+		Backfills:       NewJobRunner(logger),
+		Group:           config.DefaultGroup,
+		Store:           persist.DefaultKillMailStore,
 	}
 }
 
-// GetAllData orchestrates the data fetching process based on availability and necessity.
-// It ensures that only one instance runs at a time.
-func (svc *OrchestrateService) GetAllData(ctx context.Context, corporations, alliances, characters []int, startString, endString string) (*model.ChartData, error) {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
-	defer cancel()
+// ForGroup returns a service fetching for the named tracked group into its own store, repository and tracked IDs.
+// It shares svc's killmail fetching, ESI resolution, cache and job runners.
+func (svc *OrchestrateService) ForGroup(group string, store persist.KillMailStore, repository persist.KillMailRepository, trackedIDs persist.TrackedIDRepository) *OrchestrateService {
+	groupSvc := *svc
+	groupSvc.Group = group
+	groupSvc.Store = store
+	groupSvc.Repository = repository
+	groupSvc.TrackedIDs = trackedIDs
+	return &groupSvc
+}
 
-	svc.Logger.Infof("Attempting to acquire mutex...")
-	if !svc.AcquireMutex() {
-		return nil, fmt.Errorf("another GetAllData operation is in progress")
-	}
+// tracked returns the entities currently tracked by the service's group.
+func (svc *OrchestrateService) tracked() config.TrackedEntities {
+	tracked, _ := config.Group(svc.Group)
+	return tracked
+}
 
-	// Use defer to ensure the mutex is always released, even if a panic occurs.
-	defer func() {
-		if r := recover(); r != nil {
-			svc.Logger.Errorf("Recovered from panic: %v", r)
-		}
-		svc.Logger.Infof("Releasing mutex")
-		svc.ReleaseMutex()
-	}()
+// GetAllData orchestrates the data fetching process based on availability and necessity.
+// The range covers whole days from startDate through endDate and may span any number of years.
+// It waits for the job fetching the range, joining one already in flight for the same range and entities.
+func (svc *OrchestrateService) GetAllData(ctx context.Context, corporations, alliances, characters []int, startDate, endDate time.Time) (*model.ChartData, error) {
+	if endDate.Before(startDate) {
+		return nil, fmt.Errorf("end date %s is before start date %s", endDate.Format("2006-01-02"), startDate.Format("2006-01-02"))
+	}
 
-	// Parse the start and end dates
-	startDate, err := time.Parse("2006-01-02", startString)
+	result, err := svc.SubmitGetAllData(corporations, alliances, characters, startDate, endDate).Wait(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("invalid start date format: %w", err)
+		return nil, err
 	}
+	return result.(*model.ChartData), nil
+}
 
-	endDate, err := time.Parse("2006-01-02", endString)
-	if err != nil {
-		return nil, fmt.Errorf("invalid e date format: %w", err)
+// SubmitGetAllData queues a GetAllData job for the range and entities, or returns the one already
+// queued or running for them. Callers may wait on the job or poll it by ID.
+func (svc *OrchestrateService) SubmitGetAllData(corporations, alliances, characters []int, startDate, endDate time.Time) *Job {
+	key := getAllDataKey(svc.Group, corporations, alliances, characters, startDate, endDate)
+	return svc.Jobs.Submit(key, 30*time.Minute, func(ctx context.Context) (interface{}, error) {
+		return svc.getAllData(ctx, corporations, alliances, characters, startDate, endDate)
+	})
+}
+
+// ResumeGetAllData claims the GetAllData job with the given ID, such as one a loading page was polling, if it
+// is for the same range and entities and its result was left for collection. Otherwise, including when jobID is
+// empty, it submits the job as SubmitGetAllData does.
+func (svc *OrchestrateService) ResumeGetAllData(jobID string, corporations, alliances, characters []int, startDate, endDate time.Time) *Job {
+	if jobID != "" {
+		key := getAllDataKey(svc.Group, corporations, alliances, characters, startDate, endDate)
+		if job, ok := svc.Jobs.Claim(jobID, key); ok {
+			return job
+		}
 	}
+	return svc.SubmitGetAllData(corporations, alliances, characters, startDate, endDate)
+}
+
+func getAllDataKey(group string, corporations, alliances, characters []int, startDate, endDate time.Time) string {
+	return fmt.Sprintf("data:%s:%s:%s:corporations=%s:alliances=%s:characters=%s",
+		group, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"),
+		idSetKey(corporations), idSetKey(alliances), idSetKey(characters))
+}
 
+func (svc *OrchestrateService) getAllData(ctx context.Context, corporations, alliances, characters []int, startDate, endDate time.Time) (*model.ChartData, error) {
 	svc.Logger.Infof("Fetching data from %s to %s...", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
 	fetchStart := time.Now()
-	year := startDate.Year()
 	esiRefresh := false
+	progress := ProgressFrom(ctx)
 
-	// Inside GetAllData
-	dataAvailability, err := svc.CheckDataAvailability(int(startDate.Month()), int(endDate.Month()), year)
+	progress.Stage(ProgressChecking, "Checking stored months")
+	dataAvailability, staleMonths, err := svc.CheckDataAvailability(startDate, endDate)
 	if err != nil {
 		svc.Logger.Errorf("Error checking data availability: %v", err)
 		return nil, err
@@ -137,7 +189,7 @@ func (svc *OrchestrateService) GetAllData(ctx context.Context, corporations, all
 		CharacterIDs:   characters,
 	}
 
-	fetchIDs, err := persist.LoadIdsFromFile()
+	fetchIDs, err := svc.TrackedIDs.LoadIds()
 	if err != nil || fetchIDs == nil || (fetchIDs.CorporationIDs == nil && fetchIDs.AllianceIDs == nil && fetchIDs.CharacterIDs == nil) {
 		svc.Logger.Warnf("Using new ID File: %v", err)
 		fetchIDs = &model.Ids{ // Initialize fetchIDs as a pointer to avoid nil reference issues
@@ -148,14 +200,14 @@ func (svc *OrchestrateService) GetAllData(ctx context.Context, corporations, all
 		svc.Logger.Infof("Loaded IDs from file")
 	}
 
-	idChanged, newIDs, err := persist.CheckIfIdsChanged(hardCodedIDs)
+	idChanged, newIDs, err := persist.CheckIfIdsChanged(hardCodedIDs, svc.TrackedIDs, svc.Trusted)
 	if err != nil {
 		newIDs = hardCodedIDs
 		svc.Logger.Errorf("Error checking if IDs changes")
 	}
 
 	// Create parameters for data fetching
-	params := model.NewParams(svc.Client, fetchIDs.CorporationIDs, fetchIDs.AllianceIDs, fetchIDs.CharacterIDs, year, esiData, idChanged, newIDs)
+	params := model.NewParams(svc.Client, fetchIDs.CorporationIDs, fetchIDs.AllianceIDs, fetchIDs.CharacterIDs, esiData, idChanged, newIDs)
 
 	// Update fetchIDs with new IDs if there were changes
 	if idChanged {
@@ -165,56 +217,61 @@ func (svc *OrchestrateService) GetAllData(ctx context.Context, corporations, all
 	}
 
 	// Fetch missing data if necessary
-	newData, err := svc.GetMissingData(ctx, &params, dataAvailability)
-	if err != nil {
+	if _, err = svc.GetMissingData(ctx, &params, dataAvailability); err != nil {
 		svc.Logger.Errorf("Error fetching missing data: %v", err)
 		return nil, err
 	}
 
-	yearMonths, err := generateYearMonthPairs(int(startDate.Month()), int(endDate.Month()), startDate.Year())
+	// Bring stale months up to date without refetching them
+	for i, key := range staleMonths {
+		sYear, sMonth := extractYearMonthKey(key)
+		progress.Month(ProgressRefreshing, sYear, sMonth, i+1, len(staleMonths))
+		if _, err := svc.refreshMonth(ctx, &params, sYear, sMonth); err != nil {
+			svc.Logger.Errorf("Error refreshing %04d-%02d incrementally: %v", sYear, sMonth, err)
+		}
+	}
+
+	// Every fetched month is written through to the repository, which answers the requested days directly
+	from, until := dayRange(startDate, endDate)
+	progress.Stage(ProgressLoading, "Loading stored killmails")
+	killMails, err := svc.Repository.KillMailsBetween(ctx, from, until)
 	if err != nil {
-		svc.Logger.Errorf("Error generating year-month pairs: %v", err)
+		svc.Logger.Errorf("Error querying killmails: %v", err)
 		return nil, err
 	}
 
-	for _, ym := range yearMonths {
-		y, m := ym.Year, ym.Month
-		key := getYearMonthKey(y, m)
-		if dataAvailability[key] {
-			// Load existing data from file
-			fileName := persist.GenerateZkillFileName(y, m)
-			monthlyKillMailData, err := persist.ReadKillMailsFromFile(fileName)
-			if err != nil {
-				svc.Logger.Errorf("Error loading data from file %s: %v", fileName, err)
-				continue
-			}
-			// Populate tracked characters in ESIData
-			err = svc.ESIService.LoadTrackedCharacters(ctx, monthlyKillMailData.KillMails, esiData)
-			if err != nil {
-				svc.Logger.Errorf("Error loading tracked characters into ESI data: %v", err)
-				return nil, err
-			}
-
-			// Aggregate KillMailData into NewData
-			newData.KillMails = svc.KillMailService.AggregateKillMailDumps(newData.KillMails, monthlyKillMailData.KillMails)
-		}
+	// Populate characters, corporations and alliances in ESIData in bulk
+	svc.Logger.Infof("Loading characters from %d killmails into ESIData", len(killMails))
+	progress.Stage(ProgressResolving, fmt.Sprintf("Resolving names for %d killmails", len(killMails)))
+	resolvedBefore := esiEntityCount(esiData)
+	err = svc.Resolver.ResolveKillMailEntities(ctx, killMails, esiData)
+	if err != nil {
+		svc.Logger.Errorf("Error loading tracked characters into ESI data: %v", err)
+		return nil, err
 	}
+	progress.Resolved(esiEntityCount(esiData) - resolvedBefore)
 
 	// Initialize ChartData
 	chartData := &model.ChartData{
-		KillMails: newData.KillMails,
+		KillMails: killMails,
 		ESIData:   *esiData,
+		Group:     svc.Group,
 	}
 
 	// Refresh ESI data if necessary
 	if esiRefresh {
-		err = svc.ESIService.RefreshEsiData(ctx, chartData, svc.Client)
+		progress.Stage(ProgressResolving, "Refreshing character affiliations")
+		resolvedBefore = esiEntityCount(&chartData.ESIData)
+		err = svc.Resolver.RefreshEntities(ctx, &chartData.ESIData)
 		if err != nil {
 			svc.Logger.Errorf("Error refreshing ESI data: %v", err)
 			return nil, err
 		}
+		progress.Resolved(esiEntityCount(&chartData.ESIData) - resolvedBefore)
 	}
 
+	progress.Stage(ProgressSaving, "Saving ESI data")
+
 	// Persist ESI data and IDs
 	err = persist.SaveEsiDataToFile(esiFileName, esiData)
 	if err != nil {
@@ -222,22 +279,20 @@ func (svc *OrchestrateService) GetAllData(ctx context.Context, corporations, all
 		return nil, err
 	}
 
-	err = persist.SaveIdsToFile(fetchIDs)
+	if err = svc.Repository.SaveEsiData(ctx, esiData); err != nil {
+		svc.Logger.Errorf("Error saving ESI data to repository: %v", err)
+	}
+
+	err = svc.TrackedIDs.SaveIds(fetchIDs)
 	if err != nil {
 		svc.Logger.Errorf("Error saving IDs data: %v", err)
 		return nil, err
 	}
 
-	if saveErr := persist.SaveFailedCharacters(svc.Failed); saveErr != nil {
+	if saveErr := svc.TrackedIDs.SaveFailedCharacters(svc.Failed.Copy()); saveErr != nil {
 		svc.Logger.Errorf("Error saving IDs data: %v", err)
 	}
 
-	cacheFile := persist.GenerateCacheDataFileName()
-	err = svc.ESIService.Cache.SaveToFile(cacheFile)
-	if err != nil {
-		svc.Logger.Errorf("Error saving cache: %v", err)
-	}
-
 	fetchTotalTime := time.Since(fetchStart)
 	svc.Logger.Infof("Data fetching complete in %.2f seconds", fetchTotalTime.Seconds())
 	return chartData, nil
@@ -252,22 +307,23 @@ func (svc *OrchestrateService) GetMissingData(ctx context.Context, params *model
 		KillMails: []model.DetailedKillMail{},
 	}
 
+	// Months already available are skipped unless IDs have changed, which needs a full pull of every month
+	var missing []int
 	for key, available := range dataAvailability {
-		if available && !params.ChangedIDs {
-			// Data for this month is already available and IDs haven't changed; skip fetching.
-			continue
-		}
-
-		if params.ChangedIDs {
-			// IDs have changed; perform a full data pull for this month.
-			available = false
+		if !available || params.ChangedIDs {
+			missing = append(missing, key)
 		}
+	}
+	sort.Ints(missing)
 
+	progress := ProgressFrom(ctx)
+	for i, key := range missing {
 		// Extract year and month from key
 		year, month := extractYearMonthKey(key)
+		progress.Month(ProgressFetching, year, month, i+1, len(missing))
 
 		// Fetch the data for this month
-		monthlyKillMailData, err := svc.KillMailService.GetKillMailDataForMonth(ctx, params, month)
+		monthlyKillMailData, err := svc.KillMailService.GetKillMailDataForMonth(ctx, params, year, month)
 		if err != nil {
 			svc.Logger.Errorf("Error fetching data for %04d-%02d: %v", year, month, err)
 			return nil, err
@@ -277,55 +333,254 @@ func (svc *OrchestrateService) GetMissingData(ctx context.Context, params *model
 		aggregatedData.KillMails = svc.KillMailService.AggregateKillMailDumps(aggregatedData.KillMails, monthlyKillMailData.KillMails)
 
 		// Save the aggregated data to a unique store file
-		fileName := persist.GenerateZkillFileName(year, month)
+		fileName := svc.Store.MonthFileName(year, month)
 		svc.Logger.Infof("Saving data for %04d-%02d to file %s with %d killmails", year, month, fileName, len(aggregatedData.KillMails))
 		if err = persist.SaveKillMailsToFile(fileName, monthlyKillMailData); err != nil {
 			svc.Logger.Errorf("Failed to save fetched data to file %s: %v", fileName, err)
 			return nil, fmt.Errorf("failed to save fetched data: %w", err)
 		}
+		if _, err = svc.Repository.ReplaceMonth(ctx, year, month, monthlyKillMailData.KillMails); err != nil {
+			return nil, fmt.Errorf("failed to store fetched data: %w", err)
+		}
+		svc.saveMonthCursors(params, year, month, monthlyKillMailData)
 	}
 
 	return aggregatedData, nil
 }
 
-func (svc *OrchestrateService) AcquireMutex() bool {
-	if svc.mu.TryLock() {
-		svc.mutexAcquiredAt = time.Now()
-		return true
-	} else {
-		return false
+// RefreshMonth incrementally brings a stored month up to date for the given tracked entities.
+// It runs as a job after any fetches already queued, joining a refresh of the same month in flight.
+func (svc *OrchestrateService) RefreshMonth(ctx context.Context, corporations, alliances, characters []int, year, month int) (*model.KillMailData, error) {
+	key := fmt.Sprintf("refresh:%s:%04d-%02d:corporations=%s:alliances=%s:characters=%s",
+		svc.Group, year, month, idSetKey(corporations), idSetKey(alliances), idSetKey(characters))
+
+	job := svc.Jobs.Submit(key, 30*time.Minute, func(ctx context.Context) (interface{}, error) {
+		return svc.refreshTrackedMonth(ctx, corporations, alliances, characters, year, month)
+	})
+	result, err := job.Wait(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return result.(*model.KillMailData), nil
 }
 
-func (svc *OrchestrateService) ReleaseMutex() {
-	// Calculate how long the mutex was held
-	duration := time.Since(svc.mutexAcquiredAt)
+func (svc *OrchestrateService) refreshTrackedMonth(ctx context.Context, corporations, alliances, characters []int, year, month int) (*model.KillMailData, error) {
+	// Include entities recorded by earlier fetches, such as trusted characters
+	if fetchIDs, err := svc.TrackedIDs.LoadIds(); err == nil && fetchIDs != nil {
+		corporations = unionIDs(corporations, fetchIDs.CorporationIDs)
+		alliances = unionIDs(alliances, fetchIDs.AllianceIDs)
+		characters = unionIDs(characters, fetchIDs.CharacterIDs)
+	}
 
-	// Log the duration
-	svc.Logger.Infof("Mutex was held for: %v", duration)
+	params := model.NewParams(svc.Client, corporations, alliances, characters, nil, false, nil)
+	return svc.refreshMonth(ctx, &params, year, month)
+}
 
-	// Release the mutex
-	svc.mu.Unlock()
+// refreshMonth fetches the killmails newer than the month's cursors and merges them into its store file.
+// Months without a store file are fetched in full. It returns only the newly added killmails.
+func (svc *OrchestrateService) refreshMonth(ctx context.Context, params *model.Params, year, month int) (*model.KillMailData, error) {
+	refreshStart := time.Now()
+	fileName := svc.Store.MonthFileName(year, month)
+
+	// Stream the stored month once for its killmail IDs and, if needed, its cursors
+	known := make(map[int]bool)
+	builder := NewMonthCursorBuilder(params)
+	err := persist.EachKillMail(fileName, func(km model.DetailedKillMail) error {
+		known[int(km.KillMail.KillMailID)] = true
+		builder.Add(km)
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
+		}
+		svc.Logger.Infof("No usable store file for %04d-%02d, fetching the full month", year, month)
+		monthlyKillMailData, err := svc.KillMailService.GetKillMailDataForMonth(ctx, params, year, month)
+		if err != nil {
+			return nil, err
+		}
+		if err = persist.SaveKillMailsToFile(fileName, monthlyKillMailData); err != nil {
+			return nil, fmt.Errorf("failed to save fetched data: %w", err)
+		}
+		if _, err = svc.Repository.ReplaceMonth(ctx, year, month, monthlyKillMailData.KillMails); err != nil {
+			return nil, fmt.Errorf("failed to store fetched data: %w", err)
+		}
+		svc.saveMonthCursors(params, year, month, monthlyKillMailData)
+		return monthlyKillMailData, nil
+	}
+
+	cursors, err := svc.Store.LoadMonthCursors(year, month)
+	if err != nil || cursors == nil {
+		svc.Logger.Infof("Deriving fetch cursors for %04d-%02d from stored killmails", year, month)
+		cursors = builder.Cursors()
+	}
+
+	newData, err := svc.KillMailService.GetNewKillMailsForMonth(ctx, params, year, month, known, cursors)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := persist.MergeKillMailsIntoFile(fileName, newData.KillMails)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge new killmails into %s: %w", fileName, err)
+	}
+	if _, err = svc.Repository.SaveKillMails(ctx, newData.KillMails); err != nil {
+		return nil, fmt.Errorf("failed to store new killmails: %w", err)
+	}
+
+	for _, km := range newData.KillMails {
+		builder.Add(km)
+	}
+	svc.holdCursors(year, month, builder.Cursors(), newData.Unhydrated)
+	if err := svc.Store.SaveMonthCursors(year, month, builder.Cursors()); err != nil {
+		svc.Logger.Errorf("Failed to save fetch cursors for %04d-%02d: %v", year, month, err)
+	}
+	svc.Logger.Infof("Incremental refresh of %04d-%02d added %d killmails in %.2f seconds", year, month, added, time.Since(refreshStart).Seconds())
+	return newData, nil
+}
+
+// SubmitTrackedBackfill queues a job fetching every stored month for the tracked entities persist.CheckIfIdsChanged
+// reports as never fetched, so adding an entity fills in its history without refetching anyone else's.
+// Backfills of every group share one queue, separate from the data jobs behind the dashboards.
+func (svc *OrchestrateService) SubmitTrackedBackfill() *Job {
+	tracked := svc.tracked()
+	key := fmt.Sprintf("backfill:%s:corporations=%s:alliances=%s:characters=%s",
+		svc.Group, idSetKey(tracked.Corporations), idSetKey(tracked.Alliances), idSetKey(tracked.Characters))
+
+	return svc.Backfills.Submit(key, 6*time.Hour, func(ctx context.Context) (interface{}, error) {
+		return svc.backfillNewIDs(ctx, tracked)
+	})
+}
+
+// backfillNewIDs fetches the stored months for tracked entities missing from the tracked IDs file, merging them into
+// each month's store file, then records them there. It returns the number of killmails added. The IDs are recorded
+// only once every month has been fetched in full, so a failed backfill is tried again the next time one is submitted.
+func (svc *OrchestrateService) backfillNewIDs(ctx context.Context, tracked config.TrackedEntities) (int, error) {
+	ids := &model.Ids{
+		CorporationIDs: tracked.Corporations,
+		AllianceIDs:    tracked.Alliances,
+		CharacterIDs:   tracked.Characters,
+	}
+	changed, newIDs, err := persist.CheckIfIdsChanged(ids, svc.TrackedIDs, svc.Trusted)
+	if errors.Is(err, os.ErrNotExist) {
+		// Nothing has been fetched yet, and GetAllData pulls every month in full for everyone tracked
+		svc.Logger.Info("No tracked IDs file yet, nothing to backfill")
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to check tracked IDs: %w", err)
+	}
+	if !changed {
+		svc.Logger.Info("No new tracked entities to backfill")
+		return 0, nil
+	}
+
+	months, err := svc.Store.StoredMonths()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list stored months: %w", err)
+	}
+	svc.Logger.Infof("Backfilling %d months for %d new corporations, %d new alliances and %d new characters",
+		len(months), len(newIDs.CorporationIDs), len(newIDs.AllianceIDs), len(newIDs.CharacterIDs))
+
+	fetchIDs, err := svc.TrackedIDs.LoadIds()
+	if err != nil || fetchIDs == nil {
+		fetchIDs = &model.Ids{}
+	}
+	fetchIDs.CorporationIDs = unionIDs(fetchIDs.CorporationIDs, newIDs.CorporationIDs)
+	fetchIDs.AllianceIDs = unionIDs(fetchIDs.AllianceIDs, newIDs.AllianceIDs)
+	fetchIDs.CharacterIDs = unionIDs(fetchIDs.CharacterIDs, newIDs.CharacterIDs)
+
+	newParams := model.NewParams(svc.Client, newIDs.CorporationIDs, newIDs.AllianceIDs, newIDs.CharacterIDs, nil, false, nil)
+	allParams := model.NewParams(svc.Client, fetchIDs.CorporationIDs, fetchIDs.AllianceIDs, fetchIDs.CharacterIDs, nil, false, nil)
+
+	progress := ProgressFrom(ctx)
+	added := 0
+	for i, first := range months {
+		year, month := first.Year(), int(first.Month())
+		progress.Month(ProgressFetching, year, month, i+1, len(months))
+
+		if _, _, err := svc.Store.StatMonthFile(year, month); errors.Is(err, persist.ErrKillMailIDMissing) {
+			// The month is fetched in full for everyone tracked the next time it is requested
+			svc.Logger.Infof("Skipping %04d-%02d, which predates stored killmail IDs", year, month)
+			continue
+		}
+
+		monthlyKillMailData, err := svc.KillMailService.GetKillMailDataForMonth(ctx, &newParams, year, month)
+		if err != nil {
+			return added, fmt.Errorf("failed to fetch %04d-%02d: %w", year, month, err)
+		}
+
+		fileName := svc.Store.MonthFileName(year, month)
+		monthAdded, err := persist.MergeKillMailsIntoFile(fileName, monthlyKillMailData.KillMails)
+		if err != nil {
+			return added, fmt.Errorf("failed to merge backfilled killmails into %s: %w", fileName, err)
+		}
+		if _, err = svc.Repository.SaveKillMails(ctx, monthlyKillMailData.KillMails); err != nil {
+			return added, fmt.Errorf("failed to store backfilled killmails: %w", err)
+		}
+		added += monthAdded
+
+		// Cursors now cover the new entities' feeds alongside the existing ones
+		cursors, err := DeriveMonthCursorsFromFile(fileName, &allParams)
+		if err != nil {
+			svc.Logger.Errorf("Failed to derive fetch cursors for %04d-%02d: %v", year, month, err)
+		} else if err := svc.Store.SaveMonthCursors(year, month, cursors); err != nil {
+			svc.Logger.Errorf("Failed to save fetch cursors for %04d-%02d: %v", year, month, err)
+		}
+		svc.Logger.Infof("Backfilled %d killmails for new tracked entities into %04d-%02d", monthAdded, year, month)
+	}
+
+	if err := svc.TrackedIDs.SaveIds(fetchIDs); err != nil {
+		return added, fmt.Errorf("failed to save tracked IDs: %w", err)
+	}
+	return added, nil
+}
+
+// esiEntityCount is the number of characters, corporations and alliances esiData holds.
+func esiEntityCount(esiData *model.ESIData) int {
+	return len(esiData.CharacterInfos) + len(esiData.CorporationInfos) + len(esiData.AllianceInfos)
 }
 
-// GetTrackedCorporations returns the list of tracked corporation IDs.
+// saveMonthCursors records the newest killmail per tracked entity feed for a month, held below any
+// killmail that failed to hydrate.
+func (svc *OrchestrateService) saveMonthCursors(params *model.Params, year, month int, data *model.KillMailData) {
+	cursors := DeriveMonthCursors(data.KillMails, params)
+	svc.holdCursors(year, month, cursors, data.Unhydrated)
+	if err := svc.Store.SaveMonthCursors(year, month, cursors); err != nil {
+		svc.Logger.Errorf("Failed to save fetch cursors for %04d-%02d: %v", year, month, err)
+	}
+}
+
+// holdCursors keeps each feed's cursor below the lowest killmail on it that failed to hydrate, so the
+// next refresh pages back down to it. Killmails between the two are skipped again as already stored.
+func (svc *OrchestrateService) holdCursors(year, month int, cursors *model.MonthCursors, unhydrated map[string]int64) {
+	for key, id := range unhydrated {
+		if cursor, ok := cursors.Cursors[key]; !ok || cursor.LastKillMailID >= id {
+			svc.Logger.Warnf("Holding the %s cursor for %04d-%02d below killmail %d, which failed to hydrate", key, year, month, id)
+			cursors.Cursors[key] = model.FetchCursor{LastKillMailID: id - 1}
+		}
+	}
+}
+
+// GetTrackedCorporations returns the list of corporation IDs tracked by the service's group.
 func (svc *OrchestrateService) GetTrackedCorporations() []int {
-	return config.CorporationIDs
+	return svc.tracked().Corporations
 }
 
-// GetTrackedAlliances returns the list of tracked alliance IDs.
+// GetTrackedAlliances returns the list of alliance IDs tracked by the service's group.
 func (svc *OrchestrateService) GetTrackedAlliances() []int {
-	return config.AllianceIDs
+	return svc.tracked().Alliances
 }
 
-// GetTrackedCharacters returns the list of tracked character IDs.
+// GetTrackedCharacters returns the list of character IDs tracked by the service's group.
 func (svc *OrchestrateService) GetTrackedCharacters() []int {
-	return config.CharacterIDs
+	return svc.tracked().Characters
 }
 
 // GetTrackedCharactersFromKillMails extracts tracked character IDs from killmails and ESI data.
 func (svc *OrchestrateService) GetTrackedCharactersFromKillMails(fullKillMail []model.DetailedKillMail, esiData *model.ESIData) []int {
 	var trackedCharacters []int
+	tracked := svc.tracked()
 
 	svc.Logger.Debugf("tracked characters, killmail length: %d", len(fullKillMail))
 
@@ -351,7 +606,7 @@ func (svc *OrchestrateService) GetTrackedCharactersFromKillMails(fullKillMail []
 			allianceID := corpInfo.AllianceID
 
 			// Check DisplayCharacter
-			if config.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, allianceID) {
+			if tracked.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, allianceID) {
 				trackedCharacters = append(trackedCharacters, attacker.CharacterID)
 			}
 		}
@@ -365,35 +620,63 @@ func (svc *OrchestrateService) LookupType(id int) string {
 	return svc.InvTypeService.QueryInvType(id)
 }
 
+// LookupCorporationTicker returns a corporation's ticker, or an empty string if it cannot be looked up.
+func (svc *OrchestrateService) LookupCorporationTicker(corporationID int) string {
+	ticker, err := svc.Resolver.CorporationTicker(context.Background(), corporationID)
+	if err != nil {
+		svc.Logger.Warnf("Failed to look up the ticker of corporation %d: %v", corporationID, err)
+	}
+	return ticker
+}
+
+// LookupFitting resolves the victim's fit and cargo on a killmail, grouped by slot.
+func (svc *OrchestrateService) LookupFitting(km model.DetailedKillMail) *data.Fitting {
+	return svc.InvTypeService.ResolveFitting(km.Victim)
+}
+
+// LookupShipClass returns the group of a ship type, such as "Heavy Assault Cruiser", or "Unknown".
+func (svc *OrchestrateService) LookupShipClass(shipTypeID int) string {
+	if info, ok := svc.StaticData.Type(shipTypeID); ok && info.GroupName != "" {
+		return info.GroupName
+	}
+	return "Unknown"
+}
+
+// LookupSolarSystem returns a solar system with its region and wormhole class.
+func (svc *OrchestrateService) LookupSolarSystem(systemID int) (model.SolarSystem, bool) {
+	return svc.StaticData.SolarSystem(systemID)
+}
+
 type YearMonth struct {
 	Year  int
 	Month int
 }
 
-func (svc *OrchestrateService) CheckDataAvailability(startMonth, endMonth, startYear int) (map[int]bool, error) {
+// CheckDataAvailability reports which months between startDate and endDate have usable store files. Current or
+// previous months whose data is older than the staleness window are also returned as stale so they can be
+// refreshed incrementally.
+func (svc *OrchestrateService) CheckDataAvailability(startDate, endDate time.Time) (map[int]bool, []int, error) {
 	dataAvailability := make(map[int]bool)
+	var staleMonths []int
 	currentTime := time.Now()
 	stalenessDuration := 24 * time.Hour
 
-	yearMonths, err := generateYearMonthPairs(startMonth, endMonth, startYear)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, ym := range yearMonths {
+	for _, ym := range generateYearMonthPairs(startDate, endDate) {
 		y, m := ym.Year, ym.Month
 		key := getYearMonthKey(y, m)
 
-		fileName := persist.GenerateZkillFileName(y, m)
-		fileInfo, err := os.Stat(fileName)
+		fileInfo, usable, err := svc.Store.StatMonthFile(y, m)
 
 		if err != nil {
+			if errors.Is(err, persist.ErrKillMailIDMissing) {
+				svc.Logger.Warnf("Data for %04d-%02d predates stored killmail IDs, fetching it again", y, m)
+			}
 			dataAvailability[key] = false
 			continue
 		}
 
-		if fileInfo.Size() <= 1*1024 {
-			svc.Logger.Warnf("File %s is too small (%d bytes). Marking as unavailable.\n", fileName, fileInfo.Size())
+		if !usable {
+			svc.Logger.Warnf("File %s is too small (%d bytes). Marking as unavailable.\n", fileInfo.Name(), fileInfo.Size())
 			dataAvailability[key] = false
 			continue
 		}
@@ -403,39 +686,43 @@ func (svc *OrchestrateService) CheckDataAvailability(startMonth, endMonth, start
 
 		// Check if the file is stale for current or previous month
 		if isCurrentOrPreviousMonth(y, m, currentTime) {
-			age := currentTime.Sub(fileInfo.ModTime())
+			refreshedAt := fileInfo.ModTime()
+			if cursors, err := svc.Store.LoadMonthCursors(y, m); err == nil && cursors != nil && cursors.RefreshedAt.After(refreshedAt) {
+				refreshedAt = cursors.RefreshedAt
+			}
+
+			age := currentTime.Sub(refreshedAt)
 			if age > stalenessDuration {
-				svc.Logger.Warnf("Removing stale file %s (age: %v)...\n", fileName, age)
-				dataAvailability[key] = false
-				err = os.Remove(fileName)
-				if err != nil {
-					svc.Logger.Errorf("Error removing stale file %s: %s\n", fileName, err)
-				}
+				svc.Logger.Warnf("Data for %04d-%02d is stale (age: %v), scheduling incremental refresh\n", y, m, age)
+				staleMonths = append(staleMonths, key)
 			} else {
-				svc.Logger.Infof("Using recent file %s (age: %v)\n", fileName, age)
+				svc.Logger.Infof("Using recent file %s (age: %v)\n", fileInfo.Name(), age)
 			}
 		}
 	}
 
-	return dataAvailability, nil
+	return dataAvailability, staleMonths, nil
 }
 
-func generateYearMonthPairs(startMonth, endMonth, startYear int) ([]struct{ Year, Month int }, error) {
-	var yearMonths []struct{ Year, Month int }
+// generateYearMonthPairs lists every calendar month touched by the range, in order, each with its own year.
+func generateYearMonthPairs(startDate, endDate time.Time) []YearMonth {
+	var yearMonths []YearMonth
 
-	startDate := time.Date(startYear, time.Month(startMonth), 1, 0, 0, 0, 0, time.UTC)
-	endDate := time.Date(startYear, time.Month(endMonth), 1, 0, 0, 0, 0, time.UTC)
+	first := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(endDate.Year(), endDate.Month(), 1, 0, 0, 0, 0, time.UTC)
 
-	if endDate.Before(startDate) {
-		// Handle crossing over to the next year
-		endDate = endDate.AddDate(1, 0, 0)
+	for d := first; !d.After(last); d = d.AddDate(0, 1, 0) {
+		yearMonths = append(yearMonths, YearMonth{Year: d.Year(), Month: int(d.Month())})
 	}
 
-	for d := startDate; !d.After(endDate); d = d.AddDate(0, 1, 0) {
-		yearMonths = append(yearMonths, struct{ Year, Month int }{d.Year(), int(d.Month())})
-	}
+	return yearMonths
+}
 
-	return yearMonths, nil
+// dayRange returns the start of startDate and the start of the day after endDate, covering both days whole.
+func dayRange(startDate, endDate time.Time) (time.Time, time.Time) {
+	from := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.UTC)
+	until := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	return from, until
 }
 
 // getYearMonthKey generates a unique integer key from a year and month.
@@ -451,6 +738,17 @@ func extractYearMonthKey(key int) (year int, month int) {
 	return
 }
 
+// unionIDs returns the IDs of base followed by any IDs from extra not already present.
+func unionIDs(base, extra []int) []int {
+	result := append([]int{}, base...)
+	for _, id := range extra {
+		if !persist.Contains(result, id) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
 func isCurrentOrPreviousMonth(year, month int, currentTime time.Time) bool {
 	currentYear, currentMonth := currentTime.Year(), int(currentTime.Month())
 	previousTime := currentTime.AddDate(0, -1, 0)
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+func TestRefreshMonth(t *testing.T) {
+	const corpID = 98000001
+	const year, month = 2024, 5
+	kills := CursorKey("kills", config.EntityTypeCorporation, corpID)
+
+	tests := []struct {
+		name     string
+		pages    map[string][][]int64
+		failPage map[string]int
+		wantErr  bool
+		// wantIDs are the killmails in the month file afterwards
+		wantIDs []int
+		// wantCursor is the kills cursor saved for the month, or 0 if none should be
+		wantCursor int64
+		wantStored int
+	}{
+		{
+			name:       "new killmails advance the cursor",
+			pages:      map[string][][]int64{kills: {{100, 90}, {80}}},
+			wantIDs:    []int{80, 90, 100},
+			wantCursor: 100,
+			wantStored: 2,
+		},
+		{
+			name:     "a failed page leaves the month and cursor alone",
+			pages:    map[string][][]int64{kills: {{100, 90}, {80}}},
+			failPage: map[string]int{kills: 2},
+			wantErr:  true,
+			wantIDs:  []int{80},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdirTemp(t)
+			store := persist.KillMailStore{Group: "test", Root: "store"}
+			fileName := store.MonthFileName(year, month)
+
+			// The stored month already holds killmail 80, which its cursor is derived from
+			seeded := model.DetailedKillMail{
+				KillMail:    model.KillMail{KillMailID: 80, ZKB: model.ZKB{Hash: "hash80"}},
+				EsiKillMail: model.EsiKillMail{KillMailID: 80, Attackers: []model.Attacker{{CorporationID: corpID}}},
+			}
+			if err := persist.SaveKillMailsToFile(fileName, &model.KillMailData{KillMails: []model.DetailedKillMail{seeded}}); err != nil {
+				t.Fatal(err)
+			}
+
+			source := &fakeSource{pages: tt.pages, failPage: tt.failPage, attackerCorp: corpID}
+			repository := persist.NewMemoryKillMailRepository()
+			svc := &OrchestrateService{
+				KillMailService: NewKillMailService(source, nil, testLogger(), 2),
+				Repository:      repository,
+				Store:           store,
+				Logger:          testLogger(),
+			}
+
+			_, err := svc.refreshMonth(context.Background(), &model.Params{Corporations: []int{corpID}}, year, month)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("refreshMonth error = %v, want error %v", err, tt.wantErr)
+			}
+
+			var gotIDs []int
+			err = persist.EachKillMail(fileName, func(km model.DetailedKillMail) error {
+				gotIDs = append(gotIDs, int(km.KillMail.KillMailID))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Ints(gotIDs)
+			if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+				t.Errorf("month file holds %v, want %v", gotIDs, tt.wantIDs)
+			}
+
+			cursors, err := store.LoadMonthCursors(year, month)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotCursor int64
+			if cursors != nil {
+				gotCursor = cursors.Cursors[kills].LastKillMailID
+			}
+			if gotCursor != tt.wantCursor {
+				t.Errorf("saved kills cursor = %d, want %d", gotCursor, tt.wantCursor)
+			}
+
+			if stored, err := repository.CountKillMails(context.Background()); err != nil || stored != tt.wantStored {
+				t.Errorf("repository holds %d killmails (%v), want %d", stored, err, tt.wantStored)
+			}
+		})
+	}
+}
+
+// chdirTemp runs the rest of the test from a temporary directory, since stores resolve paths
+// against the working directory.
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestGenerateYearMonthPairs(t *testing.T) {
+	tests := []struct {
+		name  string
+		start time.Time
+		end   time.Time
+		want  []YearMonth
+	}{
+		{
+			name:  "single day",
+			start: time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC),
+			want:  []YearMonth{{Year: 2024, Month: 5}},
+		},
+		{
+			name:  "across a year boundary",
+			start: time.Date(2023, time.November, 20, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC),
+			want:  []YearMonth{{2023, 11}, {2023, 12}, {2024, 1}, {2024, 2}},
+		},
+		{
+			name:  "start late in its month",
+			start: time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+			want:  []YearMonth{{2024, 1}, {2024, 2}, {2024, 3}},
+		},
+		{
+			name:  "several years",
+			start: time.Date(2022, time.December, 1, 0, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+			want: []YearMonth{{2022, 12}, {2023, 1}, {2023, 2}, {2023, 3}, {2023, 4}, {2023, 5}, {2023, 6},
+				{2023, 7}, {2023, 8}, {2023, 9}, {2023, 10}, {2023, 11}, {2023, 12}, {2024, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generateYearMonthPairs(tt.start, tt.end); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generateYearMonthPairs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDayRange(t *testing.T) {
+	from, until := dayRange(
+		time.Date(2023, time.December, 31, 18, 0, 0, 0, time.UTC),
+		time.Date(2024, time.January, 1, 9, 30, 0, 0, time.UTC))
+
+	if want := time.Date(2023, time.December, 31, 0, 0, 0, 0, time.UTC); !from.Equal(want) {
+		t.Errorf("from = %s, want %s", from, want)
+	}
+	if want := time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC); !until.Equal(want) {
+		t.Errorf("until = %s, want %s", until, want)
+	}
+}
+
+func TestRefreshMonthRetriesFailedHydration(t *testing.T) {
+	const corpID = 98000001
+	const year, month = 2024, 5
+	kills := CursorKey("kills", config.EntityTypeCorporation, corpID)
+
+	tests := []struct {
+		name string
+		// seeded stores killmail 80 before the first refresh, so it runs incrementally
+		seeded bool
+	}{
+		{name: "incremental refresh", seeded: true},
+		{name: "full month fetch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdirTemp(t)
+			store := persist.KillMailStore{Group: "test", Root: "store"}
+			fileName := store.MonthFileName(year, month)
+			if tt.seeded {
+				seeded := model.DetailedKillMail{
+					KillMail:    model.KillMail{KillMailID: 80, ZKB: model.ZKB{Hash: "hash80"}},
+					EsiKillMail: model.EsiKillMail{KillMailID: 80, Attackers: []model.Attacker{{CorporationID: corpID}}},
+				}
+				if err := persist.SaveKillMailsToFile(fileName, &model.KillMailData{KillMails: []model.DetailedKillMail{seeded}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			source := &fakeSource{
+				pages:         map[string][][]int64{kills: {{100, 90}, {80}}},
+				attackerCorp:  corpID,
+				failHydration: map[int]bool{90: true},
+			}
+			svc := &OrchestrateService{
+				KillMailService: NewKillMailService(source, nil, testLogger(), 2),
+				Repository:      persist.NewMemoryKillMailRepository(),
+				Store:           store,
+				Logger:          testLogger(),
+			}
+			params := &model.Params{Corporations: []int{corpID}}
+
+			steps := []struct {
+				wantIDs    []int
+				wantCursor int64
+			}{
+				// Killmail 90 fails, so the cursor stays below it although 100 was stored
+				{wantIDs: []int{80, 100}, wantCursor: 89},
+				// and the next refresh pages back down to it
+				{wantIDs: []int{80, 90, 100}, wantCursor: 100},
+			}
+			for i, step := range steps {
+				if _, err := svc.refreshMonth(context.Background(), params, year, month); err != nil {
+					t.Fatalf("refresh %d: %v", i+1, err)
+				}
+
+				var gotIDs []int
+				err := persist.EachKillMail(fileName, func(km model.DetailedKillMail) error {
+					gotIDs = append(gotIDs, int(km.KillMail.KillMailID))
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Ints(gotIDs)
+				if !reflect.DeepEqual(gotIDs, step.wantIDs) {
+					t.Errorf("after refresh %d the month file holds %v, want %v", i+1, gotIDs, step.wantIDs)
+				}
+
+				cursors, err := store.LoadMonthCursors(year, month)
+				if err != nil || cursors == nil {
+					t.Fatalf("after refresh %d: cursors %v, %v", i+1, cursors, err)
+				}
+				if got := cursors.Cursors[kills].LastKillMailID; got != step.wantCursor {
+					t.Errorf("after refresh %d the kills cursor = %d, want %d", i+1, got, step.wantCursor)
+				}
+			}
+		})
+	}
+}
@@ -4,11 +4,8 @@ package service
 
 import (
 	"context"
-	"log"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -35,12 +32,16 @@ func NewPrefetchService(os *OrchestrateService, logger *logrus.Logger) *Prefetch
 	}
 }
 
-// Start begins the prefetching process.
+// Start begins the prefetching process. It runs until ctx is cancelled; the shared cache is left to its owner.
 func (pf *PrefetchService) Start(ctx context.Context) {
+	pf.startPrefetching(ctx)
+}
+
+// startPrefetching runs the prefetch loop until ctx is cancelled, leaving the shared cache to whoever owns it.
+func (pf *PrefetchService) startPrefetching(ctx context.Context) {
 	pf.wg.Add(1)
 	go pf.run(ctx)
-	pf.saveCacheOnExit() // Register exit handler here instead of in Stop
-	pf.Logger.Info("PrefetchService started.")
+	pf.Logger.Infof("PrefetchService started for group %s.", pf.OrchestrateService.Group)
 }
 
 // run contains the main loop for prefetching.
@@ -51,9 +52,6 @@ func (pf *PrefetchService) run(ctx context.Context) {
 	ticker := time.NewTicker(24 * time.Hour)
 	defer ticker.Stop()
 
-	saveTicker := time.NewTicker(1 * time.Hour) // Save cache every hour
-	defer saveTicker.Stop()
-
 	// Initial prefetch immediately upon starting
 	pf.prefetch(ctx)
 
@@ -61,13 +59,6 @@ func (pf *PrefetchService) run(ctx context.Context) {
 		select {
 		case <-ticker.C:
 			pf.prefetch(ctx)
-		case <-saveTicker.C:
-			cacheFile := persist.GenerateCacheDataFileName()
-			if err := pf.OrchestrateService.Cache.SaveToFile(cacheFile); err != nil {
-				pf.Logger.Errorf("Failed to save cache periodically: %v", err)
-			} else {
-				pf.Logger.Infof("Cache saved periodically to %s", cacheFile)
-			}
 		case <-ctx.Done():
 			pf.Logger.Info("PrefetchService received context cancellation.")
 			return
@@ -77,15 +68,24 @@ func (pf *PrefetchService) run(ctx context.Context) {
 
 func (pf *PrefetchService) prefetch(ctx context.Context) {
 	pf.Logger.Info("Starting prefetch operation.")
-	begin, end := persist.GetDateRange(config.YearToDate)
-	pf.Logger.Infof("Prefetching data for %s to %s...", begin, end)
+	begin, end, err := persist.GetTimeRange(config.YearToDate)
+	if err != nil {
+		pf.Logger.Errorf("Error determining prefetch range: %v", err)
+		return
+	}
+	pf.Logger.Infof("Prefetching data for %s to %s...", begin.Format("2006-01-02"), end.Format("2006-01-02"))
 
 	// Use a derived context with a timeout to prevent indefinite blocking
 	prefetchCtx, cancel := context.WithTimeout(ctx, 1*time.Hour)
 	defer cancel()
 
+	tracked, ok := config.Group(pf.OrchestrateService.Group)
+	if !ok {
+		pf.Logger.Infof("Group %s is no longer tracked, skipping prefetch.", pf.OrchestrateService.Group)
+		return
+	}
 	pf.Logger.Info("Calling GetAllData...")
-	chartData, err := pf.OrchestrateService.GetAllData(prefetchCtx, config.CorporationIDs, config.AllianceIDs, config.CharacterIDs, begin, end)
+	chartData, err := pf.OrchestrateService.GetAllData(prefetchCtx, tracked.Corporations, tracked.Alliances, tracked.Characters, begin, end)
 	if err != nil {
 		pf.Logger.Errorf("Error fetching detailed killmails: %v", err)
 		return
@@ -93,32 +93,10 @@ func (pf *PrefetchService) prefetch(ctx context.Context) {
 	pf.Logger.Infof("Prefetch completed successfully with %d killmails.", len(chartData.KillMails))
 }
 
-func (pf *PrefetchService) saveCacheOnExit() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		// Block until we receive a termination signal
-		sig := <-sigChan
-		log.Printf("Received signal: %s, saving cache...", sig)
-
-		// Attempt to save the cache
-		cache := pf.OrchestrateService.Cache
-		cacheFile := persist.GenerateCacheDataFileName()
-		if err := cache.SaveToFile(cacheFile); err != nil {
-			log.Fatalf("Failed to save cache to file on exit: %v", err)
-		} else {
-			log.Printf("Cache successfully saved to %s on exit", cacheFile)
-		}
-
-		// Give an opportunity to gracefully exit
-		os.Exit(0)
-	}()
-}
-
+// Stop waits for the prefetch loop to exit once its context is cancelled. It leaves the shared cache open,
+// since ingestion and running jobs may still be writing to it.
 func (pf *PrefetchService) Stop() {
 	pf.Logger.Info("Waiting for PrefetchService to stop...")
-	pf.saveCacheOnExit() // Ensure signal handling is in place for Stop
 
 	done := make(chan struct{})
 	go func() {
@@ -130,13 +108,6 @@ func (pf *PrefetchService) Stop() {
 	select {
 	case <-done:
 		pf.Logger.Info("PrefetchService stopped.")
-		// Save the cache one last time upon stopping
-		cacheFile := persist.GenerateCacheDataFileName()
-		if err := pf.OrchestrateService.Cache.SaveToFile(cacheFile); err != nil {
-			pf.Logger.Errorf("Failed to save cache on final Stop: %v", err)
-		} else {
-			pf.Logger.Infof("Cache saved successfully on final Stop to %s", cacheFile)
-		}
 	case <-time.After(30 * time.Second):
 		pf.Logger.Error("PrefetchService did not stop within the timeout. Forcing exit.")
 		os.Exit(1)
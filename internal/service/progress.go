@@ -0,0 +1,135 @@
+// internal/service/progress.go
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ProgressStage is the step a data fetch is on.
+type ProgressStage string
+
+const (
+	ProgressChecking   ProgressStage = "checking"   // finding which months are stored
+	ProgressFetching   ProgressStage = "fetching"   // pulling a full month from zKillboard
+	ProgressRefreshing ProgressStage = "refreshing" // pulling killmails newer than a month's cursors
+	ProgressLoading    ProgressStage = "loading"    // querying stored killmails for the range
+	ProgressResolving  ProgressStage = "resolving"  // resolving character, corporation and alliance names
+	ProgressSaving     ProgressStage = "saving"     // persisting ESI data and tracked IDs
+)
+
+// ProgressEvent is a snapshot of a data fetch. Counts are running totals for the whole fetch.
+type ProgressEvent struct {
+	Stage      ProgressStage `json:"stage"`
+	Message    string        `json:"message"`
+	Month      string        `json:"month,omitempty"`       // YYYY-MM being fetched or refreshed
+	MonthIndex int           `json:"month_index,omitempty"` // 1-based position among the months to fetch
+	MonthCount int           `json:"month_count,omitempty"`
+	APIType    string        `json:"api_type,omitempty"`
+	EntityType string        `json:"entity_type,omitempty"`
+	EntityID   int           `json:"entity_id,omitempty"`
+	Page       int           `json:"page,omitempty"`
+
+	KillMailsHydrated int       `json:"killmails_hydrated"`
+	EntitiesResolved  int       `json:"entities_resolved"`
+	Time              time.Time `json:"time"`
+}
+
+// ProgressTracker accumulates the progress of a data fetch and publishes a snapshot on every change.
+// A nil tracker ignores every update, so code can report progress whether or not anyone is listening.
+type ProgressTracker struct {
+	mu      sync.Mutex
+	event   ProgressEvent
+	publish func(ProgressEvent)
+}
+
+// NewProgressTracker creates a tracker that passes each snapshot to publish.
+func NewProgressTracker(publish func(ProgressEvent)) *ProgressTracker {
+	return &ProgressTracker{publish: publish}
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context carrying the tracker.
+func WithProgress(ctx context.Context, tracker *ProgressTracker) context.Context {
+	return context.WithValue(ctx, progressKey{}, tracker)
+}
+
+// ProgressFrom returns the tracker carried by ctx, or nil.
+func ProgressFrom(ctx context.Context) *ProgressTracker {
+	tracker, _ := ctx.Value(progressKey{}).(*ProgressTracker)
+	return tracker
+}
+
+// Stage moves to a stage that isn't tied to a month.
+func (t *ProgressTracker) Stage(stage ProgressStage, message string) {
+	if t == nil {
+		return
+	}
+	t.update(func(event *ProgressEvent) {
+		event.Stage = stage
+		event.Message = message
+		event.Month, event.MonthIndex, event.MonthCount = "", 0, 0
+		event.APIType, event.EntityType, event.EntityID, event.Page = "", "", 0, 0
+	})
+}
+
+// Month moves to fetching or refreshing the index-th of count months.
+func (t *ProgressTracker) Month(stage ProgressStage, year, month, index, count int) {
+	if t == nil {
+		return
+	}
+	t.update(func(event *ProgressEvent) {
+		event.Stage = stage
+		event.Month = fmt.Sprintf("%04d-%02d", year, month)
+		event.MonthIndex, event.MonthCount = index, count
+		event.Message = fmt.Sprintf("%s %s (%d of %d)", stageVerb(stage), event.Month, index, count)
+		event.APIType, event.EntityType, event.EntityID, event.Page = "", "", 0, 0
+	})
+}
+
+// Page records a hydrated zKillboard page.
+func (t *ProgressTracker) Page(page PageProgress) {
+	if t == nil {
+		return
+	}
+	t.update(func(event *ProgressEvent) {
+		event.APIType = page.APIType
+		event.EntityType = page.EntityType
+		event.EntityID = page.EntityID
+		event.Page = page.Page
+		event.KillMailsHydrated += page.Hydrated
+	})
+}
+
+// Resolved adds to the count of ESI entities resolved.
+func (t *ProgressTracker) Resolved(count int) {
+	if t == nil || count <= 0 {
+		return
+	}
+	t.update(func(event *ProgressEvent) {
+		event.EntitiesResolved += count
+	})
+}
+
+func (t *ProgressTracker) update(apply func(event *ProgressEvent)) {
+	t.mu.Lock()
+	apply(&t.event)
+	t.event.Time = time.Now()
+	snapshot := t.event
+	t.mu.Unlock()
+
+	if t.publish != nil {
+		t.publish(snapshot)
+	}
+}
+
+func stageVerb(stage ProgressStage) string {
+	if stage == ProgressRefreshing {
+		return "Refreshing"
+	}
+	return "Fetching"
+}
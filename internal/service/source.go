@@ -0,0 +1,78 @@
+// internal/service/source.go
+
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/guarzo/zkillanalytics/internal/api/esi"
+	"github.com/guarzo/zkillanalytics/internal/api/zkill"
+	"github.com/guarzo/zkillanalytics/internal/model"
+)
+
+// KillmailSource supplies killmails the way zKillboard's entity feeds do, along with their full details.
+type KillmailSource interface {
+	// GetKillMailsPage returns one page of an entity's "kills" or "losses" in a month, newest first.
+	// An empty page means there are no more. Fresh asks the source to bypass any cached copy.
+	GetKillMailsPage(ctx context.Context, apiType, entityType string, entityID, page, year, month int, fresh bool) ([]model.KillMail, error)
+
+	// GetEsiKillMail returns the full details of a killmail.
+	GetEsiKillMail(ctx context.Context, killMailID int, hash string) (*model.EsiKillMail, error)
+}
+
+// EntityResolver names the characters, corporations and alliances that appear on killmails.
+type EntityResolver interface {
+	// ResolveKillMailEntities adds every entity on killMails that esiData does not yet hold.
+	ResolveKillMailEntities(ctx context.Context, killMails []model.DetailedKillMail, esiData *model.ESIData) error
+
+	// RefreshEntities brings the affiliations already in esiData up to date.
+	RefreshEntities(ctx context.Context, esiData *model.ESIData) error
+
+	// CorporationTicker returns a corporation's ticker, which resolving in bulk leaves out.
+	CorporationTicker(ctx context.Context, corporationID int) (string, error)
+}
+
+// ZkillSource lists killmails from zKillboard and hydrates them from ESI.
+type ZkillSource struct {
+	ZKillClient *zkill.ZkillClient
+	EsiClient   *esi.EsiClient
+}
+
+// NewZkillSource creates a KillmailSource backed by the live zKillboard and ESI APIs.
+func NewZkillSource(zkillClient *zkill.ZkillClient, esiClient *esi.EsiClient) *ZkillSource {
+	return &ZkillSource{
+		ZKillClient: zkillClient,
+		EsiClient:   esiClient,
+	}
+}
+
+// GetKillMailsPage implements KillmailSource.
+func (zs *ZkillSource) GetKillMailsPage(ctx context.Context, apiType, entityType string, entityID, page, year, month int, fresh bool) ([]model.KillMail, error) {
+	switch {
+	case apiType == "kills" && fresh:
+		return zs.ZKillClient.GetKillsPageDataNoCache(ctx, entityType, entityID, page, year, month)
+	case apiType == "kills":
+		return zs.ZKillClient.GetKillsPageData(ctx, entityType, entityID, page, year, month)
+	case apiType == "losses" && fresh:
+		return zs.ZKillClient.GetLossPageDataNoCache(ctx, entityType, entityID, page, year, month)
+	case apiType == "losses":
+		return zs.ZKillClient.GetLossPageData(ctx, entityType, entityID, page, year, month)
+	default:
+		return nil, fmt.Errorf("unknown zKillboard feed %q", apiType)
+	}
+}
+
+// GetEsiKillMail implements KillmailSource.
+func (zs *ZkillSource) GetEsiKillMail(ctx context.Context, killMailID int, hash string) (*model.EsiKillMail, error) {
+	return zs.EsiClient.GetEsiKillMail(ctx, killMailID, hash)
+}
+
+// Compile-time checks that the implementations satisfy their interfaces.
+var (
+	_ KillmailSource = (*ZkillSource)(nil)
+	_ KillmailSource = (*LocalSource)(nil)
+	_ KillmailSource = (*ManualSource)(nil)
+	_ EntityResolver = (*EsiService)(nil)
+	_ EntityResolver = (*StaticResolver)(nil)
+)
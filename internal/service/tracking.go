@@ -0,0 +1,226 @@
+// internal/service/tracking.go
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/persist"
+)
+
+// Tracked entity lists, as named in the tracked entities file and the admin API.
+const (
+	TrackedCorporations      = "corporations"
+	TrackedAlliances         = "alliances"
+	TrackedCharacters        = "characters"
+	TrackedExcludeCharacters = "exclude_characters"
+)
+
+// TrackedListNames are the lists of the tracked entities file.
+var TrackedListNames = []string{TrackedCorporations, TrackedAlliances, TrackedCharacters, TrackedExcludeCharacters}
+
+// TrackingService keeps the tracked groups in effect in step with the tracked entities file.
+type TrackingService struct {
+	FileName string
+	Resolver *EsiService
+	Logger   *logrus.Logger
+
+	// OnAdded, if set, is called for each group that corporations, alliances or characters were added to,
+	// including groups that are new to the file.
+	OnAdded func(group string)
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// NewTrackingService creates a TrackingService for fileName, resolving names through resolver.
+func NewTrackingService(fileName string, resolver *EsiService, logger *logrus.Logger) *TrackingService {
+	return &TrackingService{
+		FileName: fileName,
+		Resolver: resolver,
+		Logger:   logger,
+	}
+}
+
+// Load puts the tracked entities file into effect, writing it from config.DefaultTracked first if it does not exist.
+func (ts *TrackingService) Load() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if _, err := os.Stat(ts.FileName); os.IsNotExist(err) {
+		ts.Logger.Infof("Writing default tracked entities to %s", ts.FileName)
+		defaults := config.TrackedConfig{TrackedEntities: config.DefaultTracked.Clone()}
+		if err := persist.SaveTrackedEntities(ts.FileName, &defaults); err != nil {
+			return err
+		}
+	}
+	return ts.reloadLocked()
+}
+
+// Watch reloads the tracked entities file whenever it changes, checking every interval until ctx ends.
+// An edit that cannot be read is logged and leaves the entities in effect unchanged.
+func (ts *TrackingService) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ts.mu.Lock()
+			info, err := os.Stat(ts.FileName)
+			if err != nil {
+				ts.Logger.Warnf("Failed to check %s: %v", ts.FileName, err)
+			} else if !info.ModTime().Equal(ts.modTime) || info.Size() != ts.size {
+				ts.Logger.Infof("%s changed, reloading tracked entities", ts.FileName)
+				if err := ts.reloadLocked(); err != nil {
+					// Wait for the next edit rather than retrying a broken file every interval
+					ts.recordFileLocked()
+					ts.Logger.Errorf("Keeping current tracked entities: %v", err)
+				}
+			}
+			ts.mu.Unlock()
+		}
+	}
+}
+
+// Update applies change to a copy of a group's tracked entities, then saves the file and puts the result into effect.
+// Groups are added and removed by editing the file, so the group must already exist.
+func (ts *TrackingService) Update(group string, change func(tracked *config.TrackedEntities) error) (config.TrackedEntities, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	current, ok := config.Group(group)
+	if !ok {
+		return current, fmt.Errorf("unknown group %q", group)
+	}
+
+	next := config.CurrentTrackedConfig()
+	entities, _ := next.Group(group)
+	if err := change(entities); err != nil {
+		return current, err
+	}
+	if group != config.DefaultGroup {
+		next.Groups[group] = *entities
+	}
+	if err := next.Normalize(); err != nil {
+		return current, err
+	}
+	if err := persist.SaveTrackedEntities(ts.FileName, &next); err != nil {
+		return current, err
+	}
+	ts.recordFileLocked()
+	ts.apply(next)
+
+	updated, _ := next.Group(group)
+	return *updated, nil
+}
+
+// ResolveNames looks up IDs for names of the kind list holds. Names are matched exactly, ignoring case;
+// those ESI does not know as that kind are returned as unresolved.
+func (ts *TrackingService) ResolveNames(ctx context.Context, list string, names []string) ([]int, []string, error) {
+	if len(names) == 0 {
+		return nil, nil, nil
+	}
+	found, err := ts.Resolver.LookupIDs(ctx, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	candidates := found.Characters
+	switch list {
+	case TrackedCorporations:
+		candidates = found.Corporations
+	case TrackedAlliances:
+		candidates = found.Alliances
+	}
+	byName := make(map[string]int, len(candidates))
+	for _, candidate := range candidates {
+		byName[strings.ToLower(candidate.Name)] = candidate.ID
+	}
+
+	var ids []int
+	var unresolved []string
+	for _, name := range names {
+		if id, ok := byName[strings.ToLower(name)]; ok {
+			ids = append(ids, id)
+		} else {
+			unresolved = append(unresolved, name)
+		}
+	}
+	return ids, unresolved, nil
+}
+
+// TrackedList returns a pointer to the named list of tracked.
+func TrackedList(tracked *config.TrackedEntities, list string) (*[]int, error) {
+	switch list {
+	case TrackedCorporations:
+		return &tracked.Corporations, nil
+	case TrackedAlliances:
+		return &tracked.Alliances, nil
+	case TrackedCharacters:
+		return &tracked.Characters, nil
+	case TrackedExcludeCharacters:
+		return &tracked.ExcludeCharacters, nil
+	}
+	return nil, fmt.Errorf("unknown tracked list %q", list)
+}
+
+func (ts *TrackingService) reloadLocked() error {
+	tracked, err := persist.LoadTrackedEntities(ts.FileName)
+	if err != nil {
+		return err
+	}
+	ts.recordFileLocked()
+	ts.apply(*tracked)
+	return nil
+}
+
+// recordFileLocked remembers the file's state so Watch only reloads on changes made elsewhere.
+func (ts *TrackingService) recordFileLocked() {
+	if info, err := os.Stat(ts.FileName); err == nil {
+		ts.modTime, ts.size = info.ModTime(), info.Size()
+	}
+}
+
+func (ts *TrackingService) apply(next config.TrackedConfig) {
+	previous := config.CurrentTrackedConfig()
+	config.SetTrackedConfig(next)
+
+	for _, name := range config.GroupNames() {
+		group, _ := next.Group(name)
+		ts.Logger.Infof("Group %s tracks %d corporations, %d alliances and %d characters, excluding %d characters",
+			name, len(group.Corporations), len(group.Alliances), len(group.Characters), len(group.ExcludeCharacters))
+
+		before, ok := previous.Group(name)
+		if !ok {
+			before = &config.TrackedEntities{}
+		}
+		added := hasNewIDs(before.Corporations, group.Corporations) ||
+			hasNewIDs(before.Alliances, group.Alliances) ||
+			hasNewIDs(before.Characters, group.Characters)
+		if added && ts.OnAdded != nil {
+			ts.OnAdded(name)
+		}
+	}
+}
+
+func hasNewIDs(previous, next []int) bool {
+	for _, id := range next {
+		if !slices.Contains(previous, id) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,21 +3,20 @@ package service
 import (
 	"fmt"
 	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/persist"
 	"github.com/sirupsen/logrus"
 )
 
 // TrustedService provides methods to manage trusted and untrusted entities.
 type TrustedService struct {
-	dataLoader func() (*model.TrustedCharacters, error)
-	dataSaver  func(*model.TrustedCharacters) error
+	Repository persist.TrustedRepository
 	Logger     *logrus.Logger
 }
 
-// NewTrustedService creates a new TrustedService with injected dependencies.
-func NewTrustedService(dataLoader func() (*model.TrustedCharacters, error), dataSaver func(*model.TrustedCharacters) error, logger *logrus.Logger) *TrustedService {
+// NewTrustedService creates a new TrustedService storing the trust lists in repository.
+func NewTrustedService(repository persist.TrustedRepository, logger *logrus.Logger) *TrustedService {
 	return &TrustedService{
-		dataLoader: dataLoader,
-		dataSaver:  dataSaver,
+		Repository: repository,
 		Logger:     logger,
 	}
 }
@@ -26,7 +25,7 @@ func NewTrustedService(dataLoader func() (*model.TrustedCharacters, error), data
 func (s *TrustedService) AddTrustedCharacter(newCharacter model.TrustedCharacter) error {
 	s.Logger.Infof("Starting addition process for character ID: %d (%s)", newCharacter.CharacterID, newCharacter.CharacterName)
 
-	trustedData, err := s.dataLoader()
+	trustedData, err := s.Repository.LoadTrusted()
 	if err != nil {
 		s.Logger.Errorf("Failed to load trusted data during addition: %v", err)
 		return fmt.Errorf("failed to load trusted data: %v", err)
@@ -42,7 +41,7 @@ func (s *TrustedService) AddTrustedCharacter(newCharacter model.TrustedCharacter
 	trustedData.TrustedCharacters = append(trustedData.TrustedCharacters, newCharacter)
 	s.Logger.Infof("Character ID %d (%s) added to the trusted list", newCharacter.CharacterID, newCharacter.CharacterName)
 
-	err = s.dataSaver(trustedData)
+	err = s.Repository.SaveTrusted(trustedData)
 	if err != nil {
 		s.Logger.Errorf("Failed to save updated trusted data after addition: %v", err)
 		return fmt.Errorf("failed to save updated trusted data: %v", err)
@@ -56,7 +55,7 @@ func (s *TrustedService) AddTrustedCharacter(newCharacter model.TrustedCharacter
 func (s *TrustedService) RemoveTrustedCharacter(characterID int64) error {
 	s.Logger.Infof("Starting removal process for character ID: %d", characterID)
 
-	trustedData, err := s.dataLoader()
+	trustedData, err := s.Repository.LoadTrusted()
 	if err != nil {
 		s.Logger.Errorf("Failed to load trusted data during removal: %v", err)
 		return fmt.Errorf("failed to load trusted data: %v", err)
@@ -72,7 +71,7 @@ func (s *TrustedService) RemoveTrustedCharacter(characterID int64) error {
 		s.Logger.Infof("Character ID %d successfully removed from the trusted list", characterID)
 	}
 
-	err = s.dataSaver(trustedData)
+	err = s.Repository.SaveTrusted(trustedData)
 	if err != nil {
 		s.Logger.Errorf("Failed to save updated trusted data after removal: %v", err)
 		return fmt.Errorf("failed to save updated trusted data: %v", err)
@@ -84,7 +83,7 @@ func (s *TrustedService) RemoveTrustedCharacter(characterID int64) error {
 
 // AddTrustedCorporation adds a new corporation to the trusted list.
 func (s *TrustedService) AddTrustedCorporation(newCorporation model.TrustedCorporation) error {
-	trustedData, err := s.dataLoader()
+	trustedData, err := s.Repository.LoadTrusted()
 	if err != nil {
 		return fmt.Errorf("failed to load trusted data: %v", err)
 	}
@@ -97,24 +96,24 @@ func (s *TrustedService) AddTrustedCorporation(newCorporation model.TrustedCorpo
 	}
 
 	trustedData.TrustedCorporations = append(trustedData.TrustedCorporations, newCorporation)
-	return s.dataSaver(trustedData)
+	return s.Repository.SaveTrusted(trustedData)
 }
 
 // RemoveTrustedCorporation removes a corporation from the trusted list by CorporationID.
 func (s *TrustedService) RemoveTrustedCorporation(id int64) error {
-	trustedData, err := s.dataLoader()
+	trustedData, err := s.Repository.LoadTrusted()
 	if err != nil {
 		return fmt.Errorf("failed to load trusted data: %v", err)
 	}
 
 	trustedData.TrustedCorporations = filterCorporations(trustedData.TrustedCorporations, id)
 	s.Logger.Infof("Removed corporation %d from trusted list", id)
-	return s.dataSaver(trustedData)
+	return s.Repository.SaveTrusted(trustedData)
 }
 
 // AddUntrustedCharacter adds a character to the untrusted list.
 func (s *TrustedService) AddUntrustedCharacter(character model.TrustedCharacter) error {
-	data, err := s.dataLoader()
+	data, err := s.Repository.LoadTrusted()
 	if err != nil {
 		return fmt.Errorf("failed to load trusted data: %v", err)
 	}
@@ -127,12 +126,12 @@ func (s *TrustedService) AddUntrustedCharacter(character model.TrustedCharacter)
 	}
 
 	data.UntrustedCharacters = append(data.UntrustedCharacters, character)
-	return s.dataSaver(data)
+	return s.Repository.SaveTrusted(data)
 }
 
 // AddUntrustedCorporation adds a corporation to the untrusted list.
 func (s *TrustedService) AddUntrustedCorporation(corp model.TrustedCorporation) error {
-	data, err := s.dataLoader()
+	data, err := s.Repository.LoadTrusted()
 	if err != nil {
 		return fmt.Errorf("failed to load trusted data: %v", err)
 	}
@@ -145,12 +144,12 @@ func (s *TrustedService) AddUntrustedCorporation(corp model.TrustedCorporation)
 	}
 
 	data.UntrustedCorporations = append(data.UntrustedCorporations, corp)
-	return s.dataSaver(data)
+	return s.Repository.SaveTrusted(data)
 }
 
 // RemoveUntrustedCharacter removes a character from the untrusted list by CharacterID.
 func (s *TrustedService) RemoveUntrustedCharacter(characterID int64) error {
-	data, err := s.dataLoader()
+	data, err := s.Repository.LoadTrusted()
 	if err != nil {
 		return fmt.Errorf("failed to load trusted data: %v", err)
 	}
@@ -168,12 +167,12 @@ func (s *TrustedService) RemoveUntrustedCharacter(characterID int64) error {
 	}
 
 	data.UntrustedCharacters = filtered
-	return s.dataSaver(data)
+	return s.Repository.SaveTrusted(data)
 }
 
 // RemoveUntrustedCorporation removes a corporation from the untrusted list by CorporationID.
 func (s *TrustedService) RemoveUntrustedCorporation(corpID int64) error {
-	data, err := s.dataLoader()
+	data, err := s.Repository.LoadTrusted()
 	if err != nil {
 		return fmt.Errorf("failed to load trusted data: %v", err)
 	}
@@ -191,11 +190,11 @@ func (s *TrustedService) RemoveUntrustedCorporation(corpID int64) error {
 	}
 
 	data.UntrustedCorporations = filtered
-	return s.dataSaver(data)
+	return s.Repository.SaveTrusted(data)
 }
 
 func (s *TrustedService) GetTrustedCharacters() (*model.TrustedCharacters, error) {
-	return s.dataLoader()
+	return s.Repository.LoadTrusted()
 }
 
 // Utility function to filter out a character by ID.
@@ -0,0 +1,275 @@
+// internal/utils/cassette.go
+
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// CassetteModeRecord saves every exchange to the cassette directory as it happens.
+	CassetteModeRecord = "record"
+	// CassetteModeReplay answers every request from the cassette directory without touching the network.
+	CassetteModeReplay = "replay"
+
+	defaultCassetteDir = "data/cassettes"
+)
+
+// redactedQueryParams are removed from recorded URLs and request keys because they carry credentials.
+var redactedQueryParams = []string{"token"}
+
+// redactedFields are form and JSON object fields whose values are replaced with redactedValue in recorded
+// bodies and request keys. They carry SSO authorization codes, client secrets and access and refresh tokens,
+// so with them redacted an SSO exchange replays whatever code or token the replaying request sends.
+var redactedFields = []string{"code", "code_verifier", "client_secret", "access_token", "refresh_token", "id_token"}
+
+// redactedHeaders are left out of recorded response headers.
+var redactedHeaders = []string{"Authorization", "Set-Cookie"}
+
+const redactedValue = "REDACTED"
+
+// GetCassetteConfig retrieves the record/replay mode and cassette directory from HTTP_CASSETTE_MODE
+// and HTTP_CASSETTE_DIR. An empty mode means requests go straight to the network.
+func GetCassetteConfig() (string, string) {
+	mode := strings.ToLower(os.Getenv("HTTP_CASSETTE_MODE"))
+	if mode != "" && mode != CassetteModeRecord && mode != CassetteModeReplay {
+		log.Printf("Unknown HTTP_CASSETTE_MODE %q, ignoring", mode)
+		mode = ""
+	}
+
+	dir := os.Getenv("HTTP_CASSETTE_DIR")
+	if dir == "" {
+		dir = defaultCassetteDir
+	}
+	return mode, dir
+}
+
+// cassette is one recorded exchange as stored on disk.
+type cassette struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+		Body   string `json:"body,omitempty"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int         `json:"status_code"`
+		Header     http.Header `json:"header"`
+		Body       string      `json:"body"`
+		BodyBase64 bool        `json:"body_base64,omitempty"`
+	} `json:"response"`
+}
+
+// CassetteTransport records exchanges to, or replays them from, JSON files in Dir. Identical requests
+// are numbered in the order they are made, so polled endpoints replay their responses in sequence;
+// once a sequence runs out its last response is repeated.
+type CassetteTransport struct {
+	Mode    string
+	Dir     string
+	Wrapped http.RoundTripper
+
+	mu       sync.Mutex
+	sequence map[string]int
+}
+
+// NewCassetteTransport creates a transport recording or replaying in dir. Wrapped is only used when recording.
+func NewCassetteTransport(mode, dir string, wrapped http.RoundTripper) *CassetteTransport {
+	return &CassetteTransport{
+		Mode:     mode,
+		Dir:      dir,
+		Wrapped:  wrapped,
+		sequence: make(map[string]int),
+	}
+}
+
+// RoundTrip implements the RoundTripper interface.
+func (ct *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	requestURL := redactURL(req.URL)
+	redactedBody := redactBody(requestBody, req.Header.Get("Content-Type"))
+	key := cassetteKey(req.Method, requestURL, redactedBody)
+
+	ct.mu.Lock()
+	n := ct.sequence[key]
+	ct.sequence[key] = n + 1
+	ct.mu.Unlock()
+
+	if ct.Mode == CassetteModeReplay {
+		return ct.replay(req, key, n)
+	}
+	return ct.record(req, key, n, requestURL, redactedBody)
+}
+
+// record sends the request on and saves the exchange. The request URL and body are passed in already redacted,
+// and the response is redacted only as saved; the caller gets it as received.
+func (ct *CassetteTransport) record(req *http.Request, key string, n int, requestURL string, requestBody []byte) (*http.Response, error) {
+	resp, err := ct.Wrapped.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	var entry cassette
+	entry.Request.Method = req.Method
+	entry.Request.URL = requestURL
+	entry.Request.Body = string(requestBody)
+	entry.Response.StatusCode = resp.StatusCode
+	entry.Response.Header = resp.Header.Clone()
+	for _, header := range redactedHeaders {
+		entry.Response.Header.Del(header)
+	}
+	recordedBody := redactBody(responseBody, resp.Header.Get("Content-Type"))
+	if utf8.Valid(recordedBody) {
+		entry.Response.Body = string(recordedBody)
+	} else {
+		entry.Response.Body = base64.StdEncoding.EncodeToString(recordedBody)
+		entry.Response.BodyBase64 = true
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(ct.Dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(ct.fileName(key, n), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return resp, nil
+}
+
+func (ct *CassetteTransport) replay(req *http.Request, key string, n int) (*http.Response, error) {
+	data, err := os.ReadFile(ct.fileName(key, n))
+	for err != nil && os.IsNotExist(err) && n > 0 {
+		// Past the end of a recorded sequence; repeat its last response
+		n--
+		data, err = os.ReadFile(ct.fileName(key, n))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no cassette for %s %s: %w", req.Method, redactURL(req.URL), err)
+	}
+
+	var entry cassette
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode cassette for %s %s: %w", req.Method, redactURL(req.URL), err)
+	}
+
+	body := []byte(entry.Response.Body)
+	if entry.Response.BodyBase64 {
+		if body, err = base64.StdEncoding.DecodeString(entry.Response.Body); err != nil {
+			return nil, fmt.Errorf("failed to decode cassette body: %w", err)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Response.StatusCode, http.StatusText(entry.Response.StatusCode)),
+		StatusCode:    entry.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Response.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (ct *CassetteTransport) fileName(key string, n int) string {
+	return filepath.Join(ct.Dir, fmt.Sprintf("%s-%03d.json", key, n))
+}
+
+// cassetteKey identifies a request by method, redacted URL and body, ignoring headers such as tokens.
+func cassetteKey(method, requestURL string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + requestURL + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))[:24]
+}
+
+// redactBody replaces the values of redactedFields in a form or JSON object body with redactedValue. Other
+// bodies, and bodies without any of the fields, are returned unchanged.
+func redactBody(body []byte, contentType string) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		redacted := false
+		for _, field := range redactedFields {
+			if values.Has(field) {
+				values.Set(field, redactedValue)
+				redacted = true
+			}
+		}
+		if !redacted {
+			return body
+		}
+		return []byte(values.Encode())
+	case "application/json":
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			// Arrays and scalars carry no credentials
+			return body
+		}
+		redacted := false
+		for _, field := range redactedFields {
+			if _, ok := fields[field]; ok {
+				fields[field] = json.RawMessage(`"` + redactedValue + `"`)
+				redacted = true
+			}
+		}
+		if !redacted {
+			return body
+		}
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return body
+		}
+		return data
+	}
+	return body
+}
+
+// redactURL returns the URL without credential query parameters.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	for _, param := range redactedQueryParams {
+		query.Del(param)
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
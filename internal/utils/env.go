@@ -9,6 +9,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 )
 
 // GetPort retrieves the port from the PORT environment variable.
@@ -67,6 +68,158 @@ func GetVersion() string {
 	return version
 }
 
+// GetEsiConcurrency retrieves how many ESI killmails may be fetched at once from ESI_CONCURRENCY.
+// It returns 0 when unset so callers fall back to their default.
+func GetEsiConcurrency() int {
+	value := os.Getenv("ESI_CONCURRENCY")
+	if value == "" {
+		return 0
+	}
+
+	var concurrency int
+	if _, err := fmt.Sscanf(value, "%d", &concurrency); err != nil || concurrency <= 0 {
+		log.Printf("Invalid ESI_CONCURRENCY value %q, using default", value)
+		return 0
+	}
+	log.Printf("Using ESI_CONCURRENCY from environment: %d", concurrency)
+	return concurrency
+}
+
+// GetSDEDir retrieves the directory holding static data export files from SDE_DIR, defaulting to static.
+func GetSDEDir() string {
+	dir := os.Getenv("SDE_DIR")
+	if dir == "" {
+		return "static"
+	}
+	log.Printf("Using SDE_DIR from environment: %s", dir)
+	return dir
+}
+
+// GetCacheMaxBytes retrieves the cache size cap from CACHE_MAX_MB.
+// It returns 0 when unset so callers fall back to their default.
+func GetCacheMaxBytes() int64 {
+	value := os.Getenv("CACHE_MAX_MB")
+	if value == "" {
+		return 0
+	}
+
+	var megabytes int64
+	if _, err := fmt.Sscanf(value, "%d", &megabytes); err != nil || megabytes <= 0 {
+		log.Printf("Invalid CACHE_MAX_MB value %q, using default", value)
+		return 0
+	}
+	log.Printf("Using CACHE_MAX_MB from environment: %d", megabytes)
+	return megabytes * 1024 * 1024
+}
+
+// GetBackupsKept retrieves how many backups of each persisted file to keep from BACKUPS_KEPT.
+// It returns -1 when unset so callers fall back to their default; 0 disables backups.
+func GetBackupsKept() int {
+	value := os.Getenv("BACKUPS_KEPT")
+	if value == "" {
+		return -1
+	}
+
+	var kept int
+	if _, err := fmt.Sscanf(value, "%d", &kept); err != nil || kept < 0 {
+		log.Printf("Invalid BACKUPS_KEPT value %q, using default", value)
+		return -1
+	}
+	log.Printf("Using BACKUPS_KEPT from environment: %d", kept)
+	return kept
+}
+
+// GetTrackedFile retrieves the path of the tracked entities file from TRACKED_FILE.
+// It returns "" when unset so callers fall back to their default.
+func GetTrackedFile() string {
+	fileName := os.Getenv("TRACKED_FILE")
+	if fileName != "" {
+		log.Printf("Using TRACKED_FILE from environment: %s", fileName)
+	}
+	return fileName
+}
+
+// GetAdminCharacterIDs retrieves the characters allowed to use the admin API from the
+// comma-separated ADMIN_CHARACTER_IDS. Without any, the admin API is closed to everyone.
+func GetAdminCharacterIDs() []int64 {
+	value := os.Getenv("ADMIN_CHARACTER_IDS")
+	if value == "" {
+		return nil
+	}
+
+	var ids []int64
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		var id int64
+		if _, err := fmt.Sscanf(field, "%d", &id); err != nil || id <= 0 {
+			log.Printf("Ignoring invalid ADMIN_CHARACTER_IDS entry %q", field)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	log.Printf("Using %d admin characters from ADMIN_CHARACTER_IDS", len(ids))
+	return ids
+}
+
+// GetAPITokens retrieves the bearer tokens accepted by the analytics API from the comma-separated
+// API_TOKENS variable. Without any, only logged-in users can use the API.
+func GetAPITokens() []string {
+	var tokens []string
+	for _, field := range strings.Split(os.Getenv("API_TOKENS"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			tokens = append(tokens, field)
+		}
+	}
+	if len(tokens) > 0 {
+		log.Printf("Using %d API tokens from API_TOKENS", len(tokens))
+	}
+	return tokens
+}
+
+// GetRangeEarliestDate retrieves the first day ad-hoc date ranges may start on from RANGE_EARLIEST_DATE,
+// in YYYY-MM-DD form. It returns the zero time when unset so callers fall back to their default.
+func GetRangeEarliestDate() time.Time {
+	value := os.Getenv("RANGE_EARLIEST_DATE")
+	if value == "" {
+		return time.Time{}
+	}
+
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		log.Printf("Invalid RANGE_EARLIEST_DATE value %q, using default", value)
+		return time.Time{}
+	}
+	log.Printf("Using RANGE_EARLIEST_DATE from environment: %s", value)
+	return date
+}
+
+// GetRedisQConfig retrieves the RedisQ listen URL and queue ID from the environment.
+// Setting REDISQ_URL to "off" disables live ingestion.
+func GetRedisQConfig(defaultURL string) (string, string) {
+	listenURL := os.Getenv("REDISQ_URL")
+	if listenURL == "" {
+		listenURL = defaultURL
+	}
+	if strings.EqualFold(listenURL, "off") {
+		log.Printf("REDISQ_URL is off. Live killmail ingestion disabled")
+		return "", ""
+	}
+
+	queueID := os.Getenv("REDISQ_QUEUE_ID")
+	if queueID == "" {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			hostname = "local"
+		}
+		queueID = fmt.Sprintf("zkillanalytics-%s", hostname)
+		log.Printf("REDISQ_QUEUE_ID not set. Using default: %s", queueID)
+	}
+	return listenURL, queueID
+}
+
 func GetESIEnv(host string) (string, string, string) {
 	// Define the unique environment variable names for the specific host
 	clientID := os.Getenv(fmt.Sprintf("%s_EVE_CLIENT_ID", host))
@@ -103,6 +256,30 @@ func GetSecretKey() (string, []byte) {
 	return secret, key
 }
 
+// GetPreviousSecretKeys retrieves keys retired from SECRET_KEY, still accepted for decrypting identities,
+// from the comma-separated base64 SECRET_KEY_PREVIOUS.
+func GetPreviousSecretKeys() [][]byte {
+	value := os.Getenv("SECRET_KEY_PREVIOUS")
+	if value == "" {
+		return nil
+	}
+
+	var keys [][]byte
+	for _, secret := range strings.Split(value, ",") {
+		secret = strings.TrimSpace(secret)
+		if secret == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			log.Fatalf("Failed to decode previous key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	log.Printf("Using %d previous keys from SECRET_KEY_PREVIOUS", len(keys))
+	return keys
+}
+
 func generateSecret() ([]byte, error) {
 	key := make([]byte, 32)
 	if _, err := rand.Read(key); err != nil {
@@ -1,15 +1,27 @@
 package utils
 
 import (
+	"log"
 	"net/http"
 	"time"
 )
 
 // NewHTTPClientWithUserAgent creates an HTTP client that automatically includes the specified User-Agent header.
 func NewHTTPClientWithUserAgent(userAgent string) *http.Client {
+	// Allow enough idle connections per host for concurrent ESI hydration
+	baseTransport := http.DefaultTransport.(*http.Transport).Clone()
+	baseTransport.MaxIdleConnsPerHost = 32
+
+	// Record or replay exchanges when a cassette mode is configured
+	var transport http.RoundTripper = baseTransport
+	if mode, dir := GetCassetteConfig(); mode != "" {
+		log.Printf("Using HTTP cassettes in %s mode from %s", mode, dir)
+		transport = NewCassetteTransport(mode, dir, baseTransport)
+	}
+
 	// Define a custom RoundTripper that adds the User-Agent header
 	customTransport := &userAgentRoundTripper{
-		Wrapped:   http.DefaultTransport,
+		Wrapped:   transport,
 		UserAgent: userAgent,
 	}
 
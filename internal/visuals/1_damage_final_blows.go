@@ -3,7 +3,6 @@ package visuals
 import (
 	"sort"
 
-	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
 )
 
@@ -14,6 +13,7 @@ type CharacterData struct {
 }
 
 func GetDamageAndFinalBlows(chartData *model.ChartData) []CharacterData {
+	tracked := trackedEntities(chartData)
 	characterStats := make(map[int]*CharacterData)
 
 	for _, km := range chartData.KillMails {
@@ -24,7 +24,7 @@ func GetDamageAndFinalBlows(chartData *model.ChartData) []CharacterData {
 			}
 
 			// Check if the character is one of ours
-			if !config.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
+			if !tracked.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
 				continue
 			}
 
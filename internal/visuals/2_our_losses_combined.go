@@ -3,8 +3,8 @@ package visuals
 import (
 	"sort"
 
-	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/service"
 )
 
 type LossesData struct {
@@ -15,14 +15,15 @@ type LossesData struct {
 	ShipCount     int
 }
 
-func GetCombinedLossData(chartData *model.ChartData) []LossesData {
+func GetCombinedLossData(orchestrator *service.OrchestrateService, chartData *model.ChartData) []LossesData {
+	tracked := trackedEntities(chartData)
 	characterDataMap := make(map[string]*LossesData)
 	shipLossesMap := make(map[string]int)
 
 	for _, km := range chartData.KillMails {
 		victim := km.EsiKillMail.Victim
 
-		if config.TrackedCharacterID(victim.CharacterID) || config.TrackedCorporationID(victim.CorporationID) {
+		if tracked.TrackedVictim(victim.CharacterID, victim.CorporationID) {
 			characterInfo, exists := chartData.CharacterInfos[victim.CharacterID]
 			if !exists {
 				continue
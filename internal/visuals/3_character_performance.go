@@ -3,7 +3,6 @@ package visuals
 import (
 	"sort"
 
-	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
 )
 
@@ -28,6 +27,7 @@ type ChartJSDataset struct {
 }
 
 func GetCharacterPerformance(chartData *model.ChartData) []CharacterPerformanceData {
+	tracked := trackedEntities(chartData)
 	characterStats := make(map[int]*CharacterPerformanceData)
 
 	for _, km := range chartData.KillMails {
@@ -37,7 +37,7 @@ func GetCharacterPerformance(chartData *model.ChartData) []CharacterPerformanceD
 				continue
 			}
 
-			if !config.DisplayCharacter(characterID, attacker.CorporationID, attacker.AllianceID) {
+			if !tracked.DisplayCharacter(characterID, attacker.CorporationID, attacker.AllianceID) {
 				continue
 			}
 
@@ -3,8 +3,8 @@ package visuals
 import (
 	"sort"
 
-	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
+	"github.com/guarzo/zkillanalytics/internal/service"
 )
 
 type OurShipsUsedData struct {
@@ -13,7 +13,8 @@ type OurShipsUsedData struct {
 	SeriesData map[string][]int `json:"SeriesData"`
 }
 
-func GetOurShipsUsed(chartData *model.ChartData) OurShipsUsedData {
+func GetOurShipsUsed(orchestrator *service.OrchestrateService, chartData *model.ChartData) OurShipsUsedData {
+	tracked := trackedEntities(chartData)
 	characterShipCounts := make(map[string]map[string]int)
 	shipNameSet := make(map[string]struct{})
 	characters := []string{}
@@ -24,7 +25,7 @@ func GetOurShipsUsed(chartData *model.ChartData) OurShipsUsedData {
 	for _, km := range chartData.KillMails {
 		for _, attacker := range km.EsiKillMail.Attackers {
 
-			if !config.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
+			if !tracked.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
 				continue
 			}
 			characterInfo := chartData.CharacterInfos[attacker.CharacterID]
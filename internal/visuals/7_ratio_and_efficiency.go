@@ -3,7 +3,6 @@ package visuals
 import (
 	"sort"
 
-	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
 )
 
@@ -18,12 +17,13 @@ type KillLossAndISKEfficiencyData struct {
 }
 
 func GetKillLossAndISKEfficiencyData(chartData *model.ChartData) []KillLossAndISKEfficiencyData {
+	tracked := trackedEntities(chartData)
 	characterStats := make(map[string]*KillLossAndISKEfficiencyData)
 
 	for _, km := range chartData.KillMails {
 		// Process attackers
 		for _, attacker := range km.EsiKillMail.Attackers {
-			if config.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
+			if tracked.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
 				characterInfo := chartData.CharacterInfos[attacker.CharacterID]
 				characterName := characterInfo.Name
 
@@ -39,7 +39,7 @@ func GetKillLossAndISKEfficiencyData(chartData *model.ChartData) []KillLossAndIS
 
 		// Process victim
 		victim := km.EsiKillMail.Victim
-		if config.TrackedCharacterID(victim.CharacterID) || config.TrackedCorporationID(victim.CorporationID) {
+		if tracked.TrackedVictim(victim.CharacterID, victim.CorporationID) {
 			characterInfo := chartData.CharacterInfos[victim.CharacterID]
 			characterName := characterInfo.Name
 
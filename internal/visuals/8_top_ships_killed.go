@@ -5,6 +5,7 @@ import (
 
 	"github.com/guarzo/zkillanalytics/internal/model"
 	"github.com/guarzo/zkillanalytics/internal/persist"
+	"github.com/guarzo/zkillanalytics/internal/service"
 )
 
 type ShipKillData struct {
@@ -13,7 +14,7 @@ type ShipKillData struct {
 	Name       string `json:"Name"`
 }
 
-func GetTopShipsKilledData(chartData *model.ChartData) []ShipKillData {
+func GetTopShipsKilledData(orchestrator *service.OrchestrateService, chartData *model.ChartData) []ShipKillData {
 	// Initialize a map to count killmails by ship type
 	shipKillCounts := make(map[int]ShipKillData)
 
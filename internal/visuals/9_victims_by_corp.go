@@ -5,9 +5,9 @@ package visuals
 import (
 	"sort"
 
-	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
 	"github.com/guarzo/zkillanalytics/internal/persist"
+	"github.com/guarzo/zkillanalytics/internal/service"
 )
 
 // CorporationKillCount holds the kill count data for a corporation
@@ -17,17 +17,19 @@ type CorporationKillCount struct {
 	KillCount     int    `json:"kill_count"`
 }
 
-func GetVictimsByCorp(chartData *model.ChartData) []CorporationKillCount {
+func GetVictimsByCorp(orchestrator *service.OrchestrateService, chartData *model.ChartData) []CorporationKillCount {
 	corpKillMails := make(map[int]CorporationKillCount)
 
+	tracked := trackedEntities(chartData)
+
 	// Populate the kill count map using victims from detailed killmails
 	for _, km := range chartData.KillMails {
 		victimCorpID := km.EsiKillMail.Victim.CorporationID
-		if persist.Contains(config.CorporationIDs, victimCorpID) {
+		if persist.Contains(tracked.Corporations, victimCorpID) {
 			continue
 		}
 		corpInfo, exists := chartData.CorporationInfos[victimCorpID]
-		if !exists || persist.Contains(config.AllianceIDs, corpInfo.AllianceID) {
+		if !exists || persist.Contains(tracked.Alliances, corpInfo.AllianceID) {
 			continue
 		}
 
@@ -38,7 +40,7 @@ func GetVictimsByCorp(chartData *model.ChartData) []CorporationKillCount {
 			corpKillMails[victimCorpID] = CorporationKillCount{
 				CorporationID: victimCorpID,
 				KillCount:     1,
-				Name:          corpInfo.Ticker,
+				Name:          corpInfo.Name,
 			}
 		}
 	}
@@ -57,5 +59,16 @@ func GetVictimsByCorp(chartData *model.ChartData) []CorporationKillCount {
 		sortedData = sortedData[:15]
 	}
 
+	// Tickers are looked up only for the corporations shown, since entities are resolved without them
+	for i, data := range sortedData {
+		ticker := chartData.CorporationInfos[data.CorporationID].Ticker
+		if ticker == "" {
+			ticker = orchestrator.LookupCorporationTicker(data.CorporationID)
+		}
+		if ticker != "" {
+			sortedData[i].Name = ticker
+		}
+	}
+
 	return sortedData
 }
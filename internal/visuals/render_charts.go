@@ -9,29 +9,31 @@ import (
 	"strings"
 	"unicode"
 
-	"github.com/sirupsen/logrus"
-
+	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
 	"github.com/guarzo/zkillanalytics/internal/service"
 )
 
-// Global variables
-var (
-	orchestrator *service.OrchestrateService
-	logger       *logrus.Logger
-)
-
 // TemplateData holds all the data passed to the template
 type TemplateData struct {
 	TimeFrames []TimeFrameData
+	ActiveTab  string // Name of the time frame shown first
 }
 
 // TimeFrameData represents data for a specific time frame (MTD, YTD, LastM)
 type TimeFrameData struct {
 	Name   string       // e.g., "MTD", "YTD", "LastM"
+	Label  string       // Tab text, e.g., "MTD" or "Last 7 days (2024-10-11 to 2024-10-17)"
 	Charts []ChartEntry // Slice of charts for this time frame
 }
 
+// TimeFrame is a window of chart data rendered as one tab of a dashboard.
+type TimeFrame struct {
+	Name  string // Part of every chart ID, so it must not contain underscores
+	Label string
+	Data  *model.ChartData
+}
+
 // ChartEntry represents a single chart's data
 type ChartEntry struct {
 	Name string      // e.g., "Character Damage and Final Blows"
@@ -42,8 +44,9 @@ type ChartEntry struct {
 
 // Chart represents a single chart with its data preparation function
 type Chart struct {
+	Name        string // Identifies the chart in the API, e.g., "damage-final-blows"
 	FieldPrefix string
-	PrepareFunc func(*model.ChartData) interface{}
+	PrepareFunc func(*service.OrchestrateService, *model.ChartData) interface{}
 	Description string
 	Type        string // e.g., "bar", "line", "matrix", "wordCloud"
 }
@@ -51,125 +54,149 @@ type Chart struct {
 // Define all charts with their corresponding preparation functions and field prefixes
 var chartDefinitions = []Chart{
 	{
+		Name:        "damage-final-blows",
 		FieldPrefix: "CharacterDamageData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
+		PrepareFunc: func(_ *service.OrchestrateService, cd *model.ChartData) interface{} {
 			return GetDamageAndFinalBlows(cd)
 		},
 		Description: "Character Damage and Final Blows",
 		Type:        "bar",
 	},
 	{
+		Name:        "character-performance",
 		FieldPrefix: "CharacterPerformanceData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
+		PrepareFunc: func(_ *service.OrchestrateService, cd *model.ChartData) interface{} {
 			return GetCharacterPerformance(cd)
 		},
 		Description: "Character Performance",
 		Type:        "bar",
 	},
 	{
+		Name:        "our-ships-used",
 		FieldPrefix: "OurShipsUsedData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
-			return GetOurShipsUsed(cd)
+		PrepareFunc: func(orchestrator *service.OrchestrateService, cd *model.ChartData) interface{} {
+			return GetOurShipsUsed(orchestrator, cd)
 		},
 		Description: "Our Ships Used",
 		Type:        "bar",
 	},
 	{
+		Name:        "kill-activity",
 		FieldPrefix: "KillActivityData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
+		PrepareFunc: func(_ *service.OrchestrateService, cd *model.ChartData) interface{} {
 			return GetKillActivityOverTime(cd, "daily")
 		},
 		Description: "Kill Activity Over Time",
 		Type:        "line",
 	},
 	{
+		Name:        "kill-heatmap",
 		FieldPrefix: "KillHeatmapData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
+		PrepareFunc: func(_ *service.OrchestrateService, cd *model.ChartData) interface{} {
 			return GetKillHeatmapData(cd)
 		},
 		Description: "Kills Heatmap",
 		Type:        "matrix",
 	},
 	{
+		Name:        "ratio-and-efficiency",
 		FieldPrefix: "RatioAndEfficiencyData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
+		PrepareFunc: func(_ *service.OrchestrateService, cd *model.ChartData) interface{} {
 			return GetKillLossAndISKEfficiencyData(cd)
 		},
 		Description: "Kill-to-Loss Ratio",
 		Type:        "bar",
 	},
 	{
+		Name:        "top-ships-killed",
 		FieldPrefix: "TopShipsKilledData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
-			return GetTopShipsKilledData(cd)
+		PrepareFunc: func(orchestrator *service.OrchestrateService, cd *model.ChartData) interface{} {
+			return GetTopShipsKilledData(orchestrator, cd)
 		},
 		Description: "Top Ships Killed",
 		Type:        "wordCloud",
 	},
 	{
+		Name:        "victims-by-corp",
 		FieldPrefix: "VictimsByCorpData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
-			return GetVictimsByCorp(cd)
+		PrepareFunc: func(orchestrator *service.OrchestrateService, cd *model.ChartData) interface{} {
+			return GetVictimsByCorp(orchestrator, cd)
 		},
 		Description: "Victims by Corporation",
 		Type:        "bar",
 	},
 	{
+		Name:        "fleet-size-and-value",
 		FieldPrefix: "FleetSizeAndValueData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
+		PrepareFunc: func(_ *service.OrchestrateService, cd *model.ChartData) interface{} {
 			return GetFleetSizeAndValueData(cd, "daily")
 		},
 		Description: "Fleet Size and Value Killed Over Time",
 		Type:        "line",
 	},
 	{
+		Name:        "combined-losses",
 		FieldPrefix: "CombinedLossesData",
-		PrepareFunc: func(cd *model.ChartData) interface{} {
-			return GetCombinedLossData(cd)
+		PrepareFunc: func(orchestrator *service.OrchestrateService, cd *model.ChartData) interface{} {
+			return GetCombinedLossData(orchestrator, cd)
 		},
 		Description: "Combined Losses",
 		Type:        "bar",
 	},
 }
 
-// RenderCharts prepares the template data and renders the template to a file
-func RenderCharts(orchestrateService *service.OrchestrateService, ytdChartData, lastMonthChartData, mtdChartData *model.ChartData, filePath string) error {
-	orchestrator = orchestrateService
-	logger = orchestrateService.Logger
+// Charts returns every chart definition, in dashboard order.
+func Charts() []Chart {
+	return append([]Chart{}, chartDefinitions...)
+}
 
-	// Fetch tracked characters from OrchestrateService
-	ytdTrackedCharacters := orchestrateService.GetTrackedCharactersFromKillMails(ytdChartData.KillMails, &ytdChartData.ESIData)
-	lastMTrackedCharacters := orchestrateService.GetTrackedCharactersFromKillMails(lastMonthChartData.KillMails, &lastMonthChartData.ESIData)
-	mtdTrackedCharacters := orchestrateService.GetTrackedCharactersFromKillMails(mtdChartData.KillMails, &mtdChartData.ESIData)
+// FindChart returns the chart definition with the given API name.
+func FindChart(name string) (Chart, bool) {
+	for _, chart := range chartDefinitions {
+		if chart.Name == name {
+			return chart, true
+		}
+	}
+	return Chart{}, false
+}
 
-	trackedCharacters := append(ytdTrackedCharacters, lastMTrackedCharacters...)
-	trackedCharacters = append(trackedCharacters, mtdTrackedCharacters...)
+// PrepareChart returns a chart's data for chartData, as rendered into the dashboards.
+func PrepareChart(orchestrateService *service.OrchestrateService, chart Chart, chartData *model.ChartData) interface{} {
+	return chart.PrepareFunc(orchestrateService, chartData)
+}
 
-	logger.Infof("There are %d tracked characters", len(trackedCharacters))
+// RenderCharts prepares the template data and renders the template to a file
+func RenderCharts(orchestrateService *service.OrchestrateService, ytdChartData, lastMonthChartData, mtdChartData *model.ChartData, filePath string) error {
+	return RenderTimeFrames(orchestrateService, []TimeFrame{
+		{Name: "MTD", Label: "MTD", Data: mtdChartData},
+		{Name: "LastM", Label: "LastM", Data: lastMonthChartData},
+		{Name: "YTD", Label: "YTD", Data: ytdChartData},
+	}, filePath)
+}
 
-	data := TemplateData{
-		TimeFrames: []TimeFrameData{
-			{Name: "MTD", Charts: []ChartEntry{}},
-			{Name: "LastM", Charts: []ChartEntry{}},
-			{Name: "YTD", Charts: []ChartEntry{}},
-		},
+// RenderTimeFrames renders every chart definition for each time frame to a file, with the first frame's tab shown.
+func RenderTimeFrames(orchestrateService *service.OrchestrateService, timeFrames []TimeFrame, filePath string) error {
+	if len(timeFrames) == 0 {
+		return fmt.Errorf("no time frames to render")
 	}
+	logger := orchestrateService.Logger
 
-	// Define time frames and associate chart data
-	timeFrames := []struct {
-		Name string
-		Data *model.ChartData
-	}{
-		{"MTD", mtdChartData},
-		{"LastM", lastMonthChartData},
-		{"YTD", ytdChartData},
+	// Fetch tracked characters from OrchestrateService
+	var trackedCharacters []int
+	for _, tf := range timeFrames {
+		trackedCharacters = append(trackedCharacters, orchestrateService.GetTrackedCharactersFromKillMails(tf.Data.KillMails, &tf.Data.ESIData)...)
 	}
 
+	logger.Infof("There are %d tracked characters", len(trackedCharacters))
+
+	data := TemplateData{ActiveTab: timeFrames[0].Name}
+
 	// Populate TemplateData
 	for _, tf := range timeFrames {
+		frame := TimeFrameData{Name: tf.Name, Label: tf.Label}
 		for _, chart := range chartDefinitions {
 			// Prepare data
-			preparedData, err := prepareData(tf.Data, chart.PrepareFunc, chart.Description)
+			preparedData, err := prepareData(orchestrateService, tf.Data, chart)
 			if err != nil {
 				logger.Errorf("Error preparing data for %s: %v", chart.Description, err)
 				preparedData = template.JS("[]") // Fallback to empty array
@@ -178,31 +205,16 @@ func RenderCharts(orchestrateService *service.OrchestrateService, ytdChartData,
 			// Generate unique canvas ID based on Description and Timeframe
 			chartID := fmt.Sprintf("%sChart_%s", toLowerCamelCase(chart.Description), tf.Name)
 
-			// Append to charts for this time frame
-			for i, finalTF := range data.TimeFrames {
-				if finalTF.Name == tf.Name {
-					data.TimeFrames[i].Charts = append(data.TimeFrames[i].Charts, ChartEntry{
-						Name: chart.Description,
-						ID:   chartID,
-						Data: preparedData,
-						Type: chart.Type,
-					})
-					break
-				}
-			}
+			frame.Charts = append(frame.Charts, ChartEntry{
+				Name: chart.Description,
+				ID:   chartID,
+				Data: preparedData,
+				Type: chart.Type,
+			})
 		}
+		data.TimeFrames = append(data.TimeFrames, frame)
 	}
 
-	// Log summary of charts for each timeframe to verify distinct data loading
-	//for _, tf := range data.TimeFrames {
-	//	logger.Infof("Time frame %s has %d charts", tf.Name, len(tf.Charts))
-	//	if len(tf.Charts) > 0 {
-	//		logger.Infof("Example chart for %s: ID=%s, Description=%s", tf.Name, tf.Charts[0].ID, tf.Charts[0].Name)
-	//	} else {
-	//		logger.Warnf("No charts found for time frame %s", tf.Name)
-	//	}
-	//}
-
 	// Render the template
 	funcMap := template.FuncMap{"toLower": strings.ToLower}
 	tmpl, err := template.New("tps.tmpl").Funcs(funcMap).ParseFiles(filepath.Join("static", "tmpl", "tps.tmpl"))
@@ -248,11 +260,11 @@ func toLowerCamelCase(s string) string {
 }
 
 // Generic helper function to prepare data
-func prepareData(chartData *model.ChartData, getDataFunc func(*model.ChartData) interface{}, description string) (template.JS, error) {
-	data := getDataFunc(chartData)
+func prepareData(orchestrateService *service.OrchestrateService, chartData *model.ChartData, chart Chart) (template.JS, error) {
+	data := chart.PrepareFunc(orchestrateService, chartData)
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		logger.Errorf("Error marshalling %s: %v", description, err)
+		orchestrateService.Logger.Errorf("Error marshalling %s: %v", chart.Description, err)
 		return "[]", err
 	}
 	// logger.Infof("Data sample for %s: %v", description, getDataSample(data))
@@ -266,3 +278,13 @@ func prepareData(chartData *model.ChartData, getDataFunc func(*model.ChartData)
 //	}
 //	return data
 //}
+
+// trackedEntities returns the entities tracked by the group the chart data was collected for.
+func trackedEntities(chartData *model.ChartData) config.TrackedEntities {
+	group := chartData.Group
+	if group == "" {
+		group = config.DefaultGroup
+	}
+	tracked, _ := config.Group(group)
+	return tracked
+}
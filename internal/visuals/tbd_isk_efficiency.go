@@ -3,7 +3,6 @@ package visuals
 import (
 	"sort"
 
-	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
 )
 
@@ -15,12 +14,13 @@ type ISKEfficiencyData struct {
 }
 
 func GetISKEfficiencyData(chartData *model.ChartData) []ISKEfficiencyData {
+	tracked := trackedEntities(chartData)
 	characterStats := make(map[string]*ISKEfficiencyData)
 
 	for _, km := range chartData.KillMails {
 		// Process attackers
 		for _, attacker := range km.EsiKillMail.Attackers {
-			if config.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
+			if tracked.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
 				characterInfo := chartData.CharacterInfos[attacker.CharacterID]
 				characterName := characterInfo.Name
 
@@ -36,7 +36,7 @@ func GetISKEfficiencyData(chartData *model.ChartData) []ISKEfficiencyData {
 		// Process victim
 		victim := km.EsiKillMail.Victim
 
-		if config.TrackedCharacterID(victim.CharacterID) || config.TrackedCorporationID(victim.CorporationID) {
+		if tracked.TrackedVictim(victim.CharacterID, victim.CorporationID) {
 			characterInfo := chartData.CharacterInfos[victim.CharacterID]
 			characterName := characterInfo.Name
 
@@ -6,12 +6,12 @@ import (
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/opts"
 
-	"github.com/guarzo/zkillanalytics/internal/config"
 	"github.com/guarzo/zkillanalytics/internal/model"
 	"github.com/guarzo/zkillanalytics/internal/service"
 )
 
 func RenderWeaponsByCharacter(orchestrator *service.OrchestrateService, chartData *model.ChartData) *charts.Bar {
+	tracked := trackedEntities(chartData)
 	// Initialize a map to count weapons used by each attacking character
 	characterWeapons := make(map[string]map[string]int)
 	characterKills := make(map[string]int)
@@ -38,7 +38,7 @@ func RenderWeaponsByCharacter(orchestrator *service.OrchestrateService, chartDat
 				continue
 			}
 
-			if config.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
+			if tracked.DisplayCharacter(attacker.CharacterID, attacker.CorporationID, attacker.AllianceID) {
 				if _, found := characterWeapons[characterName]; !found {
 					characterWeapons[characterName] = make(map[string]int)
 				}
@@ -3,17 +3,63 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
 
 	"github.com/guarzo/zkillanalytics/cmd"
 	"github.com/guarzo/zkillanalytics/internal/config"
+	"github.com/guarzo/zkillanalytics/internal/persist"
 )
 
 func main() {
+	cassetteMode := flag.String("cassette-mode", "", "record or replay outbound HTTP through cassettes (overrides HTTP_CASSETTE_MODE)")
+	cassetteDir := flag.String("cassette-dir", "", "directory holding HTTP cassettes (overrides HTTP_CASSETTE_DIR)")
+	flag.Parse()
+	if *cassetteMode != "" {
+		os.Setenv("HTTP_CASSETTE_MODE", *cassetteMode)
+	}
+	if *cassetteDir != "" {
+		os.Setenv("HTTP_CASSETTE_DIR", *cassetteDir)
+	}
+
 	appSetup, err := config.NewAppSetup()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+
+	if appSetup.BackupsKept >= 0 {
+		persist.BackupsKept = appSetup.BackupsKept
+	}
+	persist.EarliestRangeDate = appSetup.RangeEarliestDate
+
+	// Run a command-line tool instead of the server when one is named
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "backfill" {
+		if err := cmd.RunBackfill(appSetup, args[1:]); err != nil {
+			log.Fatalf("Backfill failed: %v", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "rotate-keys" {
+		if err := cmd.RunRotateKeys(appSetup, args[1:]); err != nil {
+			log.Fatalf("Key rotation failed: %v", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := cmd.RunMigrate(args[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "backups" {
+		if err := cmd.RunBackups(args[1:]); err != nil {
+			log.Fatalf("Backups failed: %v", err)
+		}
+		return
+	}
+
 	// Start the web server
 	cmd.StartServer(appSetup)
 }